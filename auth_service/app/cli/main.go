@@ -0,0 +1,151 @@
+// Command cli is the operator tool for auth_service. It talks to the database
+// directly and uses the same config.yml and environment as the service.
+//
+//	go run ./app/cli client create -id ai-service -name "AI Service" -scopes "lectures:read"
+//	go run ./app/cli client create -id web -name "Frontend" -public -redirect-uris "http://localhost:3000/callback"
+//	go run ./app/cli tokens revoke -user 01234567-89ab-cdef-0123-456789abcdef
+//	go run ./app/cli tokens revoke -all
+package main
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/auth"
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/postgres"
+	"auth_service/internal/infrastructure/postgres/token"
+	"auth_service/internal/infrastructure/repository"
+	"auth_service/internal/usecase/oauth"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+)
+
+const usage = `usage:
+  cli client create -id <client_id> -name <name> [-scopes "<scope> ..."] [-redirect-uris "<uri> ..."] [-public]
+  cli tokens revoke (-user <user_id> | -all)
+`
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(context.Background(), os.Args[1], os.Args[2], os.Args[3:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, group, command string, args []string) error {
+	log := logger.New("prod")
+
+	viper.SetConfigName("config")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath(".")
+	if err := viper.ReadInConfig(); err != nil {
+		return err
+	}
+
+	db, err := postgres.Connect(
+		viper.GetString("db.username"),
+		os.Getenv("DB_PASSWORD"),
+		viper.GetString("db.host"),
+		viper.GetString("db.port"),
+		viper.GetString("db.dbname"),
+		viper.GetString("db.sslmode"),
+	)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	repos := repository.NewRepository(db, log)
+
+	switch group + " " + command {
+	case "client create":
+		return createClient(ctx, oauth.NewServiceOAuth(repos.Clients, log, nil, nil), args)
+	case "tokens revoke":
+		return revokeTokens(ctx, auth.NewEpochs(token.NewTokenEpochRepository(db, log), log), args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", group+" "+command)
+	}
+}
+
+func createClient(ctx context.Context, service *oauth.ServiceOAuth, args []string) error {
+	fs := flag.NewFlagSet("client create", flag.ContinueOnError)
+	clientID := fs.String("id", "", "client_id, e.g. content-service")
+	name := fs.String("name", "", "human readable name")
+	scopes := fs.String("scopes", "", "space separated scopes the client may request")
+	redirectURIs := fs.String("redirect-uris", "", "space separated OIDC redirect URIs")
+	public := fs.Bool("public", false, "client without a secret, e.g. a browser app using PKCE")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *clientID == "" || *name == "" {
+		return fmt.Errorf("-id and -name are required")
+	}
+
+	secret, err := service.CreateClient(ctx, domain.Client{
+		ClientID:     *clientID,
+		Name:         *name,
+		Scopes:       strings.Fields(*scopes),
+		RedirectURIs: strings.Fields(*redirectURIs),
+		Public:       *public,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *public {
+		fmt.Printf("client_id: %s (public, no secret)\n", *clientID)
+		return nil
+	}
+
+	fmt.Printf("client_id:     %s\nclient_secret: %s\n\nStore the secret now, it cannot be shown again.\n", *clientID, secret)
+	return nil
+}
+
+// revokeTokens increments a token epoch. Running instances pick the new epoch
+// up within 10 seconds.
+func revokeTokens(ctx context.Context, epochs *auth.Epochs, args []string) error {
+	fs := flag.NewFlagSet("tokens revoke", flag.ContinueOnError)
+	userID := fs.String("user", "", "revoke every token of this user id")
+	all := fs.Bool("all", false, "revoke every token of every user and service")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*userID == "") == !*all {
+		return fmt.Errorf("exactly one of -user and -all is required")
+	}
+
+	if *all {
+		epoch, err := epochs.IncrementGlobal(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("global token epoch: %d\n\nEvery token is revoked; everyone has to log in again.\n", epoch)
+		return nil
+	}
+
+	id, err := uuid.Parse(*userID)
+	if err != nil {
+		return fmt.Errorf("invalid user id: %w", err)
+	}
+	epoch, err := epochs.IncrementUser(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("user %s not found", id)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("token epoch of %s: %d\n", id, epoch)
+	return nil
+}
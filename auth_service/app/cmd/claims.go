@@ -0,0 +1,26 @@
+package main
+
+import (
+	"auth_service/internal/domain"
+	"fmt"
+	"github.com/spf13/viper"
+	"slices"
+)
+
+// claimsPolicy reads tokens.claims, the profile claims added to access tokens
+// per audience. Every audience must be one of tokens.audiences.
+func claimsPolicy() (domain.ClaimsPolicy, error) {
+	policy := domain.ClaimsPolicy(viper.GetStringMapStringSlice("tokens.claims"))
+	audiences := viper.GetStringSlice("tokens.audiences")
+	for aud, claims := range policy {
+		if !slices.Contains(audiences, aud) {
+			return nil, fmt.Errorf("tokens.claims: audience %q is not in tokens.audiences", aud)
+		}
+		for _, claim := range claims {
+			if !slices.Contains(domain.ProfileClaimNames, claim) {
+				return nil, fmt.Errorf("tokens.claims.%s: unknown claim %q", aud, claim)
+			}
+		}
+	}
+	return policy, nil
+}
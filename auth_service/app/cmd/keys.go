@@ -0,0 +1,151 @@
+package main
+
+import (
+	"auth_service/internal/infrastructure/auth"
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+)
+
+// readSecret returns the secret in the file named by the <name>_FILE variable,
+// or else the <name> variable itself. Only files can change while the process
+// runs, so only secrets read from files can be rotated with SIGHUP.
+func readSecret(name string) (string, error) {
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return os.Getenv(name), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read %s_FILE: %w", name, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// loadAccessKey returns the key new access tokens are signed with: the PEM file
+// from JWT_ACCESS_PRIVATE_KEY_FILE when set, the JWT_ACCESS_SECRET otherwise.
+func loadAccessKey() (*auth.SigningKey, error) {
+	if path := os.Getenv("JWT_ACCESS_PRIVATE_KEY_FILE"); path != "" {
+		return auth.LoadPrivateKeyFile(path)
+	}
+	secret, err := readSecret("JWT_ACCESS_SECRET")
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		return nil, errors.New("none of JWT_ACCESS_PRIVATE_KEY_FILE, JWT_ACCESS_SECRET_FILE and JWT_ACCESS_SECRET is set")
+	}
+	return auth.NewHMACKey(secret)
+}
+
+func loadRefreshKey() (*auth.SigningKey, error) {
+	secret, err := readSecret("JWT_REFRESH_SECRET")
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		return nil, errors.New("neither JWT_REFRESH_SECRET_FILE nor JWT_REFRESH_SECRET is set")
+	}
+	return auth.NewHMACKey(secret)
+}
+
+// newTokenManager builds the keyrings from the environment. Keys retired before
+// this process started are passed as verify-only keys:
+//   - JWT_ACCESS_VERIFY_KEY_FILES: comma separated PEM files (public or private)
+//   - JWT_ACCESS_PREVIOUS_SECRET / JWT_REFRESH_PREVIOUS_SECRET: old HMAC secrets,
+//     or their _FILE variants
+func newTokenManager(denylist *auth.Denylist, epochs *auth.Epochs) (*auth.TokenManager, error) {
+	accessKey, err := loadAccessKey()
+	if err != nil {
+		return nil, err
+	}
+	refreshKey, err := loadRefreshKey()
+	if err != nil {
+		return nil, err
+	}
+
+	accessKeys, err := auth.NewKeyring(accessKey)
+	if err != nil {
+		return nil, err
+	}
+	refreshKeys, err := auth.NewKeyring(refreshKey)
+	if err != nil {
+		return nil, err
+	}
+	tokenManager, err := auth.NewTokenManager(accessKeys, refreshKeys, denylist, epochs, tokenPolicy())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range strings.Split(os.Getenv("JWT_ACCESS_VERIFY_KEY_FILES"), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		key, err := auth.LoadVerifyKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		tokenManager.AddAccessVerifyKey(key)
+	}
+	accessPrevious, err := readSecret("JWT_ACCESS_PREVIOUS_SECRET")
+	if err != nil {
+		return nil, err
+	}
+	if accessPrevious != "" {
+		key, err := auth.NewHMACKey(accessPrevious)
+		if err != nil {
+			return nil, err
+		}
+		tokenManager.AddAccessVerifyKey(key)
+	}
+	refreshPrevious, err := readSecret("JWT_REFRESH_PREVIOUS_SECRET")
+	if err != nil {
+		return nil, err
+	}
+	if refreshPrevious != "" {
+		key, err := auth.NewHMACKey(refreshPrevious)
+		if err != nil {
+			return nil, err
+		}
+		tokenManager.AddRefreshVerifyKey(key)
+	}
+
+	return tokenManager, nil
+}
+
+// tokenPolicy reads the tokens section of the config. Every key can be
+// overridden from the environment, e.g. TOKENS_ACCESS_TTL=15m. The policy is
+// validated by auth.NewTokenManager.
+func tokenPolicy() auth.TokenPolicy {
+	return auth.TokenPolicy{
+		Issuer:         viper.GetString("tokens.issuer"),
+		AccessTTL:      viper.GetDuration("tokens.access_ttl"),
+		DelegatedTTL:   viper.GetDuration("tokens.delegated_ttl"),
+		RefreshTTL:     viper.GetDuration("tokens.refresh_ttl"),
+		Algorithm:      viper.GetString("tokens.algorithm"),
+		Leeway:         viper.GetDuration("tokens.leeway"),
+		NotBefore:      viper.GetBool("tokens.not_before"),
+		RequiredClaims: viper.GetStringSlice("tokens.required_claims"),
+		Audiences: auth.AudiencePolicy{
+			Allowed:  viper.GetStringSlice("tokens.audiences"),
+			Default:  viper.GetStringSlice("tokens.default_audience"),
+			Accepted: viper.GetStringSlice("tokens.accepted_audiences"),
+		},
+	}
+}
+
+// rotateKeys reloads the active keys from their files. Keys given directly in
+// the environment cannot change and stay as they are.
+func rotateKeys(tokenManager *auth.TokenManager) error {
+	accessKey, err := loadAccessKey()
+	if err != nil {
+		return err
+	}
+	refreshKey, err := loadRefreshKey()
+	if err != nil {
+		return err
+	}
+	return tokenManager.RotateKeys(accessKey, refreshKey)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/mail"
+	"auth_service/internal/usecase/auth"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"net/url"
+	"os"
+)
+
+// newMailer builds the mailer picked by mail.driver. Emails carry live
+// verification and reset links, so writing them to the log must be chosen
+// explicitly; there is no fallback when the SMTP server is missing.
+func newMailer(log *logger.SlogLogger) (auth.Mailer, error) {
+	switch driver := viper.GetString("mail.driver"); driver {
+	case "smtp":
+		cfg := mail.SMTPConfig{
+			Host:     viper.GetString("mail.smtp_host"),
+			Port:     viper.GetString("mail.smtp_port"),
+			Username: viper.GetString("mail.smtp_username"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     viper.GetString("mail.from"),
+		}
+		if cfg.Host == "" || cfg.Port == "" || cfg.From == "" {
+			return nil, errors.New("mail.smtp_host, mail.smtp_port and mail.from are required by the smtp driver")
+		}
+		return mail.NewSMTPMailer(cfg), nil
+	case "log":
+		log.Warn(context.Background(), "mail: emails are written to the log with their links, for local development only")
+		return mail.NewLogMailer(log), nil
+	default:
+		return nil, fmt.Errorf("mail.driver must be smtp or log, got %q", driver)
+	}
+}
+
+// mailLinks reads the frontend pages emails link to.
+func mailLinks() (auth.Links, error) {
+	links := auth.Links{}
+	for key, link := range map[string]*string{
+		"verify_email_url":   &links.VerifyEmail,
+		"reset_password_url": &links.ResetPassword,
+	} {
+		*link = viper.GetString("mail." + key)
+		if u, err := url.Parse(*link); err != nil || !u.IsAbs() {
+			return auth.Links{}, fmt.Errorf("mail.%s must be an absolute URL", key)
+		}
+	}
+	return links, nil
+}
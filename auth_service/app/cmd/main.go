@@ -22,14 +22,17 @@ import (
 	"auth_service/internal/infrastructure/auth"
 	"auth_service/internal/infrastructure/logger"
 	"auth_service/internal/infrastructure/postgres"
+	"auth_service/internal/infrastructure/postgres/token"
 	"auth_service/internal/infrastructure/repository"
 	"auth_service/internal/interfaces/http/handler"
 	"auth_service/internal/interfaces/http/middleware"
 	"auth_service/internal/usecase"
+	"auth_service/internal/usecase/oidc"
 	"context"
 	"github.com/spf13/viper"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -41,12 +44,6 @@ func main() {
 	if err := initConfig(); err != nil {
 		log.Error(ctx, "init config error : ", err.Error())
 	}
-	accessSecret := os.Getenv("JWT_ACCESS_SECRET")
-	refreshSecret := os.Getenv("JWT_REFRESH_SECRET")
-
-	if accessSecret == "" || refreshSecret == "" {
-		log.Error(ctx, "JWT secrets are not set")
-	}
 
 	retryCfg := postgres.RetryConfig{
 		MaxAttempts: 10,
@@ -71,11 +68,63 @@ func main() {
 		log.Error(ctx, "db connect failed", "error", err)
 		return
 	}
-	tokenManager := auth.NewTokenManager(accessSecret, refreshSecret)
+
+	denylist := auth.NewDenylist(token.NewRevocationRepository(db, log), log)
+	if err := denylist.Sync(ctx); err != nil {
+		log.Error(ctx, "denylist load failed", "error", err)
+		return
+	}
+	denylistCtx, stopDenylist := context.WithCancel(ctx)
+	defer stopDenylist()
+	go denylist.Run(denylistCtx, 10*time.Second)
+
+	epochs := auth.NewEpochs(token.NewTokenEpochRepository(db, log), log)
+	if err := epochs.Sync(ctx); err != nil {
+		log.Error(ctx, "token epochs load failed", "error", err)
+		return
+	}
+	go epochs.Run(denylistCtx, 10*time.Second)
+
+	tokenManager, err := newTokenManager(denylist, epochs)
+	if err != nil {
+		log.Error(ctx, "JWT keys are not configured", "error", err)
+		return
+	}
+
+	sessions, err := sessionPolicies()
+	if err != nil {
+		log.Error(ctx, "session policy is not configured", "error", err)
+		return
+	}
+
+	claims, err := claimsPolicy()
+	if err != nil {
+		log.Error(ctx, "token claims are not configured", "error", err)
+		return
+	}
+
+	links, err := mailLinks()
+	if err != nil {
+		log.Error(ctx, "mail is not configured", "error", err)
+		return
+	}
+	mailer, err := newMailer(log)
+	if err != nil {
+		log.Error(ctx, "mail is not configured", "error", err)
+		return
+	}
 
 	repos := repository.NewRepository(db, log)
-	services := usecase.NewService(repos, log, tokenManager)
-	handlers := handler.NewHandler(services, log)
+	services := usecase.NewService(repos, log, tokenManager, mailer, usecase.Config{
+		OIDC: oidc.Config{
+			Issuer:                viper.GetString("oidc.issuer"),
+			DeviceVerificationURI: viper.GetString("oidc.device_verification_uri"),
+		},
+		Sessions: sessions,
+		Claims:   claims,
+		Links:    links,
+	})
+	handlers := handler.NewHandler(services, log, tokenManager.AcceptedAudiences())
 	router := handlers.InitRouter()
 	routerWithMiddleware := middleware.RequestID(router)
 	srv := new(handler.Server)
@@ -87,6 +136,20 @@ func main() {
 	}()
 
 	log.Info(ctx, "pm project app starting")
+	// SIGHUP re-reads the signing keys; the previous ones keep verifying until
+	// the tokens they signed expire.
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := rotateKeys(tokenManager); err != nil {
+				log.Error(ctx, "signing key rotation failed", "error", err)
+				continue
+			}
+			log.Info(ctx, "signing keys reloaded")
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 	<-quit
@@ -103,5 +166,9 @@ func initConfig() error {
 	viper.SetConfigName("config") // config.yml
 	viper.SetConfigType("yaml")   // 🔥 важно
 	viper.AddConfigPath(".")
+	// Environment variables override the file: tokens.access_ttl is read
+	// from TOKENS_ACCESS_TTL.
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
 	return viper.ReadInConfig()
 }
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"auth_service/internal/domain"
+	"fmt"
+	"github.com/spf13/viper"
+)
+
+// sessionPolicies reads the session lifetimes from the sessions section of the
+// config.
+func sessionPolicies() (domain.SessionPolicies, error) {
+	policies := domain.SessionPolicies{}
+	for key, policy := range map[string]*domain.SessionPolicy{
+		"default":     &policies.Default,
+		"remember_me": &policies.RememberMe,
+	} {
+		policy.IdleTimeout = viper.GetDuration("sessions." + key + ".idle_timeout")
+		policy.MaxLifetime = viper.GetDuration("sessions." + key + ".max_lifetime")
+
+		if policy.IdleTimeout <= 0 || policy.MaxLifetime <= 0 {
+			return domain.SessionPolicies{}, fmt.Errorf("sessions.%s: idle_timeout and max_lifetime must be set", key)
+		}
+		if policy.IdleTimeout > policy.MaxLifetime {
+			return domain.SessionPolicies{}, fmt.Errorf("sessions.%s: idle_timeout is longer than max_lifetime", key)
+		}
+	}
+	return policies, nil
+}
@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Actions a capability token can grant.
+const (
+	CapabilityActionDownload = "download"
+)
+
+var CapabilityActions = []string{CapabilityActionDownload}
+
+var ErrCapabilityRedeemed = errors.New("capability token has already been redeemed")
+
+// Capability allows one user to perform one action on one stored file, once,
+// e.g. to download a lecture PDF from a direct link.
+type Capability struct {
+	TokenID   uuid.UUID // jti; redeeming the token records it
+	UserID    uuid.UUID
+	FileKey   string // object key in the file store
+	Action    string
+	ExpiresAt time.Time
+}
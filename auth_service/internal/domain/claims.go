@@ -0,0 +1,43 @@
+package domain
+
+import "slices"
+
+// Profile claims an access token may carry besides the identity of its
+// subject. Which of them are added depends on the token's audience.
+const (
+	ClaimRoles         = "roles"
+	ClaimEmail         = "email"
+	ClaimEmailVerified = "email_verified"
+	ClaimLocale        = "locale"
+	ClaimName          = "name"
+)
+
+var ProfileClaimNames = []string{ClaimRoles, ClaimEmail, ClaimEmailVerified, ClaimLocale, ClaimName}
+
+// ProfileClaims are the optional user claims of an access token. Unset fields
+// are left out of the token.
+type ProfileClaims struct {
+	Roles         []string `json:"roles,omitempty"`
+	Email         string   `json:"email,omitempty"`
+	EmailVerified *bool    `json:"email_verified,omitempty"`
+	Locale        string   `json:"locale,omitempty"`
+	Name          string   `json:"name,omitempty"`
+}
+
+// ClaimsPolicy maps an audience to the profile claims of the tokens issued for
+// it. Audiences that are not listed get none.
+type ClaimsPolicy map[string][]string
+
+// For returns the profile claims of a token issued for every audience in
+// audience: the union of what each of them gets.
+func (p ClaimsPolicy) For(audience []string) []string {
+	var claims []string
+	for _, name := range ProfileClaimNames {
+		if slices.ContainsFunc(audience, func(aud string) bool {
+			return slices.Contains(p[aud], name)
+		}) {
+			claims = append(claims, name)
+		}
+	}
+	return claims
+}
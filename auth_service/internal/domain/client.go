@@ -0,0 +1,22 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Client is a registered OAuth2 client, typically another platform service.
+// Scopes lists what the client may request with the client_credentials grant.
+// Public clients (e.g. the browser frontend) have no secret and can only use
+// the authorization code flow with PKCE, redirecting to one of RedirectURIs.
+type Client struct {
+	Id           uuid.UUID      `json:"id" db:"id"`
+	ClientID     string         `json:"client_id" db:"client_id"`
+	SecretHash   string         `json:"-" db:"client_secret_hash"`
+	Name         string         `json:"name" db:"name"`
+	Scopes       pq.StringArray `json:"scopes" db:"scopes"`
+	RedirectURIs pq.StringArray `json:"redirect_uris" db:"redirect_uris"`
+	Public       bool           `json:"public" db:"public"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Device authorization states.
+const (
+	DeviceAuthorizationPending  = "pending"
+	DeviceAuthorizationApproved = "approved"
+	DeviceAuthorizationDenied   = "denied"
+)
+
+// DeviceAuthorization is a pending RFC 8628 device authorization. The device
+// polls with the device code, of which only a SHA-256 hash is stored, while the
+// user approves or denies the short user code on another device.
+type DeviceAuthorization struct {
+	DeviceCodeHash string         `db:"device_code_hash"`
+	UserCode       string         `db:"user_code"`
+	ClientID       string         `db:"client_id"`
+	Scopes         pq.StringArray `db:"scopes"`
+	Status         string         `db:"status"`
+	UserID         *uuid.UUID     `db:"user_id"`   // set once decided
+	AuthTime       *time.Time     `db:"auth_time"` // set once decided
+	LastPolledAt   *time.Time     `db:"last_polled_at"`
+	PollInterval   int            `db:"poll_interval"` // seconds between polls
+	ExpiresAt      time.Time      `db:"expires_at"`
+}
+
+// DeviceCode is the answer to a device authorization request.
+type DeviceCode struct {
+	DeviceCode              string
+	UserCode                string // formatted for display, e.g. WDJB-MJHT
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresAt               time.Time
+	Interval                time.Duration
+}
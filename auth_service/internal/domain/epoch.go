@@ -0,0 +1,20 @@
+package domain
+
+import "github.com/google/uuid"
+
+// TokenEpoch is the "ver" claim of a token: the global and per-user token
+// epochs when it was issued. Incrementing either counter invalidates every
+// token issued before; only tokens carrying the current values are valid.
+// Tokens issued before epochs existed have none
+// and count as epoch zero.
+type TokenEpoch struct {
+	Global int64 `json:"g"`
+	User   int64 `json:"u"`
+}
+
+// TokenEpochs are the current counters. Users holds only the users whose
+// counter was ever incremented; everyone else is at zero.
+type TokenEpochs struct {
+	Global int64
+	Users  map[uuid.UUID]int64
+}
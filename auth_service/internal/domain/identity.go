@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"slices"
+	"time"
+)
+
+// Identity is the caller authenticated by an access token or a personal
+// access token.
+type Identity struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID // uuid.Nil when the token is not bound to a session
+	TokenID   string    // jti of the access token
+	ExpiresAt time.Time
+
+	// PersonalAccessTokenID is set when the caller used a personal access
+	// token, which only grants Scopes.
+	PersonalAccessTokenID uuid.UUID
+	// ClientID is set when the token was issued to an OIDC client; Scopes
+	// then lists the scopes the user granted it.
+	ClientID string
+	Scopes   []string
+}
+
+// IsPersonalAccessToken reports whether the caller used a personal access token.
+func (i Identity) IsPersonalAccessToken() bool {
+	return i.PersonalAccessTokenID != uuid.Nil
+}
+
+// IsClientToken reports whether the token was issued to an OIDC client.
+func (i Identity) IsClientToken() bool {
+	return i.ClientID != ""
+}
+
+// HasScope reports whether the caller may act within scope. Tokens of the
+// user's own login sessions carry the user's full rights; personal access
+// tokens and tokens issued to OIDC clients only their Scopes.
+func (i Identity) HasScope(scope string) bool {
+	if !i.IsPersonalAccessToken() && !i.IsClientToken() {
+		return true
+	}
+	return slices.Contains(i.Scopes, scope)
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// Introspection is the result of checking a token on behalf of a client (RFC 7662).
+type Introspection struct {
+	Active    bool
+	Revoked   bool
+	TokenType string
+	Subject   string
+	ClientID  string
+	Actor     *Actor
+	Issuer    string
+	TokenID   string
+	Scope     string
+	Audience  []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
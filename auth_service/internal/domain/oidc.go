@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+// AuthorizationRequest holds the parameters of an OIDC /authorize request.
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scopes              []string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// AuthorizationCode is a single-use code issued by /authorize and redeemed at
+// the token endpoint. Only a SHA-256 hash of the code is stored.
+type AuthorizationCode struct {
+	CodeHash      string         `db:"code_hash"`
+	ClientID      string         `db:"client_id"`
+	UserID        uuid.UUID      `db:"user_id"`
+	RedirectURI   string         `db:"redirect_uri"`
+	Scopes        pq.StringArray `db:"scopes"`
+	CodeChallenge string         `db:"code_challenge"`
+	Nonce         string         `db:"nonce"`
+	AuthTime      time.Time      `db:"auth_time"`
+	ExpiresAt     time.Time      `db:"expires_at"`
+}
+
+// IDTokenClaims are the claims of an OpenID Connect id_token. Profile and email
+// claims are only filled when the matching scope was granted.
+type IDTokenClaims struct {
+	Issuer   string
+	Subject  string
+	Audience string
+	Nonce    string
+	AuthTime time.Time
+
+	UserInfo
+}
+
+// UserInfo holds the claims about a user an OIDC client was granted, in
+// id_tokens and at the UserInfo endpoint. Claims of scopes that were not
+// granted are empty.
+type UserInfo struct {
+	PreferredUsername string
+	GivenName         string
+	FamilyName        string
+	Name              string
+	Email             string
+}
+
+// TokenSet is the result of redeeming an authorization or device code, or a
+// refresh token of an OIDC client. ExpiresAt is when the access token expires.
+type TokenSet struct {
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	Scopes       []string
+	ExpiresAt    time.Time
+}
+
+// ProviderMetadata is the document served at /.well-known/openid-configuration.
+type ProviderMetadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
@@ -0,0 +1,15 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// PasswordResetToken lets a user who forgot their password set a new one. It
+// is sent by email; only a SHA-256 hash of it is stored.
+type PasswordResetToken struct {
+	TokenHash string    `db:"token_hash"`
+	UserID    uuid.UUID `db:"user_id"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
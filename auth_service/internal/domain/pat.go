@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Scopes a personal access token may carry. Tokens obtained by logging in are
+// not limited by scopes.
+const (
+	ScopeProfileRead   = "profile:read"
+	ScopeLecturesRead  = "lectures:read"
+	ScopeLecturesWrite = "lectures:write"
+	ScopeMessagesWrite = "messages:write"
+)
+
+var PersonalAccessTokenScopes = []string{
+	ScopeProfileRead,
+	ScopeLecturesRead,
+	ScopeLecturesWrite,
+	ScopeMessagesWrite,
+}
+
+// PersonalAccessToken is a long-lived token a user creates for scripts. Only
+// the SHA-256 hash of the token is kept; the token itself is shown once.
+type PersonalAccessToken struct {
+	Id         uuid.UUID      `json:"id" db:"id"`
+	UserID     uuid.UUID      `json:"user_id" db:"user_id"`
+	Name       string         `json:"name" db:"name"`
+	TokenHash  string         `json:"-" db:"token_hash"`
+	Scopes     pq.StringArray `json:"scopes" db:"scopes"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty" db:"expires_at"` // nil: never expires
+	LastUsedAt *time.Time     `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time     `json:"revoked_at,omitempty" db:"revoked_at"`
+}
@@ -0,0 +1,22 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+	SecurityEventTokensRevoked     = "tokens_revoked"
+	SecurityEventPasswordReset     = "password_reset"
+	SecurityEventPasswordChanged   = "password_changed"
+)
+
+// SecurityEvent is an audit record for suspicious activity on an account.
+type SecurityEvent struct {
+	Id        uuid.UUID         `json:"id" db:"id"`
+	UserID    uuid.UUID         `json:"user_id" db:"user_id"`
+	Type      string            `json:"type" db:"event_type"`
+	Details   map[string]string `json:"details" db:"-"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+}
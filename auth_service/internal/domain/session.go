@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"slices"
+	"time"
+)
+
+// Session is one logged-in device. Its id doubles as the refresh token family id.
+// Only the SHA-256 hash of the current refresh token is kept.
+//
+// The refresh token expires after the idle timeout of the session policy and
+// every refresh pushes that back, but never past ExpiresAt, the absolute end
+// of the session.
+type Session struct {
+	Id               uuid.UUID  `json:"id" db:"id"`
+	UserID           uuid.UUID  `json:"user_id" db:"user_id"`
+	RefreshTokenHash *string    `json:"-" db:"refresh_token_hash"` // NULL once revoked
+	RefreshExpiresAt *time.Time `json:"-" db:"refresh_expires_at"`
+	RememberMe       bool       `json:"remember_me" db:"remember_me"`
+	UserAgent        string     `json:"user_agent" db:"user_agent"`
+	IP               string     `json:"ip" db:"ip"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt       time.Time  `json:"last_used_at" db:"last_used_at"`
+	ExpiresAt        time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+
+	// ClientID and Scopes are set on sessions opened for an OIDC client.
+	ClientID string         `json:"client_id,omitempty" db:"client_id"`
+	Scopes   pq.StringArray `json:"scopes,omitempty" db:"scopes"`
+}
+
+// Grant is what a user consented to give an OIDC client: the access tokens of
+// the session only reveal the claims of the granted scopes. The zero Grant is
+// a first-party login with the user's full rights.
+type Grant struct {
+	ClientID string
+	Scopes   []string
+}
+
+// Grant returns the grant the session was opened with.
+func (s Session) Grant() Grant {
+	return Grant{ClientID: s.ClientID, Scopes: s.Scopes}
+}
+
+// Scopes an OIDC client may be granted.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// Filter drops the profile claims the grant's scopes do not cover. profile
+// covers the name and locale, email the address and whether it is verified;
+// roles are never given to OIDC clients.
+func (g Grant) Filter(claims ProfileClaims) ProfileClaims {
+	if g.ClientID == "" {
+		return claims
+	}
+	var filtered ProfileClaims
+	if slices.Contains(g.Scopes, ScopeProfile) {
+		filtered.Name = claims.Name
+		filtered.Locale = claims.Locale
+	}
+	if slices.Contains(g.Scopes, ScopeEmail) {
+		filtered.Email = claims.Email
+		filtered.EmailVerified = claims.EmailVerified
+	}
+	return filtered
+}
+
+// SessionPolicy bounds the lifetime of a session. IdleTimeout is how long a
+// refresh token stays valid without being used; MaxLifetime is how long the
+// session lasts at most, however active it is.
+type SessionPolicy struct {
+	IdleTimeout time.Duration
+	MaxLifetime time.Duration
+}
+
+// RefreshExpiry returns when a refresh token issued at now expires for a
+// session that ends at sessionEnd.
+func (p SessionPolicy) RefreshExpiry(now, sessionEnd time.Time) time.Time {
+	expiry := now.Add(p.IdleTimeout)
+	if expiry.After(sessionEnd) {
+		return sessionEnd
+	}
+	return expiry
+}
+
+// SessionPolicies holds the policy of ordinary browser sessions and the longer
+// one used when the user asks to be remembered.
+type SessionPolicies struct {
+	Default    SessionPolicy
+	RememberMe SessionPolicy
+}
+
+func (p SessionPolicies) For(rememberMe bool) SessionPolicy {
+	if rememberMe {
+		return p.RememberMe
+	}
+	return p.Default
+}
+
+// ClientInfo describes the device a request came from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Ticket authenticates a single WebSocket or SSE connection, for which browsers
+// cannot send an Authorization header. Only a SHA-256 hash of the ticket is
+// stored.
+type Ticket struct {
+	TicketHash string    `db:"ticket_hash"`
+	UserID     uuid.UUID `db:"user_id"`
+	Channel    string    `db:"channel"` // the stream the ticket opens, e.g. chat:<conversation id>
+	CreatedAt  time.Time `db:"created_at"`
+	ExpiresAt  time.Time `db:"expires_at"`
+}
@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+var (
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	ErrTokenRevoked       = errors.New("token has been revoked")
+	ErrInvalidAudience    = errors.New("invalid token audience")
+)
+
+// Subject types of access tokens. User tokens are issued at login; client
+// tokens are issued to services through the client_credentials grant.
+const (
+	SubjectTypeUser   = "user"
+	SubjectTypeClient = "client"
+)
+
+// Actor is the "act" claim of RFC 8693: the party acting on behalf of the
+// token's subject. Actor is set when that party itself acted for another one.
+type Actor struct {
+	Subject string `json:"sub"`
+	Actor   *Actor `json:"act,omitempty"`
+}
+
+// AccessTokenClaims are the parts of a verified access token the service needs.
+type AccessTokenClaims struct {
+	Subject     string // user id or client_id, depending on SubjectType
+	SubjectType string
+	UserID      string // empty for client tokens
+	ClientID    string // empty for user tokens
+	SessionID   string // empty for tokens issued before sessions existed
+	TokenID     string // jti
+	Actor       *Actor // set on tokens obtained by token exchange
+	Profile     ProfileClaims
+	Scopes      []string
+	Audience    []string
+	Issuer      string
+	IssuedAt    time.Time
+	ExpiresAt   time.Time
+}
+
+// IssuedToken is an access token issued to a service at the token endpoint,
+// through the client_credentials or token exchange grants.
+type IssuedToken struct {
+	AccessToken string
+	Scopes      []string
+	ExpiresAt   time.Time
+}
+
+// RefreshToken is a newly issued opaque refresh token. Token goes to the client;
+// only Hash is persisted. Its lifetime is set by the session policy.
+type RefreshToken struct {
+	Token string
+	Hash  string
+}
+
+// RefreshTokenClaims are the parts of a verified JWT refresh token, as issued
+// before refresh tokens became opaque.
+type RefreshTokenClaims struct {
+	UserID    string
+	TokenID   string // jti
+	FamilyID  string // session id, shared by every token rotated from the same login
+	ExpiresAt time.Time
+}
+
+// UsedRefreshToken records, by hash, a refresh token that has already been
+// rotated. Presenting it again means the token was copied and the whole family
+// is revoked.
+type UsedRefreshToken struct {
+	TokenHash string    `db:"token_hash"`
+	FamilyID  uuid.UUID `db:"family_id"`
+	UserID    uuid.UUID `db:"user_id"`
+	UsedAt    time.Time `db:"used_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// RevokedToken is a denylist entry: the jti of a single access token, or a
+// session id revoking every access token issued for that session.
+type RevokedToken struct {
+	ID        uuid.UUID `db:"id"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
@@ -2,17 +2,45 @@ package domain
 
 import (
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"strings"
 	"time"
 )
 
+// Roles a user can have.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // User represents an application user.
 type User struct {
-	Id           uuid.UUID `json:"id" db:"id"`
-	Username     string    `json:"username" db:"username"`
-	Email        string    `json:"email" db:"email"`
-	FirstName    string    `json:"first_name" db:"first_name"`
-	LastName     string    `json:"last_name" db:"last_name"`
-	Password     string    `json:"-" db:"password_hash"` // hide in JSON
-	RefreshToken *string   `json:"-" db:"refresh_token"` // nullable
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	Id        uuid.UUID `json:"id" db:"id"`
+	Username  string    `json:"username" db:"username"`
+	Email     string    `json:"email" db:"email"`
+	FirstName string    `json:"first_name" db:"first_name"`
+	LastName  string    `json:"last_name" db:"last_name"`
+	Password  string    `json:"-" db:"password_hash"` // hide in JSON
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"` // changes with every profile edit
+
+	Roles         pq.StringArray `json:"roles" db:"roles"`
+	EmailVerified bool           `json:"email_verified" db:"email_verified"`
+	Locale        string         `json:"locale" db:"locale"` // BCP 47 language tag, empty when unknown
+}
+
+// ProfileUpdate holds the profile fields a user may edit. Nil fields are left
+// unchanged.
+type ProfileUpdate struct {
+	FirstName *string
+	LastName  *string
+	Locale    *string
+}
+
+// DisplayName is the user's full name, or the username when no name is set.
+func (u User) DisplayName() string {
+	if name := strings.TrimSpace(u.FirstName + " " + u.LastName); name != "" {
+		return name
+	}
+	return u.Username
 }
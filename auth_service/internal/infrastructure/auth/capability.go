@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"time"
+)
+
+// NewCapabilityToken signs a token granting capability. Capability tokens have
+// their own type, so they are never accepted as access tokens, and carry no
+// audience: only auth_service verifies them, when they are redeemed.
+func (m *TokenManager) NewCapabilityToken(capability domain.Capability) (string, error) {
+	claims := m.newClaims(capability.UserID.String(), capabilityTokenType, time.Until(capability.ExpiresAt))
+	claims.ID = capability.TokenID.String()
+	claims.ExpiresAt = jwt.NewNumericDate(capability.ExpiresAt)
+	claims.SubjectType = domain.SubjectTypeUser
+	claims.FileKey = capability.FileKey
+	claims.Action = capability.Action
+	return sign(claims, m.accessKeys.Active())
+}
+
+// ParseCapabilityToken verifies the signature and expiry of a capability token.
+// Whether it was already redeemed is up to the caller. Capability tokens get no
+// leeway: redemption records are dropped once a token expires, so a token
+// accepted past its exp could be redeemed a second time.
+func (m *TokenManager) ParseCapabilityToken(ctx context.Context, token string) (domain.Capability, error) {
+	claims, err := m.parseWithLeeway(token, capabilityTokenType, m.accessKeys, 0)
+	if err != nil {
+		return domain.Capability{}, err
+	}
+	if m.epochs.IsStale(ctx, parseUserID(claims.UserID), claims.Version) {
+		return domain.Capability{}, domain.ErrTokenRevoked
+	}
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return domain.Capability{}, err
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return domain.Capability{}, err
+	}
+	return domain.Capability{
+		TokenID:   tokenID,
+		UserID:    userID,
+		FileKey:   claims.FileKey,
+		Action:    claims.Action,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"context"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type DenylistStore interface {
+	RevokeToken(ctx context.Context, id uuid.UUID, expiresAt time.Time) error
+	ListRevokedTokens(ctx context.Context) ([]domain.RevokedToken, error)
+	DeleteExpiredRevocations(ctx context.Context) (int64, error)
+}
+
+// Denylist keeps revoked token and session ids in memory in front of the
+// Postgres store, so checking a token never hits the database. Revocations made
+// by this instance apply immediately; the ones made by other instances are
+// picked up on the next sync.
+type Denylist struct {
+	store DenylistStore
+	log   *logger.SlogLogger
+
+	mu      sync.RWMutex
+	revoked map[uuid.UUID]time.Time
+}
+
+func NewDenylist(store DenylistStore, log *logger.SlogLogger) *Denylist {
+	return &Denylist{
+		store:   store,
+		log:     log,
+		revoked: map[uuid.UUID]time.Time{},
+	}
+}
+
+// Revoke denies id until expiresAt, the moment the last token it covers expires.
+func (d *Denylist) Revoke(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	if err := d.store.RevokeToken(ctx, id, expiresAt); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	if expiresAt.After(d.revoked[id]) {
+		d.revoked[id] = expiresAt
+	}
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *Denylist) IsRevoked(id uuid.UUID) bool {
+	d.mu.RLock()
+	expiresAt, ok := d.revoked[id]
+	d.mu.RUnlock()
+	return ok && time.Now().Before(expiresAt)
+}
+
+// Sync merges the live entries from the store into the cache and drops the
+// expired ones. Entries are never un-revoked, so merging cannot lose a
+// revocation made while the store was being read.
+func (d *Denylist) Sync(ctx context.Context) error {
+	entries, err := d.store.ListRevokedTokens(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, e := range entries {
+		if e.ExpiresAt.After(d.revoked[e.ID]) {
+			d.revoked[e.ID] = e.ExpiresAt
+		}
+	}
+	for id, expiresAt := range d.revoked {
+		if !now.Before(expiresAt) {
+			delete(d.revoked, id)
+		}
+	}
+	return nil
+}
+
+// Run syncs the cache and deletes expired entries every interval until ctx is done.
+func (d *Denylist) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := d.store.DeleteExpiredRevocations(ctx); err != nil {
+				d.log.Error(ctx, "denylist: delete expired error", "error", err)
+			} else if n > 0 {
+				d.log.Debug(ctx, "denylist: expired entries removed", "count", n)
+			}
+			if err := d.Sync(ctx); err != nil {
+				d.log.Error(ctx, "denylist: sync error", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestDenylistRevoke(t *testing.T) {
+	ctx := context.Background()
+	store := newMemDenylistStore()
+	d := NewDenylist(store, testLog)
+	revoked, expired, other := uuid.New(), uuid.New(), uuid.New()
+
+	if err := d.Revoke(ctx, revoked, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Revoke(ctx, expired, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.IsRevoked(revoked) {
+		t.Error("revoked id is not denied")
+	}
+	if d.IsRevoked(expired) {
+		t.Error("id is still denied after its tokens expired")
+	}
+	if d.IsRevoked(other) {
+		t.Error("id that was never revoked is denied")
+	}
+
+	// A shorter expiry must not shorten an existing revocation.
+	if err := d.Revoke(ctx, revoked, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if !d.IsRevoked(revoked) {
+		t.Error("revocation shortened by a later, shorter one")
+	}
+}
+
+// A revocation the store did not record is not applied locally either, so
+// instances cannot disagree about it.
+func TestDenylistRevokeStoreError(t *testing.T) {
+	store := newMemDenylistStore()
+	store.err = errors.New("connection refused")
+	d := NewDenylist(store, testLog)
+	id := uuid.New()
+
+	if err := d.Revoke(context.Background(), id, time.Now().Add(time.Minute)); !errors.Is(err, store.err) {
+		t.Fatalf("Revoke() error = %v, want %v", err, store.err)
+	}
+	if d.IsRevoked(id) {
+		t.Error("id denied although the store failed")
+	}
+}
+
+// Revocations made by one instance reach the others on their next sync.
+func TestDenylistSyncAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	store := newMemDenylistStore()
+	a, b := NewDenylist(store, testLog), NewDenylist(store, testLog)
+	id := uuid.New()
+
+	if err := a.Revoke(ctx, id, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if b.IsRevoked(id) {
+		t.Fatal("revocation seen by the other instance before it synced")
+	}
+	if err := b.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !b.IsRevoked(id) {
+		t.Error("revocation not seen by the other instance after it synced")
+	}
+
+	// A failed sync keeps what the instance already knows.
+	store.err = errors.New("connection refused")
+	if err := b.Sync(ctx); err == nil {
+		t.Error("Sync() succeeded with a failing store")
+	}
+	if !b.IsRevoked(id) {
+		t.Error("revocation lost by a failed sync")
+	}
+}
+
+func TestDenylistDropsExpiredEntries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := newMemDenylistStore()
+	d := NewDenylist(store, testLog)
+
+	if err := d.Revoke(ctx, uuid.New(), time.Now().Add(50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	live := uuid.New()
+	if err := d.Revoke(ctx, live, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	go d.Run(ctx, 10*time.Millisecond)
+	deadline := time.Now().Add(2 * time.Second)
+	for store.len() != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := store.len(); n != 1 {
+		t.Fatalf("%d entries in the store, want only the live one", n)
+	}
+
+	cancel()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if _, ok := d.revoked[live]; !ok || len(d.revoked) != 1 {
+		t.Errorf("cache = %v, want only the live entry", d.revoked)
+	}
+}
+
+func TestParseAccessTokenDenylisted(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, newMemDenylistStore(), newMemEpochStore())
+	userID := uuid.NewString()
+	sessionID, otherSessionID := uuid.NewString(), uuid.NewString()
+
+	token, err := m.NewAccessToken(userID, sessionID, nil, domain.ProfileClaims{}, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameSession, err := m.NewAccessToken(userID, sessionID, nil, domain.ProfileClaims{}, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSession, err := m.NewAccessToken(userID, otherSessionID, nil, domain.ProfileClaims{}, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := m.ParseAccessToken(ctx, token, []string{"auth-service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RevokeAccessToken(ctx, claims.TokenID, claims.ExpiresAt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ParseAccessToken(ctx, token, []string{"auth-service"}); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("revoked token: error = %v, want %v", err, domain.ErrTokenRevoked)
+	}
+	if _, err := m.ParseAccessToken(ctx, sameSession, []string{"auth-service"}); err != nil {
+		t.Errorf("other token of the session: %v", err)
+	}
+
+	if err := m.RevokeSessionTokens(ctx, sessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ParseAccessToken(ctx, sameSession, []string{"auth-service"}); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("token of a revoked session: error = %v, want %v", err, domain.ErrTokenRevoked)
+	}
+	if _, err := m.ParseAccessToken(ctx, otherSession, []string{"auth-service"}); err != nil {
+		t.Errorf("token of another session: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"context"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type EpochStore interface {
+	GetTokenEpochs(ctx context.Context) (domain.TokenEpochs, error)
+	IncrementGlobalTokenEpoch(ctx context.Context) (int64, error)
+	IncrementUserTokenEpoch(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+// Epochs keeps the token epochs in memory in front of the Postgres store, like
+// the Denylist. Increments made by this instance apply immediately; the ones
+// made by other instances or the CLI are picked up on the next sync.
+type Epochs struct {
+	store EpochStore
+	log   *logger.SlogLogger
+
+	mu     sync.RWMutex
+	global int64
+	users  map[uuid.UUID]int64
+}
+
+func NewEpochs(store EpochStore, log *logger.SlogLogger) *Epochs {
+	return &Epochs{
+		store: store,
+		log:   log,
+		users: map[uuid.UUID]int64{},
+	}
+}
+
+// Current returns the epoch a token issued for userID now carries. Client
+// tokens pass uuid.Nil and only get the global epoch.
+func (e *Epochs) Current(userID uuid.UUID) domain.TokenEpoch {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return domain.TokenEpoch{Global: e.global, User: e.users[userID]}
+}
+
+// IsStale reports whether a token of userID issued under ver is not valid
+// under the current epochs: it was invalidated by an increment since, or
+// carries an epoch that was never issued. A ver ahead of the cache may come
+// from another instance that saw an increment first, so the cache is synced
+// once before such a token is rejected.
+func (e *Epochs) IsStale(ctx context.Context, userID uuid.UUID, ver domain.TokenEpoch) bool {
+	current := e.Current(userID)
+	if ver == current {
+		return false
+	}
+	if ver.Global < current.Global || ver.User < current.User {
+		return true
+	}
+
+	if err := e.Sync(ctx); err != nil {
+		e.log.Error(ctx, "token epochs: sync error", "error", err)
+		return true
+	}
+	return ver != e.Current(userID)
+}
+
+// IncrementGlobal invalidates every token and returns the new global epoch.
+func (e *Epochs) IncrementGlobal(ctx context.Context) (int64, error) {
+	epoch, err := e.store.IncrementGlobalTokenEpoch(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	e.mu.Lock()
+	e.global = max(e.global, epoch)
+	e.mu.Unlock()
+	return epoch, nil
+}
+
+// IncrementUser invalidates every token of the user and returns the user's
+// new epoch.
+func (e *Epochs) IncrementUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	epoch, err := e.store.IncrementUserTokenEpoch(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	e.mu.Lock()
+	e.users[userID] = max(e.users[userID], epoch)
+	e.mu.Unlock()
+	return epoch, nil
+}
+
+// Sync merges the counters from the store into the cache. Counters only go
+// up, so keeping the higher value cannot lose an increment made while the
+// store was being read.
+func (e *Epochs) Sync(ctx context.Context) error {
+	epochs, err := e.store.GetTokenEpochs(ctx)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.global = max(e.global, epochs.Global)
+	for id, epoch := range epochs.Users {
+		e.users[id] = max(e.users[id], epoch)
+	}
+	return nil
+}
+
+// Run syncs the cache every interval until ctx is done.
+func (e *Epochs) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Sync(ctx); err != nil {
+				e.log.Error(ctx, "token epochs: sync error", "error", err)
+			}
+		}
+	}
+}
+
+// parseUserID returns uuid.Nil for client tokens, which have no user.
+func parseUserID(userID string) uuid.UUID {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestEpochsIsStale(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name string
+		// cached is what this instance has seen, stored what the database
+		// holds after increments made elsewhere.
+		cached, stored domain.TokenEpoch
+		ver            domain.TokenEpoch
+		want           bool
+		wantSync       bool
+	}{
+		{name: "current", cached: domain.TokenEpoch{Global: 1, User: 2}, stored: domain.TokenEpoch{Global: 1, User: 2}, ver: domain.TokenEpoch{Global: 1, User: 2}},
+		{name: "behind global", cached: domain.TokenEpoch{Global: 1}, stored: domain.TokenEpoch{Global: 1}, ver: domain.TokenEpoch{}, want: true},
+		{name: "behind user", cached: domain.TokenEpoch{User: 2}, stored: domain.TokenEpoch{User: 2}, ver: domain.TokenEpoch{User: 1}, want: true},
+		{name: "behind one, ahead other", cached: domain.TokenEpoch{Global: 1}, stored: domain.TokenEpoch{Global: 1}, ver: domain.TokenEpoch{User: 5}, want: true},
+		{name: "ahead of the cache only", cached: domain.TokenEpoch{}, stored: domain.TokenEpoch{User: 1}, ver: domain.TokenEpoch{User: 1}, wantSync: true},
+		{name: "ahead of the database", cached: domain.TokenEpoch{}, stored: domain.TokenEpoch{}, ver: domain.TokenEpoch{Global: 3}, want: true, wantSync: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemEpochStore()
+			store.global, store.users[userID] = tt.cached.Global, tt.cached.User
+			epochs := NewEpochs(store, testLog)
+			if err := epochs.Sync(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			store.global, store.users[userID] = tt.stored.Global, tt.stored.User
+			reads := store.reads
+
+			if got := epochs.IsStale(context.Background(), userID, tt.ver); got != tt.want {
+				t.Errorf("IsStale(%+v) = %v, want %v", tt.ver, got, tt.want)
+			}
+			if synced := store.reads > reads; synced != tt.wantSync {
+				t.Errorf("synced = %v, want %v", synced, tt.wantSync)
+			}
+		})
+	}
+}
+
+func TestParseAccessTokenEpochs(t *testing.T) {
+	ctx := context.Background()
+	store := newMemEpochStore()
+	m := newTestManager(t, newMemDenylistStore(), store)
+	alice, bob := uuid.New(), uuid.New()
+
+	aliceToken, err := m.NewAccessToken(alice.String(), "", nil, domain.ProfileClaims{}, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobToken, err := m.NewAccessToken(bob.String(), "", nil, domain.ProfileClaims{}, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.RevokeUserTokens(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ParseAccessToken(ctx, aliceToken, []string{"auth-service"}); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("token issued before the user increment: error = %v, want %v", err, domain.ErrTokenRevoked)
+	}
+	if _, err := m.ParseAccessToken(ctx, bobToken, []string{"auth-service"}); err != nil {
+		t.Errorf("other user's token: error = %v, want nil", err)
+	}
+
+	aliceToken, err = m.NewAccessToken(alice.String(), "", nil, domain.ProfileClaims{}, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ParseAccessToken(ctx, aliceToken, []string{"auth-service"}); err != nil {
+		t.Errorf("token issued after the user increment: error = %v, want nil", err)
+	}
+
+	if _, err := m.RevokeAllTokens(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{"alice": aliceToken, "bob": bobToken} {
+		if _, err := m.ParseAccessToken(ctx, token, []string{"auth-service"}); !errors.Is(err, domain.ErrTokenRevoked) {
+			t.Errorf("%s's token after the global increment: error = %v, want %v", name, err, domain.ErrTokenRevoked)
+		}
+	}
+}
+
+// A token meant for another service is not addressed to the caller, so it is
+// reported as such and says nothing about its revocation.
+func TestParseAccessTokenChecksAudienceBeforeEpoch(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, newMemDenylistStore(), newMemEpochStore())
+	userID := uuid.New()
+
+	token, err := m.NewAccessToken(userID.String(), "", []string{"content-service"}, domain.ProfileClaims{}, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.RevokeUserTokens(ctx, userID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.ParseAccessToken(ctx, token, []string{"auth-service"}); !errors.Is(err, domain.ErrInvalidAudience) {
+		t.Errorf("error = %v, want %v", err, domain.ErrInvalidAudience)
+	}
+	if _, err := m.ParseAccessToken(ctx, token, []string{"content-service"}); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("error = %v, want %v", err, domain.ErrTokenRevoked)
+	}
+}
+
+// Instances share the epoch table but cache it: a token issued by an instance
+// that saw an increment first is accepted everywhere, and the tokens the
+// increment invalidated are rejected everywhere.
+func TestParseAccessTokenAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	store := newMemEpochStore()
+	a, b := newTestManager(t, newMemDenylistStore(), store), newTestManager(t, newMemDenylistStore(), store)
+	userID := uuid.New()
+
+	before, err := a.NewAccessToken(userID.String(), "", nil, domain.ProfileClaims{}, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.RevokeUserTokens(ctx, userID); err != nil {
+		t.Fatal(err)
+	}
+	after, err := a.NewAccessToken(userID.String(), "", nil, domain.ProfileClaims{}, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.ParseAccessToken(ctx, after, []string{"auth-service"}); err != nil {
+		t.Errorf("new token on the lagging instance: error = %v, want nil", err)
+	}
+	if _, err := b.ParseAccessToken(ctx, before, []string{"auth-service"}); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("old token on the lagging instance: error = %v, want %v", err, domain.ErrTokenRevoked)
+	}
+}
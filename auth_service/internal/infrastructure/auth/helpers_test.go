@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"context"
+	"github.com/google/uuid"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testLog = logger.New("test")
+
+func testPolicy() TokenPolicy {
+	return TokenPolicy{
+		Issuer:         "auth-service",
+		AccessTTL:      30 * time.Minute,
+		DelegatedTTL:   5 * time.Minute,
+		RefreshTTL:     168 * time.Hour,
+		Leeway:         30 * time.Second,
+		NotBefore:      true,
+		RequiredClaims: []string{"exp", "iat", "iss", "jti"},
+		Audiences: AudiencePolicy{
+			Allowed:  []string{"auth-service", "content-service"},
+			Default:  []string{"auth-service"},
+			Accepted: []string{"auth-service"},
+		},
+	}
+}
+
+// newTestManager returns a token manager signing with HMAC keys, backed by the
+// given denylist and epoch stores.
+func newTestManager(t *testing.T, revocations *memDenylistStore, epochs *memEpochStore) *TokenManager {
+	t.Helper()
+	accessKeys, err := NewKeyring(mustHMACKey(t, "test-access-secret-0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshKeys, err := NewKeyring(mustHMACKey(t, "test-refresh-secret-0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewTokenManager(accessKeys, refreshKeys, NewDenylist(revocations, testLog), NewEpochs(epochs, testLog), testPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func mustHMACKey(t *testing.T, secret string) *SigningKey {
+	t.Helper()
+	key, err := NewHMACKey(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// memDenylistStore is the revoked_tokens table of the database shared by
+// every instance.
+type memDenylistStore struct {
+	mu      sync.Mutex
+	revoked map[uuid.UUID]time.Time
+	err     error // returned by every call when set
+}
+
+func newMemDenylistStore() *memDenylistStore {
+	return &memDenylistStore{revoked: map[uuid.UUID]time.Time{}}
+}
+
+func (s *memDenylistStore) RevokeToken(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if expiresAt.After(s.revoked[id]) {
+		s.revoked[id] = expiresAt
+	}
+	return nil
+}
+
+func (s *memDenylistStore) ListRevokedTokens(ctx context.Context) ([]domain.RevokedToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	var revoked []domain.RevokedToken
+	for id, expiresAt := range s.revoked {
+		if expiresAt.After(time.Now()) {
+			revoked = append(revoked, domain.RevokedToken{ID: id, ExpiresAt: expiresAt})
+		}
+	}
+	return revoked, nil
+}
+
+func (s *memDenylistStore) DeleteExpiredRevocations(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return 0, s.err
+	}
+	var n int64
+	for id, expiresAt := range s.revoked {
+		if !expiresAt.After(time.Now()) {
+			delete(s.revoked, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memDenylistStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.revoked)
+}
+
+// memEpochStore is the epoch table of the database shared by every instance.
+type memEpochStore struct {
+	mu     sync.Mutex
+	global int64
+	users  map[uuid.UUID]int64
+	reads  int
+}
+
+func newMemEpochStore() *memEpochStore {
+	return &memEpochStore{users: map[uuid.UUID]int64{}}
+}
+
+func (s *memEpochStore) GetTokenEpochs(ctx context.Context) (domain.TokenEpochs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reads++
+	users := map[uuid.UUID]int64{}
+	for id, epoch := range s.users {
+		users[id] = epoch
+	}
+	return domain.TokenEpochs{Global: s.global, Users: users}, nil
+}
+
+func (s *memEpochStore) IncrementGlobalTokenEpoch(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.global++
+	return s.global, nil
+}
+
+func (s *memEpochStore) IncrementUserTokenEpoch(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[userID]++
+	return s.users[userID], nil
+}
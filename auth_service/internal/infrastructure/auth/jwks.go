@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// JWKS returns the public part of every access token key that can still verify
+// a live token, so that other services can check access tokens offline across
+// a rotation. Symmetric keys are never published.
+func (m *TokenManager) JWKS() domain.JWKSet {
+	set := domain.JWKSet{Keys: []domain.JWK{}}
+	for _, key := range m.accessKeys.Keys() {
+		if jwk, ok := publicJWK(key); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+func publicJWK(key *SigningKey) (domain.JWK, bool) {
+	switch pub := key.verify.(type) {
+	case *rsa.PublicKey:
+		return domain.JWK{
+			Kty: "RSA",
+			Kid: key.KID(),
+			Use: "sig",
+			Alg: key.Algorithm(),
+			N:   b64(pub.N.Bytes()),
+			E:   b64(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return domain.JWK{
+			Kty: "OKP",
+			Kid: key.KID(),
+			Use: "sig",
+			Alg: key.Algorithm(),
+			Crv: "Ed25519",
+			X:   b64(pub),
+		}, true
+	default:
+		return domain.JWK{}, false
+	}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -1,119 +1,438 @@
 package auth
 
 import (
+	"auth_service/internal/domain"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
 	"time"
 )
 
 const (
-	accessTTL  = 30 * time.Minute
-	refreshTTL = 7 * 24 * time.Hour
-
-	accessTokenType  = "access"
-	refreshTokenType = "refresh"
-
-	tokenIssuer = "auth-service"
+	accessTokenType     = "access"
+	refreshTokenType    = "refresh"
+	capabilityTokenType = "capability"
 )
 
 type TokenManager struct {
-	accessKey  []byte
-	refreshKey []byte
+	accessKeys  *Keyring
+	refreshKeys *Keyring
+	denylist    *Denylist
+	epochs      *Epochs
+	policy      TokenPolicy
 }
 
-func NewTokenManager(accessKey, refreshKey string) *TokenManager {
+// NewTokenManager builds a manager from the access and refresh keyrings.
+// Access tokens are usually signed with an RSA or Ed25519 key so that other
+// services can verify them through the JWKS; refresh tokens never leave
+// auth_service and may keep using an HMAC secret. Access tokens whose jti or
+// sid is on the denylist, or whose epoch is behind the current one, are
+// rejected. An unsafe policy, or an access key the policy does not allow, is an
+// error.
+func NewTokenManager(accessKeys, refreshKeys *Keyring, denylist *Denylist, epochs *Epochs, policy TokenPolicy) (*TokenManager, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := policy.checkKey(accessKeys.Active()); err != nil {
+		return nil, err
+	}
 	return &TokenManager{
-		accessKey:  []byte(accessKey),
-		refreshKey: []byte(refreshKey),
+		accessKeys:  accessKeys,
+		refreshKeys: refreshKeys,
+		denylist:    denylist,
+		epochs:      epochs,
+		policy:      policy,
+	}, nil
+}
+
+// RotateKeys switches access and refresh token signing to the given keys. Both
+// are checked before either is switched, so a rotation never stops halfway.
+// The previous keys keep verifying until every token they signed has expired.
+func (m *TokenManager) RotateKeys(access, refresh *SigningKey) error {
+	if err := m.policy.checkKey(access); err != nil {
+		return err
 	}
+	if !access.CanSign() || !refresh.CanSign() {
+		return errors.New("active key must hold private material")
+	}
+	if err := m.accessKeys.Rotate(access, m.accessLifetime()); err != nil {
+		return err
+	}
+	return m.refreshKeys.Rotate(refresh, m.policy.RefreshTTL+m.policy.Leeway)
+}
+
+// AddAccessVerifyKey accepts tokens signed by a key retired before the
+// process started, for as long as such tokens can still be valid.
+func (m *TokenManager) AddAccessVerifyKey(key *SigningKey) {
+	m.accessKeys.AddVerifyKey(key, time.Now().Add(m.accessLifetime()))
+}
+
+func (m *TokenManager) AddRefreshVerifyKey(key *SigningKey) {
+	m.refreshKeys.AddVerifyKey(key, time.Now().Add(m.policy.RefreshTTL+m.policy.Leeway))
+}
+
+// accessLifetime is how long an access token can be accepted after it was
+// issued, clock skew included.
+func (m *TokenManager) accessLifetime() time.Duration {
+	return m.policy.AccessTTL + m.policy.Leeway
 }
 
 type Claims struct {
 	jwt.RegisteredClaims
 	UserID string `json:"user_id"`
 	Type   string `json:"type"`
+
+	// SessionID binds an access token to the session (device) it was issued for.
+	SessionID string `json:"sid,omitempty"`
+
+	// FamilyID groups the refresh tokens produced by rotating a single login.
+	FamilyID string `json:"fam,omitempty"`
+
+	// SubjectType tells user tokens from client (service) tokens. Tokens
+	// issued before it existed have none and belong to users.
+	SubjectType string `json:"sub_type,omitempty"`
+
+	// ClientID and Scope are set on client tokens (RFC 9068), and on user
+	// tokens issued to an OIDC client.
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+
+	// Actor names the service acting on behalf of the subject (RFC 8693).
+	Actor *domain.Actor `json:"act,omitempty"`
+
+	// Profile claims of user tokens, as picked for the audience.
+	domain.ProfileClaims
+
+	// Version is the token epoch the token was issued under.
+	Version domain.TokenEpoch `json:"ver"`
+
+	// FileKey and Action are set on capability tokens.
+	FileKey string `json:"file_key,omitempty"`
+	Action  string `json:"action,omitempty"`
 }
 
 //////////////////////
 // TOKEN GENERATION //
 //////////////////////
 
-func (m *TokenManager) NewAccessToken(userID string) (string, error) {
-	return m.newToken(userID, accessTokenType, accessTTL, m.accessKey)
+// NewAccessToken issues an access token for the given audiences, or for the
+// default audience when none is requested. Every audience must be allowed.
+// Tokens of a session opened for an OIDC client carry its client_id and the
+// granted scopes.
+func (m *TokenManager) NewAccessToken(userID, sessionID string, audience []string, profile domain.ProfileClaims, grant domain.Grant) (string, error) {
+	audience, err := m.ResolveAudience(audience)
+	if err != nil {
+		return "", err
+	}
+
+	claims := m.newClaims(userID, accessTokenType, m.policy.AccessTTL)
+	claims.SessionID = sessionID
+	claims.SubjectType = domain.SubjectTypeUser
+	claims.Audience = audience
+	claims.ProfileClaims = profile
+	claims.ClientID = grant.ClientID
+	claims.Scope = strings.Join(grant.Scopes, " ")
+	return sign(claims, m.accessKeys.Active())
+}
+
+// NewClientToken issues an access token to a service authenticated with the
+// client_credentials grant. Its subject is the client_id; it has no user and
+// no session. Returns the token and its expiry.
+func (m *TokenManager) NewClientToken(clientID string, scopes, audience []string) (string, time.Time, error) {
+	audience, err := m.ResolveAudience(audience)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	claims := m.newClaims("", accessTokenType, m.policy.AccessTTL)
+	claims.Subject = clientID
+	claims.SubjectType = domain.SubjectTypeClient
+	claims.ClientID = clientID
+	claims.Scope = strings.Join(scopes, " ")
+	claims.Audience = audience
+
+	token, err := sign(claims, m.accessKeys.Active())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, claims.ExpiresAt.Time, nil
 }
 
-func (m *TokenManager) NewRefreshToken(userID string) (string, error) {
-	return m.newToken(userID, refreshTokenType, refreshTTL, m.refreshKey)
+// NewDelegatedToken issues a short-lived access token for the subject of a user
+// token, to be used by actorClientID on the user's behalf (RFC 8693 token
+// exchange). The token keeps the subject's session, so revoking the session
+// revokes it too, and never outlives the subject token. An existing act claim
+// is nested under the new actor.
+func (m *TokenManager) NewDelegatedToken(subject domain.AccessTokenClaims, actorClientID string, scopes, audience []string, profile domain.ProfileClaims) (string, time.Time, error) {
+	audience, err := m.ResolveAudience(audience)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	claims := m.newClaims(subject.UserID, accessTokenType, m.policy.DelegatedTTL)
+	if subject.ExpiresAt.Before(claims.ExpiresAt.Time) {
+		claims.ExpiresAt = jwt.NewNumericDate(subject.ExpiresAt)
+	}
+	claims.SessionID = subject.SessionID
+	claims.SubjectType = domain.SubjectTypeUser
+	claims.Scope = strings.Join(scopes, " ")
+	claims.Audience = audience
+	claims.Actor = &domain.Actor{Subject: actorClientID, Actor: subject.Actor}
+	claims.ProfileClaims = profile
+
+	token, err := sign(claims, m.accessKeys.Active())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, claims.ExpiresAt.Time, nil
 }
 
-func (m *TokenManager) newToken(
-	userID string,
-	tokenType string,
-	ttl time.Duration,
-	key []byte,
-) (string, error) {
+// ResolveAudience falls back to the default audience and checks that every
+// requested audience is allowed.
+func (m *TokenManager) ResolveAudience(audience []string) ([]string, error) {
+	if len(audience) == 0 {
+		return m.policy.Audiences.Default, nil
+	}
+	for _, aud := range audience {
+		if !slices.Contains(m.policy.Audiences.Allowed, aud) {
+			return nil, fmt.Errorf("%w: %q", domain.ErrInvalidAudience, aud)
+		}
+	}
+	return audience, nil
+}
 
+// NewRefreshToken issues a random opaque refresh token. Refresh tokens are only
+// ever checked by auth_service against its database, so they need no signature
+// and carry no claims; the database keeps a SHA-256 hash.
+func (m *TokenManager) NewRefreshToken() (domain.RefreshToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return domain.RefreshToken{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return domain.RefreshToken{
+		Token: token,
+		Hash:  m.HashRefreshToken(token),
+	}, nil
+}
+
+// HashRefreshToken returns the hex SHA-256 under which a refresh token is
+// stored. A plain hash is enough since the tokens have 256 bits of entropy.
+func (m *TokenManager) HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsJWTRefreshToken reports whether token is a JWT refresh token issued before
+// refresh tokens became opaque. Opaque tokens are base64url and have no dots.
+func (m *TokenManager) IsJWTRefreshToken(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func (m *TokenManager) newClaims(userID string, tokenType string, ttl time.Duration) Claims {
+	now := time.Now()
 	claims := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    tokenIssuer,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    m.policy.Issuer,
 			Subject:   userID,
+			ID:        uuid.NewString(),
 		},
-		UserID: userID,
-		Type:   tokenType,
+		UserID:  userID,
+		Type:    tokenType,
+		Version: m.epochs.Current(parseUserID(userID)),
+	}
+	if m.policy.NotBefore {
+		claims.NotBefore = claims.IssuedAt
 	}
+	return claims
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(key)
+func sign(claims Claims, key *SigningKey) (string, error) {
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.KID()
+	return token.SignedString(key.sign)
 }
 
 //////////////////////
 // TOKEN PARSING    //
 //////////////////////
 
-func (m *TokenManager) ParseAccessToken(context context.Context, tokenStr string) (string, error) {
-	return m.parse(tokenStr, accessTokenType, m.accessKey)
+// ParseAccessToken verifies an access token addressed to at least one of the
+// accepted audiences.
+func (m *TokenManager) ParseAccessToken(ctx context.Context, tokenStr string, accepted []string) (domain.AccessTokenClaims, error) {
+	claims, err := m.parse(tokenStr, accessTokenType, m.accessKeys)
+	if err != nil {
+		return domain.AccessTokenClaims{}, err
+	}
+	if claim := m.policy.missingClaim(claims); claim != "" {
+		return domain.AccessTokenClaims{}, fmt.Errorf("missing required claim %q", claim)
+	}
+	if !slices.ContainsFunc(claims.Audience, func(aud string) bool {
+		return slices.Contains(accepted, aud)
+	}) {
+		return domain.AccessTokenClaims{}, domain.ErrInvalidAudience
+	}
+	// Revocation is only checked for tokens addressed to the caller, so that
+	// introspection does not report foreign tokens as revoked.
+	if m.isRevoked(ctx, claims) {
+		return domain.AccessTokenClaims{}, domain.ErrTokenRevoked
+	}
+	subjectType := claims.SubjectType
+	if subjectType == "" {
+		subjectType = domain.SubjectTypeUser
+	}
+	return domain.AccessTokenClaims{
+		Subject:     claims.Subject,
+		SubjectType: subjectType,
+		UserID:      claims.UserID,
+		ClientID:    claims.ClientID,
+		SessionID:   claims.SessionID,
+		TokenID:     claims.ID,
+		Actor:       claims.Actor,
+		Profile:     claims.ProfileClaims,
+		Scopes:      strings.Fields(claims.Scope),
+		Audience:    claims.Audience,
+		Issuer:      claims.Issuer,
+		IssuedAt:    claims.IssuedAt.Time,
+		ExpiresAt:   claims.ExpiresAt.Time,
+	}, nil
+}
+
+// isRevoked reports whether the token was denied, alone or with its session,
+// or invalidated by a token epoch increment.
+func (m *TokenManager) isRevoked(ctx context.Context, claims *Claims) bool {
+	if m.epochs.IsStale(ctx, parseUserID(claims.UserID), claims.Version) {
+		return true
+	}
+	for _, id := range []string{claims.ID, claims.SessionID} {
+		if parsed, err := uuid.Parse(id); err == nil && m.denylist.IsRevoked(parsed) {
+			return true
+		}
+	}
+	return false
 }
 
-func (m *TokenManager) ParseRefreshToken(context context.Context, tokenStr string) (string, error) {
-	return m.parse(tokenStr, refreshTokenType, m.refreshKey)
+// ParseRefreshToken verifies a JWT refresh token issued before refresh tokens
+// became opaque. Such tokens keep working until they are rotated or expire.
+func (m *TokenManager) ParseRefreshToken(ctx context.Context, tokenStr string) (domain.RefreshTokenClaims, error) {
+	claims, err := m.parse(tokenStr, refreshTokenType, m.refreshKeys)
+	if err != nil {
+		return domain.RefreshTokenClaims{}, err
+	}
+	if m.epochs.IsStale(ctx, parseUserID(claims.UserID), claims.Version) {
+		return domain.RefreshTokenClaims{}, domain.ErrTokenRevoked
+	}
+	return domain.RefreshTokenClaims{
+		UserID:    claims.UserID,
+		TokenID:   claims.ID,
+		FamilyID:  claims.FamilyID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
 }
 
 func (m *TokenManager) parse(
 	tokenStr string,
 	expectedType string,
-	key []byte,
-) (string, error) {
+	keys *Keyring,
+) (*Claims, error) {
+	return m.parseWithLeeway(tokenStr, expectedType, keys, m.policy.Leeway)
+}
+
+// parseWithLeeway is parse with another tolerated clock skew than the
+// policy's.
+func (m *TokenManager) parseWithLeeway(
+	tokenStr string,
+	expectedType string,
+	keys *Keyring,
+	leeway time.Duration,
+) (*Claims, error) {
 
 	token, err := jwt.ParseWithClaims(
 		tokenStr,
 		&Claims{},
 		func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			// Tokens issued before kid headers were introduced are checked
+			// against the active key.
+			key := keys.Active()
+			if kid, _ := t.Header["kid"].(string); kid != "" {
+				var err error
+				if key, err = keys.Lookup(kid); err != nil {
+					return nil, err
+				}
+			}
+			if t.Method.Alg() != key.Algorithm() {
 				return nil, errors.New("invalid signing method")
 			}
-			return key, nil
+			return key.verify, nil
 		},
+		jwt.WithLeeway(leeway),
+		jwt.WithIssuedAt(),
+		// Every token type expires; one without exp would be valid forever,
+		// whatever tokens.required_claims says.
+		jwt.WithExpirationRequired(),
 	)
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	claims, ok := token.Claims.(*Claims)
 	if !ok || !token.Valid {
-		return "", errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	if claims.Type != expectedType {
-		return "", errors.New("invalid token type")
+		return nil, errors.New("invalid token type")
 	}
 
-	if claims.Issuer != tokenIssuer {
-		return "", errors.New("invalid token issuer")
+	if claims.Issuer != m.policy.Issuer {
+		return nil, errors.New("invalid token issuer")
 	}
 
-	return claims.UserID, nil
+	return claims, nil
+}
+
+//////////////////////
+// REVOCATION       //
+//////////////////////
+
+// RevokeAccessToken denies a single access token until it expires.
+func (m *TokenManager) RevokeAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	id, err := uuid.Parse(tokenID)
+	if err != nil {
+		return err
+	}
+	return m.denylist.Revoke(ctx, id, expiresAt)
+}
+
+// RevokeUserTokens invalidates every token the user holds by incrementing the
+// user's token epoch. Returns the new epoch.
+func (m *TokenManager) RevokeUserTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return m.epochs.IncrementUser(ctx, userID)
+}
+
+// RevokeAllTokens invalidates every token by incrementing the global token
+// epoch. Returns the new epoch.
+func (m *TokenManager) RevokeAllTokens(ctx context.Context) (int64, error) {
+	return m.epochs.IncrementGlobal(ctx)
+}
+
+// RevokeSessionTokens denies every access token issued for the session. No such
+// token can outlive the access token lifetime from now.
+func (m *TokenManager) RevokeSessionTokens(ctx context.Context, sessionID string) error {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return err
+	}
+	return m.denylist.Revoke(ctx, id, time.Now().Add(m.accessLifetime()))
 }
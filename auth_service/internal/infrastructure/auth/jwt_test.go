@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessTokenAudience(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested []string
+		accepted  []string
+		want      []string
+		wantErr   error // of NewAccessToken, or of ParseAccessToken when nil
+	}{
+		{name: "default", accepted: []string{"auth-service"}, want: []string{"auth-service"}},
+		{name: "requested", requested: []string{"content-service"}, accepted: []string{"content-service"}, want: []string{"content-service"}},
+		{name: "one of several accepted", requested: []string{"auth-service", "content-service"}, accepted: []string{"content-service"}, want: []string{"auth-service", "content-service"}},
+		{name: "not allowed", requested: []string{"billing"}, wantErr: domain.ErrInvalidAudience},
+		{name: "not accepted", requested: []string{"content-service"}, accepted: []string{"auth-service"}, wantErr: domain.ErrInvalidAudience},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, newMemDenylistStore(), newMemEpochStore())
+			token, err := m.NewAccessToken("6f1c0a7e-3b5d-4c2a-9e8f-0a1b2c3d4e5f", "", tt.requested, domain.ProfileClaims{}, domain.Grant{})
+			if err == nil {
+				var claims domain.AccessTokenClaims
+				claims, err = m.ParseAccessToken(context.Background(), token, tt.accepted)
+				if err == nil && !slices.Equal(claims.Audience, tt.want) {
+					t.Errorf("Audience = %v, want %v", claims.Audience, tt.want)
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewHMACKeyMinimumLength(t *testing.T) {
+	if _, err := NewHMACKey(strings.Repeat("s", minHMACSecretBytes-1)); err == nil {
+		t.Errorf("%d byte secret accepted", minHMACSecretBytes-1)
+	}
+	if _, err := NewHMACKey(strings.Repeat("s", minHMACSecretBytes)); err != nil {
+		t.Errorf("%d byte secret: %v", minHMACSecretBytes, err)
+	}
+}
+
+// exp is required even when the configured policy does not list it.
+func TestParseRequiresExpiration(t *testing.T) {
+	m := newTestManager(t, newMemDenylistStore(), newMemEpochStore())
+	m.policy.RequiredClaims = []string{"iat", "iss"}
+
+	tests := []struct {
+		name      string
+		expiresAt time.Duration
+		drop      bool
+		wantErr   bool
+	}{
+		{name: "valid", expiresAt: time.Minute},
+		{name: "expired past the leeway", expiresAt: -time.Minute, wantErr: true},
+		{name: "no exp", drop: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := m.newClaims("6f1c0a7e-3b5d-4c2a-9e8f-0a1b2c3d4e5f", accessTokenType, tt.expiresAt)
+			claims.Audience = []string{"auth-service"}
+			if tt.drop {
+				claims.ExpiresAt = nil
+			}
+			token, err := sign(claims, m.accessKeys.Active())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = m.ParseAccessToken(context.Background(), token, []string{"auth-service"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseAccessToken() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
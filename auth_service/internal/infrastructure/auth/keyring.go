@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Keyring holds the active signing key plus any number of verify-only keys,
+// indexed by kid. A retired key stays verifiable until retireAt, which is set
+// to the moment the last token it could have signed expires.
+type Keyring struct {
+	mu     sync.RWMutex
+	active *SigningKey
+	keys   map[string]*keyringEntry
+}
+
+type keyringEntry struct {
+	key      *SigningKey
+	retireAt time.Time // zero while the key is active
+}
+
+func NewKeyring(active *SigningKey) (*Keyring, error) {
+	if !active.CanSign() {
+		return nil, errors.New("active key must hold private material")
+	}
+	return &Keyring{
+		active: active,
+		keys: map[string]*keyringEntry{
+			active.KID(): {key: active},
+		},
+	}, nil
+}
+
+// Active returns the key new tokens are signed with.
+func (k *Keyring) Active() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Lookup finds a key by kid. Retired keys past their retireAt are dropped.
+func (k *Keyring) Lookup(kid string) (*SigningKey, error) {
+	k.mu.RLock()
+	entry, ok := k.keys[kid]
+	k.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if !entry.retireAt.IsZero() && time.Now().After(entry.retireAt) {
+		k.prune()
+		return nil, ErrUnknownKey
+	}
+	return entry.key, nil
+}
+
+// AddVerifyKey registers a verify-only key that is accepted until `until`.
+func (k *Keyring) AddVerifyKey(key *SigningKey, until time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[key.KID()]; ok {
+		return
+	}
+	k.keys[key.KID()] = &keyringEntry{key: key, retireAt: until}
+}
+
+// Rotate makes next the active key. The previous active key is kept for
+// verification for `overlap`, which must be at least the TTL of the tokens it
+// signed. Rotating to the key that is already active is a no-op.
+func (k *Keyring) Rotate(next *SigningKey, overlap time.Duration) error {
+	if !next.CanSign() {
+		return errors.New("active key must hold private material")
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if next.KID() == k.active.KID() {
+		return nil
+	}
+
+	k.keys[k.active.KID()].retireAt = time.Now().Add(overlap)
+	k.keys[next.KID()] = &keyringEntry{key: next}
+	k.active = next
+	return nil
+}
+
+// Keys returns the active key followed by the still valid retired keys,
+// newest retirement first.
+func (k *Keyring) Keys() []*SigningKey {
+	k.prune()
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	retired := make([]*keyringEntry, 0, len(k.keys))
+	for _, entry := range k.keys {
+		if entry.key != k.active {
+			retired = append(retired, entry)
+		}
+	}
+	sort.Slice(retired, func(i, j int) bool {
+		return retired[i].retireAt.After(retired[j].retireAt)
+	})
+
+	keys := []*SigningKey{k.active}
+	for _, entry := range retired {
+		keys = append(keys, entry.key)
+	}
+	return keys
+}
+
+func (k *Keyring) prune() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now()
+	for kid, entry := range k.keys {
+		if !entry.retireAt.IsZero() && now.After(entry.retireAt) {
+			delete(k.keys, kid)
+		}
+	}
+}
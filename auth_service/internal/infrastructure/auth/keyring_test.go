@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+)
+
+func TestRotateKeys(t *testing.T) {
+	m := newTestManager(t, newMemDenylistStore(), newMemEpochStore())
+	m.policy.Algorithm = "HS256"
+	oldAccess, oldRefresh := m.accessKeys.Active(), m.refreshKeys.Active()
+	access := mustHMACKey(t, "next-access-secret-0123456789abcdef")
+	refresh := mustHMACKey(t, "next-refresh-secret-0123456789abcdef")
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The policy's algorithm is HS256, so the access key is refused and the
+	// refresh key must not be switched either.
+	if err := m.RotateKeys(NewEd25519Key(priv), refresh); err == nil {
+		t.Fatal("access key of another algorithm accepted")
+	}
+	if m.accessKeys.Active() != oldAccess || m.refreshKeys.Active() != oldRefresh {
+		t.Fatal("keys switched by a failed rotation")
+	}
+
+	publicOnly := newEd25519VerifyKey(priv.Public().(ed25519.PublicKey))
+	if err := m.RotateKeys(access, publicOnly); err == nil {
+		t.Fatal("refresh key without private material accepted")
+	}
+	if m.accessKeys.Active() != oldAccess || m.refreshKeys.Active() != oldRefresh {
+		t.Fatal("keys switched by a failed rotation")
+	}
+
+	if err := m.RotateKeys(access, refresh); err != nil {
+		t.Fatal(err)
+	}
+	if m.accessKeys.Active() != access || m.refreshKeys.Active() != refresh {
+		t.Fatal("keys not switched")
+	}
+	for _, kid := range []string{oldAccess.KID(), access.KID()} {
+		if _, err := m.accessKeys.Lookup(kid); err != nil {
+			t.Errorf("access key %s: %v", kid, err)
+		}
+	}
+	if _, err := m.refreshKeys.Lookup(oldRefresh.KID()); err != nil {
+		t.Errorf("previous refresh key: %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+)
+
+const (
+	minRSAKeyBits = 2048
+	// minHMACSecretBytes is the HS256 output size; shorter secrets can be
+	// brute-forced from a single token (RFC 7518 section 3.2).
+	minHMACSecretBytes = 32
+)
+
+// SigningKey pairs a JWT signing method with the material used to sign and verify.
+// For HMAC both sides are the shared secret; for RSA and Ed25519 the verify side
+// is the public key, which can be published in a JWKS. Keys loaded from a public
+// key have no sign side and can only be used for verification.
+type SigningKey struct {
+	kid    string
+	method jwt.SigningMethod
+	sign   any
+	verify any
+}
+
+func NewHMACKey(secret string) (*SigningKey, error) {
+	if len(secret) < minHMACSecretBytes {
+		return nil, fmt.Errorf("hmac secret must be at least %d bytes", minHMACSecretBytes)
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return &SigningKey{
+		kid:    "hs-" + hex.EncodeToString(sum[:8]),
+		method: jwt.SigningMethodHS256,
+		sign:   []byte(secret),
+		verify: []byte(secret),
+	}, nil
+}
+
+func NewRSAKey(key *rsa.PrivateKey) (*SigningKey, error) {
+	k, err := newRSAVerifyKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	k.sign = key
+	return k, nil
+}
+
+func NewEd25519Key(key ed25519.PrivateKey) *SigningKey {
+	k := newEd25519VerifyKey(key.Public().(ed25519.PublicKey))
+	k.sign = key
+	return k
+}
+
+func newRSAVerifyKey(pub *rsa.PublicKey) (*SigningKey, error) {
+	if pub.N.BitLen() < minRSAKeyBits {
+		return nil, fmt.Errorf("rsa key must be at least %d bits", minRSAKeyBits)
+	}
+	// RFC 7638 thumbprint: members in lexicographic order, no whitespace.
+	thumb := fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`,
+		b64(big.NewInt(int64(pub.E)).Bytes()), b64(pub.N.Bytes()))
+	return &SigningKey{
+		kid:    thumbprint(thumb),
+		method: jwt.SigningMethodRS256,
+		verify: pub,
+	}, nil
+}
+
+func newEd25519VerifyKey(pub ed25519.PublicKey) *SigningKey {
+	thumb := fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, b64(pub))
+	return &SigningKey{
+		kid:    thumbprint(thumb),
+		method: jwt.SigningMethodEdDSA,
+		verify: pub,
+	}
+}
+
+func thumbprint(canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	return b64(sum[:])
+}
+
+// ParsePrivateKeyPEM accepts PKCS#8 ("PRIVATE KEY") and PKCS#1 ("RSA PRIVATE KEY")
+// blocks and picks RS256 or EdDSA based on the key type.
+func ParsePrivateKeyPEM(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewRSAKey(key)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return NewRSAKey(k)
+		case ed25519.PrivateKey:
+			return NewEd25519Key(k), nil
+		default:
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
+
+// ParsePublicKeyPEM accepts a PKIX ("PUBLIC KEY") block and returns a
+// verify-only key, used to keep accepting tokens signed by a retired key.
+func ParsePublicKeyPEM(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return newRSAVerifyKey(k)
+	case ed25519.PublicKey:
+		return newEd25519VerifyKey(k), nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+func LoadPrivateKeyFile(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// LoadVerifyKeyFile reads either a private or a public key and returns it
+// for verification only.
+func LoadVerifyKeyFile(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePublicKeyPEM(data)
+	if err != nil {
+		key, err = ParsePrivateKeyPEM(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &SigningKey{kid: key.kid, method: key.method, verify: key.verify}, nil
+}
+
+// KID returns the key id written to the "kid" header of tokens signed with this key.
+// Asymmetric keys use their RFC 7638 thumbprint, so the same key always gets the same id.
+func (k *SigningKey) KID() string {
+	return k.kid
+}
+
+// Algorithm returns the JWS "alg" value, e.g. HS256, RS256 or EdDSA.
+func (k *SigningKey) Algorithm() string {
+	return k.method.Alg()
+}
+
+// IsAsymmetric reports whether the verify key can be shared publicly.
+func (k *SigningKey) IsAsymmetric() bool {
+	_, hmac := k.verify.([]byte)
+	return !hmac
+}
+
+// CanSign reports whether the key holds private material.
+func (k *SigningKey) CanSign() bool {
+	return k.sign != nil
+}
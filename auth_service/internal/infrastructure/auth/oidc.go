@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"time"
+)
+
+const idTokenTTL = 10 * time.Minute
+
+// ErrNoPublicKey is returned when an id_token is requested but access tokens are
+// signed with an HMAC secret that relying parties could not verify.
+var ErrNoPublicKey = errors.New("id tokens require an asymmetric access signing key")
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time"`
+
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	GivenName         string `json:"given_name,omitempty"`
+	FamilyName        string `json:"family_name,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Email             string `json:"email,omitempty"`
+}
+
+// NewIDToken signs an OpenID Connect id_token with the active access key, whose
+// public part relying parties fetch from the JWKS.
+func (m *TokenManager) NewIDToken(claims domain.IDTokenClaims) (string, error) {
+	key := m.accessKeys.Active()
+	if !key.IsAsymmetric() {
+		return "", ErrNoPublicKey
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(key.method, idTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    claims.Issuer,
+			Subject:   claims.Subject,
+			Audience:  jwt.ClaimStrings{claims.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(idTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        uuid.NewString(),
+		},
+		Nonce:             claims.Nonce,
+		AuthTime:          claims.AuthTime.Unix(),
+		PreferredUsername: claims.PreferredUsername,
+		GivenName:         claims.GivenName,
+		FamilyName:        claims.FamilyName,
+		Name:              claims.Name,
+		Email:             claims.Email,
+	})
+	token.Header["kid"] = key.KID()
+	return token.SignedString(key.sign)
+}
+
+// IDTokenAlgorithm returns the "alg" id_tokens are signed with, as advertised
+// in the discovery document.
+func (m *TokenManager) IDTokenAlgorithm() string {
+	return m.accessKeys.Active().Algorithm()
+}
+
+// AccessTokenTTL returns how long access tokens are valid, for the expires_in
+// of token responses.
+func (m *TokenManager) AccessTokenTTL() time.Duration {
+	return m.policy.AccessTTL
+}
@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// NewPasswordResetToken returns a random password reset token and the hash it
+// is stored under.
+func (m *TokenManager) NewPasswordResetToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, m.HashPasswordResetToken(token), nil
+}
+
+// HashPasswordResetToken returns the hex SHA-256 of token.
+func (m *TokenManager) HashPasswordResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
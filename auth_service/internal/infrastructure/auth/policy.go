@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"slices"
+	"time"
+)
+
+// Limits a TokenPolicy is checked against. Access tokens are verified offline
+// by other services and cannot be recalled from them, so they must stay short.
+const (
+	maxAccessTTL = time.Hour
+	maxLeeway    = 5 * time.Minute
+)
+
+// Claims that TokenPolicy.RequiredClaims may name.
+var knownClaims = []string{"exp", "iat", "nbf", "iss", "sub", "aud", "jti"}
+
+var signingAlgorithms = []string{
+	jwt.SigningMethodHS256.Alg(),
+	jwt.SigningMethodRS256.Alg(),
+	jwt.SigningMethodEdDSA.Alg(),
+}
+
+// TokenPolicy configures the tokens a TokenManager issues and accepts.
+type TokenPolicy struct {
+	// Issuer is the "iss" of issued tokens; parsed tokens must carry it.
+	Issuer string
+
+	AccessTTL    time.Duration
+	DelegatedTTL time.Duration // token exchange; capped by the subject token
+	// RefreshTTL is how long JWT refresh tokens issued before refresh tokens
+	// became opaque stay valid, and so how long a retired refresh key keeps
+	// verifying. Opaque refresh tokens follow the session policy instead.
+	RefreshTTL time.Duration
+
+	// Algorithm pins the access token signing algorithm (HS256, RS256 or
+	// EdDSA). The access key must match it. Empty accepts the key as it is.
+	Algorithm string
+
+	// Leeway tolerates clock skew when checking exp, nbf and iat.
+	Leeway time.Duration
+
+	// NotBefore adds an nbf claim equal to iat to issued access tokens. An nbf
+	// present on a parsed token is always checked.
+	NotBefore bool
+
+	// RequiredClaims must be present on every parsed access token, e.g.
+	// ["exp", "iat", "jti"]. exp is required of every token regardless.
+	RequiredClaims []string
+
+	Audiences AudiencePolicy
+}
+
+// AudiencePolicy lists the audiences access tokens may be requested for, the
+// audience used when a request names none, and the audiences auth_service
+// accepts on its own routes.
+type AudiencePolicy struct {
+	Allowed  []string
+	Default  []string
+	Accepted []string
+}
+
+// Validate reports the first unsafe or inconsistent value of the policy.
+func (p TokenPolicy) Validate() error {
+	if p.Issuer == "" {
+		return errors.New("token policy: issuer is not set")
+	}
+	if p.AccessTTL <= 0 || p.AccessTTL > maxAccessTTL {
+		return fmt.Errorf("token policy: access_ttl must be between 0 and %s, got %s", maxAccessTTL, p.AccessTTL)
+	}
+	if p.DelegatedTTL <= 0 || p.DelegatedTTL > p.AccessTTL {
+		return fmt.Errorf("token policy: delegated_ttl must be between 0 and access_ttl, got %s", p.DelegatedTTL)
+	}
+	if p.RefreshTTL <= 0 {
+		return fmt.Errorf("token policy: refresh_ttl must be positive, got %s", p.RefreshTTL)
+	}
+	if p.Algorithm != "" && !slices.Contains(signingAlgorithms, p.Algorithm) {
+		return fmt.Errorf("token policy: unsupported algorithm %q", p.Algorithm)
+	}
+	if p.Leeway < 0 || p.Leeway > maxLeeway {
+		return fmt.Errorf("token policy: leeway must be between 0 and %s, got %s", maxLeeway, p.Leeway)
+	}
+	for _, claim := range p.RequiredClaims {
+		if !slices.Contains(knownClaims, claim) {
+			return fmt.Errorf("token policy: unknown required claim %q", claim)
+		}
+	}
+	if slices.Contains(p.RequiredClaims, "nbf") && !p.NotBefore {
+		return errors.New("token policy: nbf is required but not_before is off, so issued tokens would be rejected")
+	}
+	return p.Audiences.validate()
+}
+
+func (p AudiencePolicy) validate() error {
+	if len(p.Default) == 0 {
+		return errors.New("token policy: default_audience is not set")
+	}
+	for _, aud := range p.Default {
+		if !slices.Contains(p.Allowed, aud) {
+			return fmt.Errorf("token policy: default audience %q is not in audiences", aud)
+		}
+	}
+	if len(p.Accepted) == 0 {
+		return errors.New("token policy: accepted_audiences is not set")
+	}
+	return nil
+}
+
+// checkKey reports whether key may sign access tokens under the policy.
+func (p TokenPolicy) checkKey(key *SigningKey) error {
+	if p.Algorithm != "" && key.Algorithm() != p.Algorithm {
+		return fmt.Errorf("token policy: access key uses %s, policy requires %s", key.Algorithm(), p.Algorithm)
+	}
+	return nil
+}
+
+// missingClaim returns the first required claim absent from claims.
+func (p TokenPolicy) missingClaim(claims *Claims) string {
+	for _, claim := range p.RequiredClaims {
+		var present bool
+		switch claim {
+		case "exp":
+			present = claims.ExpiresAt != nil
+		case "iat":
+			present = claims.IssuedAt != nil
+		case "nbf":
+			present = claims.NotBefore != nil
+		case "iss":
+			present = claims.Issuer != ""
+		case "sub":
+			present = claims.Subject != ""
+		case "aud":
+			present = len(claims.Audience) > 0
+		case "jti":
+			present = claims.ID != ""
+		}
+		if !present {
+			return claim
+		}
+	}
+	return ""
+}
+
+// AcceptedAudiences returns the audiences auth_service accepts on its own
+// protected routes.
+func (m *TokenManager) AcceptedAudiences() []string {
+	return m.policy.Audiences.Accepted
+}
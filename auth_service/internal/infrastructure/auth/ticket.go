@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// NewTicket returns a random connection ticket and the hash it is stored under.
+func (m *TokenManager) NewTicket() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(b)
+	return ticket, m.HashTicket(ticket), nil
+}
+
+// HashTicket returns the hex SHA-256 of ticket.
+func (m *TokenManager) HashTicket(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
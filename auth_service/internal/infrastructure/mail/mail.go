@@ -0,0 +1,69 @@
+package mail
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string // empty for servers without authentication
+	Password string
+	From     string
+}
+
+// SMTPMailer sends emails through an SMTP server, using STARTTLS when the
+// server offers it.
+type SMTPMailer struct {
+	cfg SMTPConfig
+}
+
+func NewSMTPMailer(cfg SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, email domain.Email) error {
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.cfg.From,
+		"To: " + email.To,
+		"Subject: " + email.Subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		email.Body,
+	}, "\r\n")
+
+	addr := net.JoinHostPort(m.cfg.Host, m.cfg.Port)
+	if err := smtp.SendMail(addr, auth, m.cfg.From, []string{email.To}, []byte(msg)); err != nil {
+		return fmt.Errorf("send mail to %s: %w", email.To, err)
+	}
+	return nil
+}
+
+// LogMailer writes emails to the log instead of sending them, for local
+// development without an SMTP server. The bodies hold working verification
+// and reset links, so it must never be used in production.
+type LogMailer struct {
+	log *logger.SlogLogger
+}
+
+func NewLogMailer(log *logger.SlogLogger) *LogMailer {
+	return &LogMailer{log: log}
+}
+
+func (m *LogMailer) Send(ctx context.Context, email domain.Email) error {
+	m.log.Info(ctx, "mail: log driver, not sent",
+		"to", email.To, "subject", email.Subject, "body", email.Body)
+	return nil
+}
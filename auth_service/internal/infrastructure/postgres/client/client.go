@@ -0,0 +1,54 @@
+package client
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/postgres"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+)
+
+type Clients struct {
+	db  *sqlx.DB
+	log *logger.SlogLogger
+}
+
+func NewClientRepository(db *sqlx.DB, log *logger.SlogLogger) *Clients {
+	return &Clients{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *Clients) CreateClient(ctx context.Context, client domain.Client) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (client_id, client_secret_hash, name, scopes, redirect_uris, public)
+		VALUES (:client_id, :client_secret_hash, :name, :scopes, :redirect_uris, :public)
+	`, postgres.Clients)
+
+	_, err := r.db.NamedExecContext(ctx, query, client)
+	if err != nil {
+		r.log.Error(ctx, "create client error", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (r *Clients) GetClientByClientID(ctx context.Context, clientID string) (domain.Client, error) {
+	var client domain.Client
+
+	query := fmt.Sprintf(`
+		SELECT id, client_id, client_secret_hash, name, scopes, redirect_uris, public, created_at
+		FROM %s
+		WHERE client_id = $1
+	`, postgres.Clients)
+
+	err := r.db.GetContext(ctx, &client, query, clientID)
+	if err != nil && err != sql.ErrNoRows {
+		r.log.Error(ctx, "get client error", err.Error())
+	}
+	return client, err
+}
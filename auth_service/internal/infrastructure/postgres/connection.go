@@ -7,9 +7,21 @@ import (
 )
 
 const (
-	Users        = "users"
-	Games        = "games"
-	ScoreHistory = "score_history"
+	Users                = "users"
+	Games                = "games"
+	ScoreHistory         = "score_history"
+	UsedRefreshTokens    = "used_refresh_tokens"
+	SecurityEvents       = "security_events"
+	Sessions             = "sessions"
+	RevokedTokens        = "revoked_tokens"
+	Clients              = "clients"
+	AuthorizationCodes   = "authorization_codes"
+	PersonalAccessTokens = "personal_access_tokens"
+	DeviceAuthorizations = "device_authorizations"
+	RedeemedCapabilities = "redeemed_capabilities"
+	Tickets              = "tickets"
+	TokenEpoch           = "token_epoch"
+	PasswordResetTokens  = "password_reset_tokens"
 )
 
 func Connect(username, password, host, port, databaseName, sslMode string) (*sqlx.DB, error) {
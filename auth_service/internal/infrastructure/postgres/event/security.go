@@ -0,0 +1,43 @@
+package event
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/postgres"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+)
+
+type Security struct {
+	db  *sqlx.DB
+	log *logger.SlogLogger
+}
+
+func NewSecurityRepository(db *sqlx.DB, log *logger.SlogLogger) *Security {
+	return &Security{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *Security) CreateSecurityEvent(ctx context.Context, event domain.SecurityEvent) error {
+	details, err := json.Marshal(event.Details)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (user_id, event_type, details)
+		VALUES ($1, $2, $3)
+	`, postgres.SecurityEvents)
+
+	_, err = r.db.ExecContext(ctx, query, event.UserID, event.Type, details)
+	if err != nil {
+		r.log.Error(ctx, "create security event error", err.Error())
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package token
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/postgres"
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type Capabilities struct {
+	db  *sqlx.DB
+	log *logger.SlogLogger
+}
+
+func NewCapabilityRepository(db *sqlx.DB, log *logger.SlogLogger) *Capabilities {
+	return &Capabilities{
+		db:  db,
+		log: log,
+	}
+}
+
+// capabilityRetention keeps redemption records past the expiry of their token,
+// so that a token accepted by an application server whose clock is behind the
+// database's still finds its record.
+const capabilityRetention = 5 * time.Minute
+
+// RedeemCapability records that a capability token was used. Of concurrent
+// calls for the same token exactly one succeeds; the others, and every later
+// call, get domain.ErrCapabilityRedeemed. Records of tokens that expired more
+// than capabilityRetention ago are cleared on the way.
+func (r *Capabilities) RedeemCapability(ctx context.Context, capability domain.Capability) error {
+	cleanup := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE expires_at <= NOW() - $1 * INTERVAL '1 second'
+	`, postgres.RedeemedCapabilities)
+
+	if _, err := r.db.ExecContext(ctx, cleanup, capabilityRetention.Seconds()); err != nil {
+		r.log.Error(ctx, "delete expired capabilities error", err.Error())
+		return err
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, user_id, file_key, action, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO NOTHING
+	`, postgres.RedeemedCapabilities)
+
+	res, err := r.db.ExecContext(ctx, query,
+		capability.TokenID,
+		capability.UserID,
+		capability.FileKey,
+		capability.Action,
+		capability.ExpiresAt,
+	)
+	if err != nil {
+		r.log.Error(ctx, "redeem capability error", err.Error())
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrCapabilityRedeemed
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package token
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/postgres"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+)
+
+type AuthorizationCodes struct {
+	db  *sqlx.DB
+	log *logger.SlogLogger
+}
+
+func NewAuthorizationCodeRepository(db *sqlx.DB, log *logger.SlogLogger) *AuthorizationCodes {
+	return &AuthorizationCodes{
+		db:  db,
+		log: log,
+	}
+}
+
+// CreateAuthorizationCode stores a new code and drops the expired ones, which
+// were never redeemed.
+func (r *AuthorizationCodes) CreateAuthorizationCode(ctx context.Context, code domain.AuthorizationCode) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, nonce, auth_time, expires_at)
+		VALUES (:code_hash, :client_id, :user_id, :redirect_uri, :scopes, :code_challenge, :nonce, :auth_time, :expires_at)
+	`, postgres.AuthorizationCodes)
+
+	_, err := r.db.NamedExecContext(ctx, query, code)
+	if err != nil {
+		r.log.Error(ctx, "create authorization code error", err.Error())
+		return err
+	}
+
+	cleanup := fmt.Sprintf(`DELETE FROM %s WHERE expires_at <= NOW()`, postgres.AuthorizationCodes)
+	if _, err := r.db.ExecContext(ctx, cleanup); err != nil {
+		r.log.Error(ctx, "delete expired authorization codes error", err.Error())
+	}
+
+	return nil
+}
+
+// ConsumeAuthorizationCode deletes the code and returns it, so that a code can
+// be redeemed only once even by concurrent requests. Returns sql.ErrNoRows for
+// an unknown or already redeemed code.
+func (r *AuthorizationCodes) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (domain.AuthorizationCode, error) {
+	var code domain.AuthorizationCode
+
+	query := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE code_hash = $1
+		RETURNING code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, nonce, auth_time, expires_at
+	`, postgres.AuthorizationCodes)
+
+	err := r.db.GetContext(ctx, &code, query, codeHash)
+	if err != nil && err != sql.ErrNoRows {
+		r.log.Error(ctx, "consume authorization code error", err.Error())
+	}
+	return code, err
+}
@@ -0,0 +1,143 @@
+package token
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/postgres"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type DeviceAuthorizations struct {
+	db  *sqlx.DB
+	log *logger.SlogLogger
+}
+
+func NewDeviceAuthorizationRepository(db *sqlx.DB, log *logger.SlogLogger) *DeviceAuthorizations {
+	return &DeviceAuthorizations{
+		db:  db,
+		log: log,
+	}
+}
+
+// CreateDeviceAuthorization stores a new authorization and drops the expired
+// ones, which were never completed.
+func (r *DeviceAuthorizations) CreateDeviceAuthorization(ctx context.Context, auth domain.DeviceAuthorization) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (device_code_hash, user_code, client_id, scopes, status, poll_interval, expires_at)
+		VALUES (:device_code_hash, :user_code, :client_id, :scopes, :status, :poll_interval, :expires_at)
+	`, postgres.DeviceAuthorizations)
+
+	_, err := r.db.NamedExecContext(ctx, query, auth)
+	if err != nil {
+		r.log.Error(ctx, "create device authorization error", err.Error())
+		return err
+	}
+
+	cleanup := fmt.Sprintf(`DELETE FROM %s WHERE expires_at <= NOW()`, postgres.DeviceAuthorizations)
+	if _, err := r.db.ExecContext(ctx, cleanup); err != nil {
+		r.log.Error(ctx, "delete expired device authorizations error", err.Error())
+	}
+
+	return nil
+}
+
+func (r *DeviceAuthorizations) GetDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (domain.DeviceAuthorization, error) {
+	var auth domain.DeviceAuthorization
+
+	query := fmt.Sprintf(`
+		SELECT device_code_hash, user_code, client_id, scopes, status, user_id, auth_time, last_polled_at, poll_interval, expires_at
+		FROM %s
+		WHERE user_code = $1
+	`, postgres.DeviceAuthorizations)
+
+	err := r.db.GetContext(ctx, &auth, query, userCode)
+	if err != nil && err != sql.ErrNoRows {
+		r.log.Error(ctx, "get device authorization error", err.Error())
+	}
+	return auth, err
+}
+
+// PollDeviceAuthorization records a poll by the device and returns the
+// authorization as it was before, so the caller can see when it last polled.
+func (r *DeviceAuthorizations) PollDeviceAuthorization(ctx context.Context, deviceCodeHash string) (domain.DeviceAuthorization, error) {
+	var auth domain.DeviceAuthorization
+
+	query := fmt.Sprintf(`
+		UPDATE %[1]s AS d
+		SET last_polled_at = NOW()
+		FROM (SELECT device_code_hash, last_polled_at FROM %[1]s WHERE device_code_hash = $1 FOR UPDATE) AS prev
+		WHERE d.device_code_hash = prev.device_code_hash
+		RETURNING d.device_code_hash, d.user_code, d.client_id, d.scopes, d.status, d.user_id, d.auth_time,
+			prev.last_polled_at, d.poll_interval, d.expires_at
+	`, postgres.DeviceAuthorizations)
+
+	err := r.db.GetContext(ctx, &auth, query, deviceCodeHash)
+	if err != nil && err != sql.ErrNoRows {
+		r.log.Error(ctx, "poll device authorization error", err.Error())
+	}
+	return auth, err
+}
+
+// SlowDownDeviceAuthorization adds step to the interval the device must wait
+// between polls and returns the new interval in seconds.
+func (r *DeviceAuthorizations) SlowDownDeviceAuthorization(ctx context.Context, deviceCodeHash string, step time.Duration) (int, error) {
+	var interval int
+
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET poll_interval = poll_interval + $2
+		WHERE device_code_hash = $1
+		RETURNING poll_interval
+	`, postgres.DeviceAuthorizations)
+
+	err := r.db.GetContext(ctx, &interval, query, deviceCodeHash, int(step.Seconds()))
+	if err != nil && err != sql.ErrNoRows {
+		r.log.Error(ctx, "slow down device authorization error", err.Error())
+	}
+	return interval, err
+}
+
+// DecideDeviceAuthorization approves or denies a pending authorization on
+// behalf of userID. Returns sql.ErrNoRows for an unknown, expired or already
+// decided user code.
+func (r *DeviceAuthorizations) DecideDeviceAuthorization(ctx context.Context, userCode string, userID uuid.UUID, status string) error {
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET status = $3, user_id = $2, auth_time = NOW()
+		WHERE user_code = $1 AND status = $4 AND expires_at > NOW()
+	`, postgres.DeviceAuthorizations)
+
+	res, err := r.db.ExecContext(ctx, query, userCode, userID, status, domain.DeviceAuthorizationPending)
+	if err != nil {
+		r.log.Error(ctx, "decide device authorization error", err.Error())
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// DeleteDeviceAuthorization removes a decided authorization, so that tokens
+// are issued for it only once even to concurrent polls. Returns sql.ErrNoRows
+// when it was already removed.
+func (r *DeviceAuthorizations) DeleteDeviceAuthorization(ctx context.Context, deviceCodeHash string) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE device_code_hash = $1`, postgres.DeviceAuthorizations)
+
+	res, err := r.db.ExecContext(ctx, query, deviceCodeHash)
+	if err != nil {
+		r.log.Error(ctx, "delete device authorization error", err.Error())
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package token
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/postgres"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type TokenEpochs struct {
+	db  *sqlx.DB
+	log *logger.SlogLogger
+}
+
+func NewTokenEpochRepository(db *sqlx.DB, log *logger.SlogLogger) *TokenEpochs {
+	return &TokenEpochs{
+		db:  db,
+		log: log,
+	}
+}
+
+// GetTokenEpochs returns the global epoch and the epochs of the users whose
+// counter is not zero.
+func (r *TokenEpochs) GetTokenEpochs(ctx context.Context) (domain.TokenEpochs, error) {
+	epochs := domain.TokenEpochs{Users: map[uuid.UUID]int64{}}
+
+	query := fmt.Sprintf(`SELECT epoch FROM %s WHERE id = 1`, postgres.TokenEpoch)
+	if err := r.db.GetContext(ctx, &epochs.Global, query); err != nil {
+		r.log.Error(ctx, "get global token epoch error", err.Error())
+		return domain.TokenEpochs{}, err
+	}
+
+	var users []struct {
+		ID    uuid.UUID `db:"id"`
+		Epoch int64     `db:"token_epoch"`
+	}
+	query = fmt.Sprintf(`SELECT id, token_epoch FROM %s WHERE token_epoch > 0`, postgres.Users)
+	if err := r.db.SelectContext(ctx, &users, query); err != nil {
+		r.log.Error(ctx, "list user token epochs error", err.Error())
+		return domain.TokenEpochs{}, err
+	}
+	for _, u := range users {
+		epochs.Users[u.ID] = u.Epoch
+	}
+
+	return epochs, nil
+}
+
+// IncrementGlobalTokenEpoch bumps the global epoch and, in the same
+// transaction, revokes every session, personal access token, connection ticket
+// and authorization or device code a user already approved, none of which
+// carry an epoch. Returns the new epoch.
+func (r *TokenEpochs) IncrementGlobalTokenEpoch(ctx context.Context) (int64, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		r.log.Error(ctx, "increment global token epoch: begin tx error", err.Error())
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var epoch int64
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET epoch = epoch + 1, updated_at = NOW()
+		WHERE id = 1
+		RETURNING epoch
+	`, postgres.TokenEpoch)
+	if err := tx.GetContext(ctx, &epoch, query); err != nil {
+		r.log.Error(ctx, "increment global token epoch error", err.Error())
+		return 0, err
+	}
+
+	for _, query := range []string{
+		fmt.Sprintf(`UPDATE %s SET refresh_token_hash = NULL, revoked_at = NOW() WHERE revoked_at IS NULL`, postgres.Sessions),
+		fmt.Sprintf(`UPDATE %s SET revoked_at = NOW() WHERE revoked_at IS NULL`, postgres.PersonalAccessTokens),
+		fmt.Sprintf(`DELETE FROM %s`, postgres.Tickets),
+		fmt.Sprintf(`DELETE FROM %s`, postgres.AuthorizationCodes),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id IS NOT NULL`, postgres.DeviceAuthorizations),
+	} {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			r.log.Error(ctx, "increment global token epoch: revoke error", err.Error())
+			return 0, err
+		}
+	}
+
+	return epoch, tx.Commit()
+}
+
+// IncrementUserTokenEpoch is IncrementGlobalTokenEpoch for a single user.
+// Returns sql.ErrNoRows when the user does not exist.
+func (r *TokenEpochs) IncrementUserTokenEpoch(ctx context.Context, userID uuid.UUID) (int64, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		r.log.Error(ctx, "increment user token epoch: begin tx error", err.Error())
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var epoch int64
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET token_epoch = token_epoch + 1
+		WHERE id = $1
+		RETURNING token_epoch
+	`, postgres.Users)
+	if err := tx.GetContext(ctx, &epoch, query, userID); err != nil {
+		r.log.Error(ctx, "increment user token epoch error", err.Error())
+		return 0, err
+	}
+
+	for _, query := range []string{
+		fmt.Sprintf(`UPDATE %s SET refresh_token_hash = NULL, revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, postgres.Sessions),
+		fmt.Sprintf(`UPDATE %s SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, postgres.PersonalAccessTokens),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, postgres.Tickets),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, postgres.AuthorizationCodes),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, postgres.DeviceAuthorizations),
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			r.log.Error(ctx, "increment user token epoch: revoke error", err.Error())
+			return 0, err
+		}
+	}
+
+	return epoch, tx.Commit()
+}
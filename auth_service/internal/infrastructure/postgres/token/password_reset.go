@@ -0,0 +1,74 @@
+package token
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/postgres"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type PasswordResetTokens struct {
+	db  *sqlx.DB
+	log *logger.SlogLogger
+}
+
+func NewPasswordResetTokenRepository(db *sqlx.DB, log *logger.SlogLogger) *PasswordResetTokens {
+	return &PasswordResetTokens{
+		db:  db,
+		log: log,
+	}
+}
+
+// CreatePasswordResetToken stores a new token, unless the user got one less
+// than interval ago, and drops the expired ones. Returns sql.ErrNoRows when
+// throttled.
+func (r *PasswordResetTokens) CreatePasswordResetToken(ctx context.Context, token domain.PasswordResetToken, interval time.Duration) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s (token_hash, user_id, created_at, expires_at)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (
+			SELECT 1 FROM %[1]s
+			WHERE user_id = $2 AND created_at > $3 - $5 * INTERVAL '1 second'
+		)
+	`, postgres.PasswordResetTokens)
+
+	res, err := r.db.ExecContext(ctx, query,
+		token.TokenHash, token.UserID, token.CreatedAt, token.ExpiresAt, interval.Seconds())
+	if err != nil {
+		r.log.Error(ctx, "create password reset token error", err.Error())
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	cleanup := fmt.Sprintf(`DELETE FROM %s WHERE expires_at <= NOW()`, postgres.PasswordResetTokens)
+	if _, err := r.db.ExecContext(ctx, cleanup); err != nil {
+		r.log.Error(ctx, "delete expired password reset tokens error", err.Error())
+	}
+
+	return nil
+}
+
+// ConsumePasswordResetToken deletes the token and returns it, so that a token
+// can be used only once even by concurrent requests. Returns sql.ErrNoRows for
+// an unknown or already used token.
+func (r *PasswordResetTokens) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (domain.PasswordResetToken, error) {
+	var token domain.PasswordResetToken
+
+	query := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE token_hash = $1
+		RETURNING token_hash, user_id, created_at, expires_at
+	`, postgres.PasswordResetTokens)
+
+	err := r.db.GetContext(ctx, &token, query, tokenHash)
+	if err != nil && err != sql.ErrNoRows {
+		r.log.Error(ctx, "consume password reset token error", err.Error())
+	}
+	return token, err
+}
@@ -0,0 +1,113 @@
+package token
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/postgres"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PersonalAccessTokens struct {
+	db  *sqlx.DB
+	log *logger.SlogLogger
+}
+
+func NewPersonalAccessTokenRepository(db *sqlx.DB, log *logger.SlogLogger) *PersonalAccessTokens {
+	return &PersonalAccessTokens{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *PersonalAccessTokens) CreatePersonalAccessToken(ctx context.Context, token domain.PersonalAccessToken) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, user_id, name, token_hash, scopes, created_at, expires_at)
+		VALUES (:id, :user_id, :name, :token_hash, :scopes, :created_at, :expires_at)
+	`, postgres.PersonalAccessTokens)
+
+	_, err := r.db.NamedExecContext(ctx, query, token)
+	if err != nil {
+		r.log.Error(ctx, "create personal access token error", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// ListPersonalAccessTokens returns the user's tokens that have not been
+// revoked, newest first. Expired tokens are included so they can be cleaned up.
+func (r *PersonalAccessTokens) ListPersonalAccessTokens(ctx context.Context, userID uuid.UUID) ([]domain.PersonalAccessToken, error) {
+	tokens := []domain.PersonalAccessToken{}
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, name, token_hash, scopes, created_at, expires_at, last_used_at, revoked_at
+		FROM %s
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`, postgres.PersonalAccessTokens)
+
+	err := r.db.SelectContext(ctx, &tokens, query, userID)
+	if err != nil {
+		r.log.Error(ctx, "list personal access tokens error", err.Error())
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// GetPersonalAccessTokenByHash finds a token that has not been revoked.
+func (r *PersonalAccessTokens) GetPersonalAccessTokenByHash(ctx context.Context, tokenHash string) (domain.PersonalAccessToken, error) {
+	var token domain.PersonalAccessToken
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, name, token_hash, scopes, created_at, expires_at, last_used_at, revoked_at
+		FROM %s
+		WHERE token_hash = $1 AND revoked_at IS NULL
+	`, postgres.PersonalAccessTokens)
+
+	err := r.db.GetContext(ctx, &token, query, tokenHash)
+	if err != nil && err != sql.ErrNoRows {
+		r.log.Error(ctx, "get personal access token error", err.Error())
+	}
+	return token, err
+}
+
+// TouchPersonalAccessToken records a use of the token. To avoid a write per
+// request, last_used_at is only moved forward once a minute.
+func (r *PersonalAccessTokens) TouchPersonalAccessToken(ctx context.Context, id uuid.UUID) error {
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`, postgres.PersonalAccessTokens)
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		r.log.Error(ctx, "touch personal access token error", err.Error())
+	}
+	return err
+}
+
+// RevokePersonalAccessToken revokes one of the user's tokens. Returns
+// sql.ErrNoRows if the user has no such live token.
+func (r *PersonalAccessTokens) RevokePersonalAccessToken(ctx context.Context, userID, id uuid.UUID) error {
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, postgres.PersonalAccessTokens)
+
+	res, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		r.log.Error(ctx, "revoke personal access token error", err.Error())
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
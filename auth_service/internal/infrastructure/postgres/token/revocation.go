@@ -0,0 +1,74 @@
+package token
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/postgres"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type Revocations struct {
+	db  *sqlx.DB
+	log *logger.SlogLogger
+}
+
+func NewRevocationRepository(db *sqlx.DB, log *logger.SlogLogger) *Revocations {
+	return &Revocations{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *Revocations) RevokeToken(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET expires_at = GREATEST(%[1]s.expires_at, EXCLUDED.expires_at)
+	`, postgres.RevokedTokens)
+
+	_, err := r.db.ExecContext(ctx, query, id, expiresAt)
+	if err != nil {
+		r.log.Error(ctx, "revoke token error", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// ListRevokedTokens returns the entries that have not expired yet.
+func (r *Revocations) ListRevokedTokens(ctx context.Context) ([]domain.RevokedToken, error) {
+	revoked := []domain.RevokedToken{}
+
+	query := fmt.Sprintf(`
+		SELECT id, expires_at
+		FROM %s
+		WHERE expires_at > NOW()
+	`, postgres.RevokedTokens)
+
+	err := r.db.SelectContext(ctx, &revoked, query)
+	if err != nil {
+		r.log.Error(ctx, "list revoked tokens error", err.Error())
+		return nil, err
+	}
+
+	return revoked, nil
+}
+
+func (r *Revocations) DeleteExpiredRevocations(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE expires_at <= NOW()
+	`, postgres.RevokedTokens)
+
+	res, err := r.db.ExecContext(ctx, query)
+	if err != nil {
+		r.log.Error(ctx, "delete expired revocations error", err.Error())
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
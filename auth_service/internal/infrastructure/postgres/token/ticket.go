@@ -0,0 +1,64 @@
+package token
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/postgres"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+)
+
+type Tickets struct {
+	db  *sqlx.DB
+	log *logger.SlogLogger
+}
+
+func NewTicketRepository(db *sqlx.DB, log *logger.SlogLogger) *Tickets {
+	return &Tickets{
+		db:  db,
+		log: log,
+	}
+}
+
+// CreateTicket stores a new ticket and drops the expired ones, which were
+// never redeemed.
+func (r *Tickets) CreateTicket(ctx context.Context, ticket domain.Ticket) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (ticket_hash, user_id, channel, created_at, expires_at)
+		VALUES (:ticket_hash, :user_id, :channel, :created_at, :expires_at)
+	`, postgres.Tickets)
+
+	_, err := r.db.NamedExecContext(ctx, query, ticket)
+	if err != nil {
+		r.log.Error(ctx, "create ticket error", err.Error())
+		return err
+	}
+
+	cleanup := fmt.Sprintf(`DELETE FROM %s WHERE expires_at <= NOW()`, postgres.Tickets)
+	if _, err := r.db.ExecContext(ctx, cleanup); err != nil {
+		r.log.Error(ctx, "delete expired tickets error", err.Error())
+	}
+
+	return nil
+}
+
+// ConsumeTicket deletes the ticket and returns it, so that a ticket can be
+// redeemed only once even by concurrent requests. Returns sql.ErrNoRows for an
+// unknown or already redeemed ticket.
+func (r *Tickets) ConsumeTicket(ctx context.Context, ticketHash string) (domain.Ticket, error) {
+	var ticket domain.Ticket
+
+	query := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE ticket_hash = $1
+		RETURNING ticket_hash, user_id, channel, created_at, expires_at
+	`, postgres.Tickets)
+
+	err := r.db.GetContext(ctx, &ticket, query, ticketHash)
+	if err != nil && err != sql.ErrNoRows {
+		r.log.Error(ctx, "consume ticket error", err.Error())
+	}
+	return ticket, err
+}
@@ -5,9 +5,12 @@ import (
 	"auth_service/internal/infrastructure/logger"
 	"auth_service/internal/infrastructure/postgres"
 	"context"
+	"database/sql"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
 )
 
 type Auth struct {
@@ -74,72 +77,159 @@ func (r *Auth) GetUserByUsername(ctx context.Context, username string) (domain.U
 	}
 	return user, err
 }
-func (r *Auth) SaveRefreshToken(ctx context.Context, userID uuid.UUID, token string) error {
-	query := fmt.Sprintf(`
-		UPDATE %s
-		SET refresh_token = $1
-		WHERE id = $2
-	`, postgres.Users)
 
-	_, err := r.db.ExecContext(ctx, query, token, userID)
+// GetUserByEmail looks a user up by email, ignoring case.
+func (r *Auth) GetUserByEmail(ctx context.Context, email string) (domain.User, error) {
+	var user domain.User
+	query := fmt.Sprintf("SELECT id, username, password_hash FROM %s WHERE LOWER(email) = LOWER($1)", postgres.Users)
+	err := r.db.QueryRowContext(ctx, query, email).Scan(&user.Id, &user.Username, &user.Password)
 	if err != nil {
-		r.log.Error(ctx, "save refresh token error", err.Error())
-		return err
+		r.log.Error(ctx, "postgres error", err.Error())
 	}
-
-	return nil
+	return user, err
 }
-
-func (r *Auth) GetRefreshToken(ctx context.Context, userID uuid.UUID) (string, error) {
-	var token string
+func (r *Auth) GetUserByID(ctx context.Context, userID uuid.UUID) (domain.User, error) {
+	var user domain.User
 
 	query := fmt.Sprintf(`
-		SELECT refresh_token
+		SELECT id, username, email, last_name, first_name, password_hash, roles, email_verified, locale, updated_at
 		FROM %s
 		WHERE id = $1
 	`, postgres.Users)
 
-	err := r.db.QueryRowContext(ctx, query, userID).Scan(&token)
+	err := r.db.QueryRowContext(ctx, query, userID).
+		Scan(&user.Id, &user.Username, &user.Email, &user.LastName, &user.FirstName, &user.Password,
+			&user.Roles, &user.EmailVerified, &user.Locale, &user.UpdatedAt)
+
 	if err != nil {
-		r.log.Error(ctx, "get refresh token error", err.Error())
-		return "", err
+		r.log.Error(ctx, "get user by id error", err.Error())
+		return domain.User{}, err
 	}
 
-	return token, nil
+	return user, nil
 }
 
-func (r *Auth) DeleteRefreshToken(ctx context.Context, userID uuid.UUID) error {
+// MarkEmailVerified marks the user's email as verified, provided it is still
+// email. Returns sql.ErrNoRows when the user changed address in the meantime.
+func (r *Auth) MarkEmailVerified(ctx context.Context, userID uuid.UUID, email string) error {
 	query := fmt.Sprintf(`
 		UPDATE %s
-		SET refresh_token = NULL
-		WHERE id = $1
+		SET email_verified = TRUE, updated_at = NOW()
+		WHERE id = $1 AND LOWER(email) = LOWER($2)
 	`, postgres.Users)
 
-	_, err := r.db.ExecContext(ctx, query, userID)
+	res, err := r.db.ExecContext(ctx, query, userID, email)
 	if err != nil {
-		r.log.Error(ctx, "delete refresh token error", err.Error())
+		r.log.Error(ctx, "mark email verified error", err.Error())
 		return err
 	}
-
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
 
-func (r *Auth) GetUserByID(ctx context.Context, userID uuid.UUID) (domain.User, error) {
-	var user domain.User
-
+// MarkVerificationEmailSent records that a verification email goes out now,
+// unless one went out less than interval ago or the email is already
+// verified. Returns sql.ErrNoRows in both cases.
+func (r *Auth) MarkVerificationEmailSent(ctx context.Context, userID uuid.UUID, interval time.Duration) error {
 	query := fmt.Sprintf(`
-		SELECT id, username, email, last_name, first_name 
-		FROM %s
+		UPDATE %s
+		SET email_verification_sent_at = NOW()
 		WHERE id = $1
+		  AND NOT email_verified
+		  AND (email_verification_sent_at IS NULL
+		       OR email_verification_sent_at < NOW() - $2 * INTERVAL '1 second')
 	`, postgres.Users)
 
-	err := r.db.QueryRowContext(ctx, query, userID).
-		Scan(&user.Id, &user.Username, &user.Email, &user.LastName, &user.FirstName)
+	res, err := r.db.ExecContext(ctx, query, userID, interval.Seconds())
+	if err != nil {
+		r.log.Error(ctx, "mark verification email sent error", err.Error())
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
 
+// UpdatePassword replaces the user's password hash and, in the same
+// transaction, revokes every credential obtained with the old one: reset
+// links, sessions, personal access tokens, tickets and pending authorization
+// and device codes. Returns the ids of the revoked sessions, whose access
+// tokens the caller still has to deny.
+func (r *Auth) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) ([]uuid.UUID, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
-		r.log.Error(ctx, "get user by id error", err.Error())
-		return domain.User{}, err
+		r.log.Error(ctx, "update password: begin tx error", err.Error())
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	return user, nil
+	query := fmt.Sprintf(`UPDATE %s SET password_hash = $2 WHERE id = $1`, postgres.Users)
+	res, err := tx.ExecContext(ctx, query, userID, passwordHash)
+	if err != nil {
+		r.log.Error(ctx, "update password error", err.Error())
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	sessions := []uuid.UUID{}
+	query = fmt.Sprintf(`
+		UPDATE %s
+		SET refresh_token_hash = NULL, revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+		RETURNING id
+	`, postgres.Sessions)
+	if err := tx.SelectContext(ctx, &sessions, query, userID); err != nil {
+		r.log.Error(ctx, "update password: revoke sessions error", err.Error())
+		return nil, err
+	}
+
+	for _, query := range []string{
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, postgres.PasswordResetTokens),
+		fmt.Sprintf(`UPDATE %s SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, postgres.PersonalAccessTokens),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, postgres.Tickets),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, postgres.AuthorizationCodes),
+		fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, postgres.DeviceAuthorizations),
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			r.log.Error(ctx, "update password: revoke error", err.Error())
+			return nil, err
+		}
+	}
+
+	return sessions, tx.Commit()
+}
+
+// UpdateProfile applies update, provided the profile was last changed at one
+// of versions, or whenever it was when versions is nil, and returns the new
+// profile. Returns sql.ErrNoRows when it was changed in the meantime.
+func (r *Auth) UpdateProfile(ctx context.Context, userID uuid.UUID, update domain.ProfileUpdate, versions []time.Time) (domain.User, error) {
+	var user domain.User
+
+	var updatedAt pq.StringArray
+	for _, version := range versions {
+		updatedAt = append(updatedAt, version.Format(time.RFC3339Nano))
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET first_name = COALESCE($3, first_name),
+		    last_name = COALESCE($4, last_name),
+		    locale = COALESCE($5, locale),
+		    updated_at = NOW()
+		WHERE id = $1 AND ($2::TIMESTAMPTZ[] IS NULL OR updated_at = ANY($2::TIMESTAMPTZ[]))
+		RETURNING id, username, email, last_name, first_name, roles, email_verified, locale, updated_at
+	`, postgres.Users)
+
+	err := r.db.QueryRowContext(ctx, query, userID, updatedAt, update.FirstName, update.LastName, update.Locale).
+		Scan(&user.Id, &user.Username, &user.Email, &user.LastName, &user.FirstName,
+			&user.Roles, &user.EmailVerified, &user.Locale, &user.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		r.log.Error(ctx, "update profile error", err.Error())
+	}
+	return user, err
 }
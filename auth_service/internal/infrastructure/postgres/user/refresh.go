@@ -0,0 +1,81 @@
+package user
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/postgres"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RotateRefreshToken marks the presented token as used and replaces the
+// session's refresh token hash with nextHash in one transaction. The primary key
+// on token_hash makes the check atomic: of two concurrent rotations of the same
+// token only one succeeds, the other gets domain.ErrRefreshTokenReused.
+func (r *Auth) RotateRefreshToken(ctx context.Context, used domain.UsedRefreshToken, nextHash string, nextExpiresAt time.Time, client domain.ClientInfo) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		r.log.Error(ctx, "rotate refresh token: begin tx error", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
+	insert := fmt.Sprintf(`
+		INSERT INTO %s (token_hash, family_id, user_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (token_hash) DO NOTHING
+	`, postgres.UsedRefreshTokens)
+
+	res, err := tx.ExecContext(ctx, insert, used.TokenHash, used.FamilyID, used.UserID, used.ExpiresAt)
+	if err != nil {
+		r.log.Error(ctx, "rotate refresh token: insert used error", err.Error())
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrRefreshTokenReused
+	}
+
+	update := fmt.Sprintf(`
+		UPDATE %s
+		SET refresh_token_hash = $1, refresh_expires_at = $2, last_used_at = NOW(), user_agent = $3, ip = $4
+		WHERE id = $5 AND refresh_token_hash = $6 AND revoked_at IS NULL
+	`, postgres.Sessions)
+
+	res, err = tx.ExecContext(ctx, update, nextHash, nextExpiresAt, client.UserAgent, client.IP, used.FamilyID, used.TokenHash)
+	if err != nil {
+		r.log.Error(ctx, "rotate refresh token: update error", err.Error())
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	cleanup := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE user_id = $1 AND expires_at < NOW()
+	`, postgres.UsedRefreshTokens)
+
+	if _, err := tx.ExecContext(ctx, cleanup, used.UserID); err != nil {
+		r.log.Error(ctx, "rotate refresh token: cleanup error", err.Error())
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Auth) GetUsedRefreshToken(ctx context.Context, tokenHash string) (domain.UsedRefreshToken, error) {
+	var used domain.UsedRefreshToken
+
+	query := fmt.Sprintf(`
+		SELECT token_hash, family_id, user_id, used_at, expires_at
+		FROM %s
+		WHERE token_hash = $1
+	`, postgres.UsedRefreshTokens)
+
+	err := r.db.GetContext(ctx, &used, query, tokenHash)
+	if err != nil && err != sql.ErrNoRows {
+		r.log.Error(ctx, "get used refresh token error", err.Error())
+	}
+	return used, err
+}
@@ -0,0 +1,135 @@
+package user
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/postgres"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func (r *Auth) CreateSession(ctx context.Context, session domain.Session) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, user_id, refresh_token_hash, refresh_expires_at, remember_me, user_agent, ip, expires_at, client_id, scopes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, postgres.Sessions)
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		session.Id,
+		session.UserID,
+		session.RefreshTokenHash,
+		session.RefreshExpiresAt,
+		session.RememberMe,
+		session.UserAgent,
+		session.IP,
+		session.ExpiresAt,
+		session.ClientID,
+		pq.StringArray(session.Scopes),
+	)
+	if err != nil {
+		r.log.Error(ctx, "create session error", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (r *Auth) GetSession(ctx context.Context, sessionID uuid.UUID) (domain.Session, error) {
+	var session domain.Session
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, refresh_token_hash, refresh_expires_at, remember_me, user_agent, ip, created_at, last_used_at, expires_at, revoked_at, client_id, scopes
+		FROM %s
+		WHERE id = $1
+	`, postgres.Sessions)
+
+	err := r.db.GetContext(ctx, &session, query, sessionID)
+	if err != nil && err != sql.ErrNoRows {
+		r.log.Error(ctx, "get session error", err.Error())
+	}
+	return session, err
+}
+
+// GetSessionByRefreshTokenHash finds the active session whose current refresh
+// token has the given hash.
+func (r *Auth) GetSessionByRefreshTokenHash(ctx context.Context, tokenHash string) (domain.Session, error) {
+	var session domain.Session
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, refresh_token_hash, refresh_expires_at, remember_me, user_agent, ip, created_at, last_used_at, expires_at, revoked_at, client_id, scopes
+		FROM %s
+		WHERE refresh_token_hash = $1
+	`, postgres.Sessions)
+
+	err := r.db.GetContext(ctx, &session, query, tokenHash)
+	if err != nil && err != sql.ErrNoRows {
+		r.log.Error(ctx, "get session by refresh token error", err.Error())
+	}
+	return session, err
+}
+
+// ListSessions returns the user's sessions that can still be refreshed: not
+// revoked, not idle for too long and not past their absolute end. Most recently
+// used first.
+func (r *Auth) ListSessions(ctx context.Context, userID uuid.UUID) ([]domain.Session, error) {
+	sessions := []domain.Session{}
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, refresh_token_hash, refresh_expires_at, remember_me, user_agent, ip, created_at, last_used_at, expires_at, revoked_at, client_id, scopes
+		FROM %s
+		WHERE user_id = $1 AND revoked_at IS NULL AND refresh_expires_at > NOW() AND expires_at > NOW()
+		ORDER BY last_used_at DESC
+	`, postgres.Sessions)
+
+	err := r.db.SelectContext(ctx, &sessions, query, userID)
+	if err != nil {
+		r.log.Error(ctx, "list sessions error", err.Error())
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession returns sql.ErrNoRows when the user has no such active session.
+func (r *Auth) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET refresh_token_hash = NULL, revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, postgres.Sessions)
+
+	res, err := r.db.ExecContext(ctx, query, sessionID, userID)
+	if err != nil {
+		r.log.Error(ctx, "revoke session error", err.Error())
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// RevokeAllSessions returns the ids of the sessions it revoked.
+func (r *Auth) RevokeAllSessions(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET refresh_token_hash = NULL, revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+		RETURNING id
+	`, postgres.Sessions)
+
+	err := r.db.SelectContext(ctx, &ids, query, userID)
+	if err != nil {
+		r.log.Error(ctx, "revoke all sessions error", err.Error())
+		return nil, err
+	}
+
+	return ids, nil
+}
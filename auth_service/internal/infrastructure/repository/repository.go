@@ -3,29 +3,106 @@ package repository
 import (
 	"auth_service/internal/domain"
 	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/postgres/client"
+	"auth_service/internal/infrastructure/postgres/event"
+	"auth_service/internal/infrastructure/postgres/token"
 	"auth_service/internal/infrastructure/postgres/user"
 	"context"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"time"
 )
 
 type Auth interface {
 	CreateUser(ctx context.Context, user domain.User) (uuid.UUID, error)
 	GetUser(ctx context.Context, username, password string) (domain.User, error)
 	GetUserByUsername(ctx context.Context, username string) (domain.User, error)
+	GetUserByEmail(ctx context.Context, email string) (domain.User, error)
 
-	SaveRefreshToken(ctx context.Context, id uuid.UUID, refresh string) error
-	GetRefreshToken(ctx context.Context, id uuid.UUID) (string, error)
-	DeleteRefreshToken(ctx context.Context, id uuid.UUID) error
 	GetUserByID(ctx context.Context, id uuid.UUID) (domain.User, error)
+	MarkEmailVerified(ctx context.Context, userID uuid.UUID, email string) error
+	MarkVerificationEmailSent(ctx context.Context, userID uuid.UUID, interval time.Duration) error
+	UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) ([]uuid.UUID, error)
+	UpdateProfile(ctx context.Context, userID uuid.UUID, update domain.ProfileUpdate, versions []time.Time) (domain.User, error)
+
+	CreateSession(ctx context.Context, session domain.Session) error
+	GetSession(ctx context.Context, id uuid.UUID) (domain.Session, error)
+	GetSessionByRefreshTokenHash(ctx context.Context, tokenHash string) (domain.Session, error)
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]domain.Session, error)
+	RevokeSession(ctx context.Context, userID, id uuid.UUID) error
+	RevokeAllSessions(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+
+	RotateRefreshToken(ctx context.Context, used domain.UsedRefreshToken, nextHash string, nextExpiresAt time.Time, client domain.ClientInfo) error
+	GetUsedRefreshToken(ctx context.Context, tokenHash string) (domain.UsedRefreshToken, error)
+}
+
+type SecurityEvents interface {
+	CreateSecurityEvent(ctx context.Context, event domain.SecurityEvent) error
+}
+
+type Clients interface {
+	CreateClient(ctx context.Context, client domain.Client) error
+	GetClientByClientID(ctx context.Context, clientID string) (domain.Client, error)
+}
+
+type AuthorizationCodes interface {
+	CreateAuthorizationCode(ctx context.Context, code domain.AuthorizationCode) error
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) (domain.AuthorizationCode, error)
+}
+
+type PersonalAccessTokens interface {
+	CreatePersonalAccessToken(ctx context.Context, token domain.PersonalAccessToken) error
+	ListPersonalAccessTokens(ctx context.Context, userID uuid.UUID) ([]domain.PersonalAccessToken, error)
+	GetPersonalAccessTokenByHash(ctx context.Context, tokenHash string) (domain.PersonalAccessToken, error)
+	TouchPersonalAccessToken(ctx context.Context, id uuid.UUID) error
+	RevokePersonalAccessToken(ctx context.Context, userID, id uuid.UUID) error
+}
+
+type DeviceAuthorizations interface {
+	CreateDeviceAuthorization(ctx context.Context, auth domain.DeviceAuthorization) error
+	GetDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (domain.DeviceAuthorization, error)
+	PollDeviceAuthorization(ctx context.Context, deviceCodeHash string) (domain.DeviceAuthorization, error)
+	SlowDownDeviceAuthorization(ctx context.Context, deviceCodeHash string, step time.Duration) (int, error)
+	DecideDeviceAuthorization(ctx context.Context, userCode string, userID uuid.UUID, status string) error
+	DeleteDeviceAuthorization(ctx context.Context, deviceCodeHash string) error
+}
+
+type Capabilities interface {
+	RedeemCapability(ctx context.Context, capability domain.Capability) error
+}
+
+type Tickets interface {
+	CreateTicket(ctx context.Context, ticket domain.Ticket) error
+	ConsumeTicket(ctx context.Context, ticketHash string) (domain.Ticket, error)
+}
+
+type PasswordResetTokens interface {
+	CreatePasswordResetToken(ctx context.Context, token domain.PasswordResetToken, interval time.Duration) error
+	ConsumePasswordResetToken(ctx context.Context, tokenHash string) (domain.PasswordResetToken, error)
 }
 
 type Repository struct {
 	Auth
+	SecurityEvents
+	Clients
+	AuthorizationCodes
+	PersonalAccessTokens
+	DeviceAuthorizations
+	Capabilities
+	Tickets
+	PasswordResetTokens
 }
 
 func NewRepository(db *sqlx.DB, log *logger.SlogLogger) *Repository {
 	return &Repository{
-		Auth: user.NewAuthRepository(db, log),
+		Auth:                 user.NewAuthRepository(db, log),
+		SecurityEvents:       event.NewSecurityRepository(db, log),
+		Clients:              client.NewClientRepository(db, log),
+		AuthorizationCodes:   token.NewAuthorizationCodeRepository(db, log),
+		PersonalAccessTokens: token.NewPersonalAccessTokenRepository(db, log),
+		DeviceAuthorizations: token.NewDeviceAuthorizationRepository(db, log),
+		Capabilities:         token.NewCapabilityRepository(db, log),
+		Tickets:              token.NewTicketRepository(db, log),
+		PasswordResetTokens:  token.NewPasswordResetTokenRepository(db, log),
 	}
 }
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"auth_service/internal/usecase/auth"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// @Summary Revoke every token of a user
+// @Description Break-glass revocation for a compromised account. Increments the user's token epoch, so every access token the user holds stops working, and ends the user's sessions, personal access tokens and tickets. Requires the admin role.
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} TokenEpochResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/users/{id}/revoke-tokens [post]
+func (h *Handler) revokeUserTokens(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	epoch, err := h.service.Auth.RevokeUserTokens(ctx, userID)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		NewErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	adminID, _ := getUserId(c)
+	h.log.Warn(ctx, "admin revoked user tokens", "admin_id", adminID.String(), "user_id", userID.String())
+
+	c.JSON(http.StatusOK, TokenEpochResponse{UserID: userID.String(), Epoch: epoch})
+}
+
+// @Summary Revoke every token
+// @Description Break-glass revocation of every outstanding token. Increments the global token epoch, so every access token of every user and service stops working, and ends every session, personal access token and ticket. Everyone, including the caller, has to log in again. This does not help against a leaked signing key on its own: whoever holds the key can forge tokens carrying the new epoch, so rotate the key as well. Requires the admin role.
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} TokenEpochResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /admin/revoke-tokens [post]
+func (h *Handler) revokeAllTokens(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	epoch, err := h.service.Auth.RevokeAllTokens(ctx)
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	adminID, _ := getUserId(c)
+	h.log.Warn(ctx, "admin revoked all tokens", "admin_id", adminID.String())
+
+	c.JSON(http.StatusOK, TokenEpochResponse{Epoch: epoch})
+}
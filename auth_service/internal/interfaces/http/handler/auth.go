@@ -2,24 +2,35 @@ package handler
 
 import (
 	"auth_service/internal/domain"
+	"auth_service/internal/usecase/auth"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // RegisterInput represents user registration payload
 type RegisterInput struct {
-	Username  string `json:"username" binding:"required" example:"john_doe"`
+	Username  string `json:"username" binding:"required,max=255" example:"john_doe"`
 	Email     string `json:"email" binding:"required,email" example:"john@example.com"`
 	Password  string `json:"password" binding:"required,min=6" example:"password123"`
-	FirstName string `json:"first_name" binding:"required" example:"John"`
-	LastName  string `json:"last_name" binding:"required" example:"Doe"`
+	FirstName string `json:"first_name" binding:"required,max=255" example:"John"`
+	LastName  string `json:"last_name" binding:"required,max=255" example:"Doe"`
 }
 
-// LoginInput represents user login payload
+// LoginInput represents user login payload. Either email or username
+// identifies the user; email is matched case-insensitively.
 type LoginInput struct {
-	Username string `json:"username" binding:"required" example:"john_doe"`
+	Email    string `json:"email,omitempty" binding:"required_without=Username,omitempty,email" example:"john@example.com"`
+	Username string `json:"username,omitempty" binding:"required_without=Email" example:"john_doe"`
 	Password string `json:"password" binding:"required" example:"password123"`
+	// Audience lists the services the access token is meant for. The
+	// configured default audience is used when empty.
+	Audience []string `json:"audience,omitempty" example:"content-service"`
+	// RememberMe asks for a long session instead of a browser session.
+	RememberMe bool `json:"remember_me,omitempty" example:"true"`
 }
 
 // @Summary Register new user
@@ -49,6 +60,10 @@ func (h *Handler) signUp(c *gin.Context) {
 		FirstName: input.FirstName,
 		LastName:  input.LastName,
 	})
+	if errors.Is(err, auth.ErrWeakPassword) {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -61,13 +76,14 @@ func (h *Handler) signUp(c *gin.Context) {
 }
 
 // @Summary Login user
-// @Description Authenticate user and return access and refresh tokens
+// @Description Authenticate user by email or username and return access and refresh tokens
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param input body LoginInput true "Login input"
 // @Success 200 {object} LoginResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /auth/login [post]
 func (h *Handler) signIn(c *gin.Context) {
@@ -78,7 +94,20 @@ func (h *Handler) signIn(c *gin.Context) {
 		NewErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	at, rt, err := h.service.Login(ctx, input.Username, input.Password)
+	login := input.Email
+	if login == "" {
+		login = input.Username
+	}
+
+	at, rt, err := h.service.Login(ctx, login, input.Password, clientInfo(c), input.Audience, input.RememberMe)
+	if errors.Is(err, domain.ErrInvalidAudience) {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
 	if err != nil {
 		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -92,7 +121,8 @@ func (h *Handler) signIn(c *gin.Context) {
 
 // RefreshInput represents refresh token payload
 type RefreshInput struct {
-	RefreshToken string `json:"refresh_token" binding:"required" example:"your_refresh_token"`
+	RefreshToken string   `json:"refresh_token" binding:"required" example:"your_refresh_token"`
+	Audience     []string `json:"audience,omitempty" example:"content-service"`
 }
 
 // MeResponse represents current user response
@@ -102,6 +132,12 @@ type MeResponse struct {
 	Email     string `json:"email" example:"john@example.com"`
 	FirstName string `json:"first_name" example:"Aibar"`
 	LastName  string `json:"last_name" example:"Tlekbay"`
+
+	Roles         []string `json:"roles" example:"user"`
+	EmailVerified bool     `json:"email_verified" example:"false"`
+	Locale        string   `json:"locale" example:"kk-KZ"`
+	// Scopes is only set when the request used a personal access token.
+	Scopes []string `json:"scopes,omitempty" example:"lectures:read"`
 }
 
 // @Summary Refresh tokens
@@ -123,7 +159,11 @@ func (h *Handler) refresh(c *gin.Context) {
 		return
 	}
 
-	at, rt, err := h.service.Auth.Refresh(ctx, input.RefreshToken)
+	at, rt, err := h.service.Auth.Refresh(ctx, input.RefreshToken, "", clientInfo(c), input.Audience)
+	if errors.Is(err, domain.ErrInvalidAudience) {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
 		return
@@ -136,7 +176,7 @@ func (h *Handler) refresh(c *gin.Context) {
 }
 
 // @Summary Logout user
-// @Description Logout user by deleting refresh token
+// @Description Logout the current device by revoking its session
 // @Tags auth
 // @Security BearerAuth
 // @Produce json
@@ -146,45 +186,182 @@ func (h *Handler) refresh(c *gin.Context) {
 func (h *Handler) logout(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	token := c.GetHeader("Authorization")
-	token = strings.TrimPrefix(token, "Bearer ")
-
-	err := h.service.Auth.Logout(ctx, token)
+	identity, err := getIdentity(c)
 	if err != nil {
 		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
 		return
 	}
 
+	if err := h.service.Auth.Logout(ctx, identity); err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "logged out",
 	})
 }
 
 // @Summary Get current user
-// @Description Get user info from access token
+// @Description Get user info from access token. The ETag header is the version to send in If-Match when updating the profile.
 // @Tags auth
 // @Security BearerAuth
 // @Produce json
 // @Success 200 {object} MeResponse
+// @Header 200 {string} ETag "Profile version"
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Router /auth/me [get]
 func (h *Handler) me(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	token := c.GetHeader("Authorization")
-	token = strings.TrimPrefix(token, "Bearer ")
+	userID, err := getUserId(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
 
-	user, err := h.service.Auth.Me(ctx, token)
+	user, err := h.service.Auth.Me(ctx, userID)
 	if err != nil {
 		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, MeResponse{
+	var scopes []string
+	if identity, _ := getIdentity(c); identity.IsPersonalAccessToken() {
+		scopes = identity.Scopes
+	}
+	c.Header("ETag", profileETag(user.UpdatedAt))
+	c.JSON(http.StatusOK, newMeResponse(user, scopes))
+}
+
+// UpdateProfileInput lists the profile fields to change; omitted fields keep
+// their value. An empty locale clears it.
+type UpdateProfileInput struct {
+	FirstName *string `json:"first_name,omitempty" binding:"omitnil,min=1,max=255" example:"John"`
+	LastName  *string `json:"last_name,omitempty" binding:"omitnil,min=1,max=255" example:"Doe"`
+	Locale    *string `json:"locale,omitempty" binding:"omitnil,max=35,eq=|bcp47_language_tag" example:"kk-KZ"`
+}
+
+// @Summary Update current user
+// @Description Change the editable profile fields. If-Match must carry the ETag of the profile the edit is based on, alone or in a comma-separated list; if none of them is the current version, e.g. because another tab changed the profile, nothing is written and 412 is returned. Weak ETags never match. If-Match: * applies the edit to the current profile, whatever its version. Malformed values are rejected with 400. Access tokens carry the new values from the next refresh.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param If-Match header string true "ETag from GET /auth/me, or *"
+// @Param input body UpdateProfileInput true "Fields to change"
+// @Success 200 {object} MeResponse
+// @Header 200 {string} ETag "New profile version"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 428 {object} ErrorResponse
+// @Router /auth/me [patch]
+func (h *Handler) updateMe(c *gin.Context) {
+	userID, err := getUserId(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		NewErrorResponse(c, http.StatusPreconditionRequired, "If-Match header with the ETag of the profile is required")
+		return
+	}
+
+	var input UpdateProfileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	versions, err := parseIfMatch(ifMatch)
+	if errors.Is(err, errNoProfileVersion) {
+		NewErrorResponse(c, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	if err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.service.Auth.UpdateProfile(c.Request.Context(), userID, domain.ProfileUpdate{
+		FirstName: input.FirstName,
+		LastName:  input.LastName,
+		Locale:    input.Locale,
+	}, versions)
+	if errors.Is(err, auth.ErrProfileModified) {
+		NewErrorResponse(c, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Header("ETag", profileETag(user.UpdatedAt))
+	c.JSON(http.StatusOK, newMeResponse(&user, nil))
+}
+
+func newMeResponse(user *domain.User, scopes []string) MeResponse {
+	return MeResponse{
 		ID:        user.Id.String(),
 		Username:  user.Username,
 		Email:     user.Email,
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
-	})
+
+		Roles:         user.Roles,
+		EmailVerified: user.EmailVerified,
+		Locale:        user.Locale,
+		Scopes:        scopes,
+	}
+}
+
+// profileETag is the strong ETag of a profile last changed at updatedAt. The
+// database keeps microseconds, so that is the precision used.
+func profileETag(updatedAt time.Time) string {
+	return strconv.Quote(strconv.FormatInt(updatedAt.UnixMicro(), 10))
+}
+
+// errNoProfileVersion is returned by parseIfMatch when If-Match lists no
+// strong ETag, so no version of the profile can match it.
+var errNoProfileVersion = errors.New("If-Match carries no strong ETag of GET /auth/me")
+
+// parseIfMatch returns the updatedAt values the ETags of an If-Match list were
+// made from, or nil for "*", which matches any current profile. Profile ETags
+// are strong, so weak ones never match (RFC 9110 section 13.1.1) and are
+// skipped like strong ones this server did not issue; errNoProfileVersion is
+// returned when nothing is left. Values that are no ETag at all are refused.
+func parseIfMatch(ifMatch string) ([]time.Time, error) {
+	if strings.TrimSpace(ifMatch) == "*" {
+		return nil, nil
+	}
+	var versions []time.Time
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		weak := strings.HasPrefix(tag, "W/")
+		opaque := strings.TrimPrefix(tag, "W/")
+		if len(opaque) < 2 || opaque[0] != '"' || opaque[len(opaque)-1] != '"' {
+			return nil, errors.New("If-Match must carry ETags of GET /auth/me, or *")
+		}
+		if weak {
+			continue
+		}
+		micros, err := strconv.ParseInt(opaque[1:len(opaque)-1], 10, 64)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, time.UnixMicro(micros))
+	}
+	if len(versions) == 0 {
+		return nil, errNoProfileVersion
+	}
+	return versions, nil
 }
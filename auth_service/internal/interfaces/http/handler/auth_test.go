@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	updatedAt := time.Date(2026, 10, 17, 23, 50, 0, 123456000, time.FixedZone("ALMT", 5*3600))
+	etag := profileETag(updatedAt)
+	older := updatedAt.Add(-time.Hour)
+
+	tests := []struct {
+		name      string
+		ifMatch   string
+		want      []time.Time
+		wantErr   error
+		malformed bool
+	}{
+		{name: "profile ETag", ifMatch: etag, want: []time.Time{updatedAt}},
+		{name: "surrounding space", ifMatch: " " + etag + " ", want: []time.Time{updatedAt}},
+		{name: "any version", ifMatch: "*"},
+		{name: "list", ifMatch: profileETag(older) + ", " + etag, want: []time.Time{older, updatedAt}},
+		{name: "list without spaces", ifMatch: profileETag(older) + "," + etag, want: []time.Time{older, updatedAt}},
+		{name: "weak ETag in a list", ifMatch: "W/" + profileETag(older) + ", " + etag, want: []time.Time{updatedAt}},
+		{name: "foreign ETag in a list", ifMatch: `"abc", ` + etag, want: []time.Time{updatedAt}},
+		{name: "empty list elements", ifMatch: ", " + etag + ",", want: []time.Time{updatedAt}},
+		{name: "weak ETag", ifMatch: "W/" + etag, wantErr: errNoProfileVersion},
+		{name: "foreign ETag", ifMatch: `"abc"`, wantErr: errNoProfileVersion},
+		{name: "unquoted", ifMatch: "1792263000123456", malformed: true},
+		{name: "half quoted", ifMatch: `1792263000123456"`, malformed: true},
+		{name: "single quote", ifMatch: `"`, malformed: true},
+		{name: "malformed tag in a list", ifMatch: etag + ", abc", malformed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseIfMatch(tt.ifMatch)
+			if tt.malformed {
+				if err == nil || errors.Is(err, errNoProfileVersion) {
+					t.Fatalf("parseIfMatch(%q) error = %v, want a malformed header error", tt.ifMatch, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseIfMatch(%q) error = %v, want %v", tt.ifMatch, err, tt.wantErr)
+			}
+			if !slices.EqualFunc(got, tt.want, time.Time.Equal) || (got == nil) != (tt.want == nil) {
+				t.Errorf("parseIfMatch(%q) = %v, want %v", tt.ifMatch, got, tt.want)
+			}
+		})
+	}
+}
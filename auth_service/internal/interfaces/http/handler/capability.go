@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/usecase/auth"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// CreateCapabilityInput names the file a single-use token is requested for
+type CreateCapabilityInput struct {
+	FileKey string `json:"file_key" binding:"required,max=1024" example:"lectures/42/slides.pdf"`
+	Action  string `json:"action" binding:"required" example:"download"`
+}
+
+// RedeemCapabilityInput is a capability token presented to a download proxy
+type RedeemCapabilityInput struct {
+	Token   string `json:"token" binding:"required"`
+	FileKey string `json:"file_key" binding:"required" example:"lectures/42/slides.pdf"`
+	Action  string `json:"action" binding:"required" example:"download"`
+}
+
+// @Summary Create capability token
+// @Description Issue a single-use token, valid for 5 minutes, allowing the current user to perform one action on one stored file. The frontend puts it in a direct download link. Actions: download
+// @Tags capabilities
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body CreateCapabilityInput true "File and action"
+// @Success 201 {object} CapabilityTokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /auth/capabilities [post]
+func (h *Handler) createCapability(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := getUserId(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var input CreateCapabilityInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	capability, token, err := h.service.Auth.CreateCapability(ctx, userID, input.FileKey, input.Action)
+	if errors.Is(err, auth.ErrUnknownAction) {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, CapabilityTokenResponse{
+		Token:     token,
+		FileKey:   capability.FileKey,
+		Action:    capability.Action,
+		ExpiresAt: capability.ExpiresAt,
+	})
+}
+
+// @Summary Redeem capability token
+// @Description Called by a download proxy before serving a file. Checks that the token grants the action on the file and uses it up, atomically: a token is accepted once. The proxy must still check that the returned user may access the file. Authenticate with the client's credentials (HTTP Basic).
+// @Tags capabilities
+// @Accept json
+// @Produce json
+// @Param input body RedeemCapabilityInput true "Token, file and action"
+// @Success 200 {object} CapabilityResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} OAuthErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /capabilities/redeem [post]
+func (h *Handler) redeemCapability(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var input RedeemCapabilityInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	capability, err := h.service.Auth.RedeemCapability(ctx, input.Token, input.FileKey, input.Action)
+	if errors.Is(err, auth.ErrInvalidCapability) {
+		NewErrorResponse(c, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	client := c.MustGet(clientCtx).(domain.Client)
+	h.log.Info(ctx, "capability redeemed",
+		"client_id", client.ClientID, "user_id", capability.UserID.String(), "file_key", capability.FileKey)
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, CapabilityResponse{
+		UserID:  capability.UserID.String(),
+		FileKey: capability.FileKey,
+		Action:  capability.Action,
+	})
+}
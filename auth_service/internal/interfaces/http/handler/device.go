@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/usecase/oidc"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DeviceDecisionInput represents the user's answer to a device authorization
+type DeviceDecisionInput struct {
+	UserCode string `json:"user_code" binding:"required" example:"WDJB-MJHT"`
+	Approve  bool   `json:"approve" example:"true"`
+}
+
+// @Summary Device authorization endpoint
+// @Description RFC 8628 device flow for clients without a browser. Returns a user code to show to the user and a device code to poll the token endpoint with (grant_type=urn:ietf:params:oauth:grant-type:device_code). Public clients send client_id only.
+// @Tags oauth2
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param client_id formData string false "Client id (public clients)"
+// @Param scope formData string false "e.g. openid profile"
+// @Success 200 {object} DeviceAuthorizationResponse
+// @Failure 400 {object} OAuthErrorResponse
+// @Failure 401 {object} OAuthErrorResponse
+// @Router /oauth2/device_authorization [post]
+func (h *Handler) deviceAuthorization(c *gin.Context) {
+	client := c.MustGet(clientCtx).(domain.Client)
+
+	result, err := h.service.OIDC.DeviceAuthorization(c.Request.Context(), client, strings.Fields(c.PostForm("scope")))
+	if errors.Is(err, oidc.ErrInvalidScope) {
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	}
+	if err != nil {
+		NewOAuthErrorResponse(c, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, DeviceAuthorizationResponse{
+		DeviceCode:              result.DeviceCode,
+		UserCode:                result.UserCode,
+		VerificationURI:         result.VerificationURI,
+		VerificationURIComplete: result.VerificationURIComplete,
+		ExpiresIn:               int64(time.Until(result.ExpiresAt).Seconds()),
+		Interval:                int64(result.Interval.Seconds()),
+	})
+}
+
+func (h *Handler) deviceCodeGrant(c *gin.Context, client domain.Client) {
+	result, err := h.service.OIDC.ExchangeDeviceCode(c.Request.Context(), client, c.PostForm("device_code"), clientInfo(c))
+	switch {
+	case errors.Is(err, oidc.ErrAuthorizationPending):
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "authorization_pending", err.Error())
+		return
+	case errors.Is(err, oidc.ErrSlowDown):
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "slow_down", err.Error())
+		return
+	case errors.Is(err, oidc.ErrAccessDenied):
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "access_denied", err.Error())
+		return
+	case errors.Is(err, oidc.ErrExpiredToken):
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "expired_token", err.Error())
+		return
+	case errors.Is(err, oidc.ErrInvalidGrant):
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	case err != nil:
+		NewOAuthErrorResponse(c, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken:  result.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(result.ExpiresAt).Seconds()),
+		RefreshToken: result.RefreshToken,
+		IDToken:      result.IDToken,
+		Scope:        strings.Join(result.Scopes, " "),
+	})
+}
+
+// @Summary Look up device code
+// @Description Shows which client a device flow user code belongs to, so the user can check it before approving
+// @Tags device
+// @Security BearerAuth
+// @Produce json
+// @Param user_code query string true "User code shown by the device"
+// @Success 200 {object} DeviceResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /auth/device [get]
+func (h *Handler) lookupDevice(c *gin.Context) {
+	auth, client, err := h.service.OIDC.LookupDevice(c.Request.Context(), c.Query("user_code"))
+	if errors.Is(err, oidc.ErrInvalidUserCode) {
+		NewErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, DeviceResponse{
+		ClientID:   client.ClientID,
+		ClientName: client.Name,
+		Scopes:     auth.Scopes,
+		ExpiresAt:  auth.ExpiresAt,
+	})
+}
+
+// @Summary Approve or deny device
+// @Description Approve or deny a device flow request as the current user. The device receives tokens for a new session on its next poll.
+// @Tags device
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body DeviceDecisionInput true "Decision"
+// @Success 200 {object} StatusResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /auth/device [post]
+func (h *Handler) decideDevice(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := getUserId(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var input DeviceDecisionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = h.service.OIDC.DecideDevice(ctx, userID, input.UserCode, input.Approve)
+	if errors.Is(err, oidc.ErrInvalidUserCode) {
+		NewErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, StatusResponse{Status: "ok"})
+}
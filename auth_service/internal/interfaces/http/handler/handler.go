@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"auth_service/internal/domain"
 	"auth_service/internal/infrastructure/logger"
 	"auth_service/internal/usecase"
 	"github.com/gin-contrib/cors"
@@ -13,10 +14,13 @@ import (
 type Handler struct {
 	service *usecase.Service
 	log     *logger.SlogLogger
+
+	// audiences are the access token audiences accepted on protected routes.
+	audiences []string
 }
 
-func NewHandler(service *usecase.Service, log *logger.SlogLogger) *Handler {
-	return &Handler{service: service, log: log}
+func NewHandler(service *usecase.Service, log *logger.SlogLogger, audiences []string) *Handler {
+	return &Handler{service: service, log: log, audiences: audiences}
 }
 
 func (h *Handler) InitRouter() *gin.Engine {
@@ -26,11 +30,26 @@ func (h *Handler) InitRouter() *gin.Engine {
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(files.Handler))
 
 	r.Use(cors.New(cors.Config{
-		AllowOrigins: []string{"http://localhost:3000"},
-		AllowMethods: []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders: []string{"Authorization", "Content-Type"},
+		AllowOrigins:  []string{"http://localhost:3000"},
+		AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowHeaders:  []string{"Authorization", "Content-Type", "If-Match"},
+		ExposeHeaders: []string{"ETag"},
 	}))
 
+	r.GET("/.well-known/jwks.json", h.jwks)
+	r.GET("/.well-known/openid-configuration", h.openidConfiguration)
+
+	oauth := r.Group("/oauth2")
+	{
+		oauth.GET("/authorize", h.authorize)
+		oauth.POST("/authorize", h.authorizeLogin)
+		oauth.POST("/token", h.tokenClientIdentity, h.token)
+		oauth.POST("/device_authorization", h.tokenClientIdentity, h.deviceAuthorization)
+		oauth.POST("/introspect", h.clientIdentity, h.introspect)
+		oauth.GET("/userinfo", h.userIdentity, h.userInfoScope, h.userinfo)
+		oauth.POST("/userinfo", h.userIdentity, h.userInfoScope, h.userinfo)
+	}
+
 	api := r.Group("/api/v1")
 
 	auth := api.Group("/auth")
@@ -39,15 +58,54 @@ func (h *Handler) InitRouter() *gin.Engine {
 		auth.POST("/register", h.signUp)
 		auth.POST("/login", h.signIn)
 		auth.POST("/refresh", h.refresh)
+		auth.POST("/verify-email", h.verifyEmail)
+		auth.POST("/password/forgot", h.forgotPassword)
+		auth.POST("/password/reset", h.resetPassword)
 
 		// PROTECTED
 		protected := auth.Group("/")
 		protected.Use(h.userIdentity)
 		{
-			protected.POST("/logout", h.logout)
-			protected.GET("/me", h.me)
+			protected.GET("/me", h.requireScope(domain.ScopeProfileRead), h.me)
+			protected.POST("/capabilities", h.requireScope(domain.ScopeLecturesRead), h.verifiedEmail, h.createCapability)
+		}
+
+		// Account management needs a login session, not a personal access token
+		account := protected.Group("/")
+		account.Use(h.sessionOnly)
+		{
+			account.POST("/logout", h.logout)
+			account.POST("/logout/all", h.logoutAll)
+
+			account.POST("/password", h.changePassword)
+			account.PATCH("/me", h.updateMe)
+
+			account.GET("/sessions", h.listSessions)
+			account.DELETE("/sessions/:id", h.revokeSession)
+
+			account.POST("/tokens", h.verifiedEmail, h.createToken)
+			account.GET("/tokens", h.listTokens)
+			account.DELETE("/tokens/:id", h.revokeToken)
+
+			account.GET("/device", h.lookupDevice)
+			account.POST("/device", h.verifiedEmail, h.decideDevice)
+
+			account.POST("/ticket", h.verifiedEmail, h.createTicket)
+
+			account.POST("/verify-email/resend", h.resendVerificationEmail)
 		}
 	}
 
+	admin := api.Group("/admin")
+	admin.Use(h.userIdentity, h.sessionOnly, h.requireRole(domain.RoleAdmin))
+	{
+		admin.POST("/users/:id/revoke-tokens", h.revokeUserTokens)
+		admin.POST("/revoke-tokens", h.revokeAllTokens)
+	}
+
+	// Called by other services with their client credentials
+	api.POST("/capabilities/redeem", h.clientIdentity, h.redeemCapability)
+	api.POST("/tickets/redeem", h.clientIdentity, h.redeemTicket)
+
 	return r
 }
@@ -0,0 +1,17 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// @Summary JSON Web Key Set
+// @Description Public keys used to sign access tokens. Downstream services use them to verify tokens offline.
+// @Tags well-known
+// @Produce json
+// @Success 200 {object} domain.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (h *Handler) jwks(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.service.Auth.JWKS())
+}
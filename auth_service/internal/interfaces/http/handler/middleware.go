@@ -1,24 +1,28 @@
 package handler
 
 import (
+	"auth_service/internal/domain"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"log"
 	"net/http"
+	"slices"
 	"strings"
 )
 
 const (
 	authorizationHeader = "Authorization"
 	userCtx             = "UserId"
+	sessionCtx          = "SessionId"
+	identityCtx         = "Identity"
 )
 
-// userIdentity is a Gin middleware that extracts the user id from a Bearer access token.
+// userIdentity is a Gin middleware that extracts the user id from a Bearer access token
+// or personal access token.
 //
 // Swagger annotations for documentation generators (e.g., swaggo):
 // @Summary Authenticate user by access token (middleware)
-// @Description Parses the "Authorization: Bearer {token}" header, validates the access token and stores the user id in the Gin context under key `UserId`.
+// @Description Parses the "Authorization: Bearer {token}" header, validates the access token (or personal access token) and stores the user id in the Gin context under key `UserId`.
 // @Tags middleware
 // @Accept json
 // @Produce json
@@ -36,15 +40,95 @@ func (h *Handler) userIdentity(c *gin.Context) {
 		return
 	}
 
-	// Service returns uuid.UUID
-	userId, err := h.service.Auth.ParseAccessToken(c.Request.Context(), headerParts[1])
+	identity, err := h.service.Auth.ParseAccessToken(c.Request.Context(), headerParts[1], h.audiences)
 	if err != nil {
 		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
 		return
 	}
 
 	// Store uuid.UUID in context
-	c.Set(userCtx, userId)
+	c.Set(userCtx, identity.UserID)
+	c.Set(sessionCtx, identity.SessionID)
+	c.Set(identityCtx, identity)
+	c.Next()
+}
+
+// sessionOnly rejects personal access tokens and tokens issued to OIDC
+// clients on routes that manage the account itself, so that a leaked script
+// token or a third-party app cannot log the user out or mint more tokens.
+func (h *Handler) sessionOnly(c *gin.Context) {
+	identity, err := getIdentity(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if identity.IsPersonalAccessToken() {
+		NewErrorResponse(c, http.StatusForbidden, "personal access tokens cannot be used here")
+		return
+	}
+	if identity.IsClientToken() {
+		NewErrorResponse(c, http.StatusForbidden, "tokens issued to OIDC clients cannot be used here")
+		return
+	}
+	c.Next()
+}
+
+// requireScope rejects personal access tokens and tokens of OIDC clients that
+// were not granted scope.
+func (h *Handler) requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, err := getIdentity(c)
+		if err != nil {
+			NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if !identity.HasScope(scope) {
+			NewErrorResponse(c, http.StatusForbidden, "missing scope "+scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireRole rejects users without role. Roles are read from the database
+// rather than the token, so that removing a role takes effect at once.
+func (h *Handler) requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := getUserId(c)
+		if err != nil {
+			NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		user, err := h.service.Auth.Me(c.Request.Context(), userID)
+		if err != nil {
+			NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if !slices.Contains(user.Roles, role) {
+			NewErrorResponse(c, http.StatusForbidden, "missing role "+role)
+			return
+		}
+		c.Next()
+	}
+}
+
+// verifiedEmail rejects users who have not verified their email yet. The
+// state is read from the database, so it applies as soon as the user verifies.
+func (h *Handler) verifiedEmail(c *gin.Context) {
+	userID, err := getUserId(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	user, err := h.service.Auth.Me(c.Request.Context(), userID)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !user.EmailVerified {
+		NewErrorResponse(c, http.StatusForbidden, "email is not verified")
+		return
+	}
 	c.Next()
 }
 
@@ -55,12 +139,9 @@ var ErrUserNotAuthorized = errors.New("user not authorized")
 func getUserId(c *gin.Context) (uuid.UUID, error) {
 	id, ok := c.Get(userCtx)
 	if !ok {
-		log.Println("userCtx not found")
 		return uuid.UUID{}, ErrUserNotAuthorized
 	}
 
-	log.Printf("userCtx value: %#v, type: %T\n", id, id)
-
 	userID, ok := id.(uuid.UUID)
 	if !ok {
 		return uuid.UUID{}, ErrUserNotAuthorized
@@ -68,3 +149,33 @@ func getUserId(c *gin.Context) (uuid.UUID, error) {
 
 	return userID, nil
 }
+
+// getSessionId returns the session the access token was issued for, or uuid.Nil
+// for tokens that are not bound to a session.
+func getSessionId(c *gin.Context) uuid.UUID {
+	id, _ := c.Get(sessionCtx)
+	sessionID, _ := id.(uuid.UUID)
+	return sessionID
+}
+
+// getIdentity returns the full identity of the access token the request was
+// authenticated with.
+func getIdentity(c *gin.Context) (domain.Identity, error) {
+	value, ok := c.Get(identityCtx)
+	if !ok {
+		return domain.Identity{}, ErrUserNotAuthorized
+	}
+	identity, ok := value.(domain.Identity)
+	if !ok {
+		return domain.Identity{}, ErrUserNotAuthorized
+	}
+	return identity, nil
+}
+
+// clientInfo describes the device the request came from.
+func clientInfo(c *gin.Context) domain.ClientInfo {
+	return domain.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"auth_service/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var (
+	loginIdentity = domain.Identity{UserID: uuid.New(), SessionID: uuid.New()}
+	patIdentity   = domain.Identity{UserID: uuid.New(), PersonalAccessTokenID: uuid.New(), Scopes: []string{domain.ScopeProfileRead}}
+	oidcIdentity  = domain.Identity{UserID: uuid.New(), SessionID: uuid.New(), ClientID: "web", Scopes: []string{"openid", "email"}}
+)
+
+// serve runs middleware for a caller authenticated as identity and returns the
+// response status; 200 when the request got through.
+func serve(identity domain.Identity, middleware gin.HandlerFunc) int {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", func(c *gin.Context) {
+		c.Set(identityCtx, identity)
+	}, middleware, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w.Code
+}
+
+func TestSessionOnly(t *testing.T) {
+	h := &Handler{}
+	tests := []struct {
+		name     string
+		identity domain.Identity
+		want     int
+	}{
+		{"login session", loginIdentity, http.StatusOK},
+		{"personal access token", patIdentity, http.StatusForbidden},
+		{"OIDC client token", oidcIdentity, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(tt.identity, h.sessionOnly); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	h := &Handler{}
+	tests := []struct {
+		name     string
+		identity domain.Identity
+		scope    string
+		want     int
+	}{
+		{"login session", loginIdentity, domain.ScopeLecturesWrite, http.StatusOK},
+		{"granted to the personal access token", patIdentity, domain.ScopeProfileRead, http.StatusOK},
+		{"not granted to the personal access token", patIdentity, domain.ScopeLecturesWrite, http.StatusForbidden},
+		{"OIDC client token", oidcIdentity, domain.ScopeProfileRead, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(tt.identity, h.requireScope(tt.scope)); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserInfoScope(t *testing.T) {
+	h := &Handler{}
+	withoutOpenID := oidcIdentity
+	withoutOpenID.Scopes = []string{"email"}
+
+	tests := []struct {
+		name     string
+		identity domain.Identity
+		want     int
+	}{
+		{"login session", loginIdentity, http.StatusOK},
+		{"personal access token with profile:read", patIdentity, http.StatusOK},
+		{"OIDC client token with openid", oidcIdentity, http.StatusOK},
+		{"OIDC client token without openid", withoutOpenID, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(tt.identity, h.userInfoScope); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/usecase/auth"
+	"auth_service/internal/usecase/oauth"
+	"auth_service/internal/usecase/oidc"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const clientCtx = "Client"
+
+// clientIdentity authenticates an OAuth2 client by HTTP Basic credentials, or by
+// client_id and client_secret form fields (RFC 6749 section 2.3.1).
+func (h *Handler) clientIdentity(c *gin.Context) {
+	clientID, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+	if clientID == "" || secret == "" {
+		c.Header("WWW-Authenticate", `Basic realm="auth-service"`)
+		NewOAuthErrorResponse(c, http.StatusUnauthorized, "invalid_client", "client authentication required")
+		return
+	}
+
+	client, err := h.service.OAuth.AuthenticateClient(c.Request.Context(), clientID, secret)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Basic realm="auth-service"`)
+		NewOAuthErrorResponse(c, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	c.Set(clientCtx, client)
+	c.Next()
+}
+
+// tokenClientIdentity is clientIdentity for the token and device authorization
+// endpoints, which public clients call with a client_id form field and no
+// secret (RFC 6749 section 2.1).
+func (h *Handler) tokenClientIdentity(c *gin.Context) {
+	if _, _, ok := c.Request.BasicAuth(); ok || c.PostForm("client_secret") != "" {
+		h.clientIdentity(c)
+		return
+	}
+
+	client, err := h.service.OAuth.AuthenticatePublicClient(c.Request.Context(), c.PostForm("client_id"))
+	if err != nil {
+		c.Header("WWW-Authenticate", `Basic realm="auth-service"`)
+		NewOAuthErrorResponse(c, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	c.Set(clientCtx, client)
+	c.Next()
+}
+
+// @Summary Token endpoint
+// @Description Issues tokens (RFC 6749 section 3.2). Supported grants: client_credentials for service tokens whose subject is the client_id, authorization_code with PKCE for OIDC clients, refresh_token, RFC 8693 token exchange for acting on behalf of a user, and the RFC 8628 device code grant. Confidential clients authenticate with HTTP Basic; public clients send client_id only.
+// @Tags oauth2
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "client_credentials, authorization_code, refresh_token, urn:ietf:params:oauth:grant-type:token-exchange or urn:ietf:params:oauth:grant-type:device_code"
+// @Param scope formData string false "client_credentials, token exchange: space separated scopes, defaults to every scope of the client"
+// @Param audience formData []string false "client_credentials, token exchange: services the token is meant for"
+// @Param code formData string false "authorization_code: the code returned by /oauth2/authorize"
+// @Param redirect_uri formData string false "authorization_code: the redirect_uri used at /oauth2/authorize"
+// @Param code_verifier formData string false "authorization_code: PKCE code verifier"
+// @Param refresh_token formData string false "refresh_token: a refresh token issued to the client"
+// @Param subject_token formData string false "token exchange: the user access token to act on"
+// @Param subject_token_type formData string false "token exchange: urn:ietf:params:oauth:token-type:access_token"
+// @Param device_code formData string false "device_code: the device code returned by /oauth2/device_authorization"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} OAuthErrorResponse
+// @Failure 401 {object} OAuthErrorResponse
+// @Router /oauth2/token [post]
+func (h *Handler) token(c *gin.Context) {
+	client := c.MustGet(clientCtx).(domain.Client)
+
+	switch grantType := c.PostForm("grant_type"); grantType {
+	case "client_credentials":
+		h.clientCredentialsGrant(c, client)
+	case "authorization_code":
+		h.authorizationCodeGrant(c, client)
+	case "refresh_token":
+		h.refreshTokenGrant(c, client)
+	case oauth.GrantTypeTokenExchange:
+		h.tokenExchangeGrant(c, client)
+	case oidc.GrantTypeDeviceCode:
+		h.deviceCodeGrant(c, client)
+	default:
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "unsupported_grant_type", "unsupported grant_type "+grantType)
+	}
+}
+
+func (h *Handler) clientCredentialsGrant(c *gin.Context, client domain.Client) {
+	scopes := strings.Fields(c.PostForm("scope"))
+	result, err := h.service.OAuth.ClientCredentials(c.Request.Context(), client, scopes, c.PostFormArray("audience"))
+	switch {
+	case errors.Is(err, oauth.ErrUnauthorizedClient):
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "unauthorized_client", err.Error())
+		return
+	case errors.Is(err, oauth.ErrInvalidScope):
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	case errors.Is(err, domain.ErrInvalidAudience):
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "invalid_target", err.Error())
+		return
+	case err != nil:
+		NewOAuthErrorResponse(c, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken: result.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(result.ExpiresAt).Seconds()),
+		Scope:       strings.Join(result.Scopes, " "),
+	})
+}
+
+func (h *Handler) authorizationCodeGrant(c *gin.Context, client domain.Client) {
+	result, err := h.service.OIDC.ExchangeCode(
+		c.Request.Context(),
+		client,
+		c.PostForm("code"),
+		c.PostForm("redirect_uri"),
+		c.PostForm("code_verifier"),
+		clientInfo(c),
+	)
+	if errors.Is(err, oidc.ErrInvalidGrant) {
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+	if err != nil {
+		NewOAuthErrorResponse(c, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken:  result.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(result.ExpiresAt).Seconds()),
+		RefreshToken: result.RefreshToken,
+		IDToken:      result.IDToken,
+		Scope:        strings.Join(result.Scopes, " "),
+	})
+}
+
+func (h *Handler) tokenExchangeGrant(c *gin.Context, client domain.Client) {
+	result, err := h.service.OAuth.ExchangeToken(
+		c.Request.Context(),
+		client,
+		c.PostForm("subject_token"),
+		c.PostForm("subject_token_type"),
+		strings.Fields(c.PostForm("scope")),
+		c.PostFormArray("audience"),
+	)
+	switch {
+	case errors.Is(err, oauth.ErrUnauthorizedClient):
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "unauthorized_client", err.Error())
+		return
+	case errors.Is(err, oauth.ErrUnsupportedTokenType), errors.Is(err, oauth.ErrInvalidSubjectToken):
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	case errors.Is(err, oauth.ErrInvalidScope):
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	case errors.Is(err, domain.ErrInvalidAudience):
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "invalid_target", err.Error())
+		return
+	case err != nil:
+		NewOAuthErrorResponse(c, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken:     result.AccessToken,
+		IssuedTokenType: oauth.TokenTypeAccessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(time.Until(result.ExpiresAt).Seconds()),
+		Scope:           strings.Join(result.Scopes, " "),
+	})
+}
+
+func (h *Handler) refreshTokenGrant(c *gin.Context, client domain.Client) {
+	result, err := h.service.OIDC.RefreshTokens(c.Request.Context(), client, c.PostForm("refresh_token"), clientInfo(c))
+	switch {
+	case errors.Is(err, auth.ErrInvalidRefreshToken), errors.Is(err, domain.ErrRefreshTokenReused):
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	case err != nil:
+		NewOAuthErrorResponse(c, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken:  result.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(result.ExpiresAt).Seconds()),
+		RefreshToken: result.RefreshToken,
+	})
+}
+
+// @Summary Token introspection
+// @Description RFC 7662 token introspection for internal services. Authenticate with the client's credentials (HTTP Basic). Only tokens whose audience includes the client_id are reported as active.
+// @Tags oauth2
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "Access token to check"
+// @Param token_type_hint formData string false "access_token"
+// @Success 200 {object} IntrospectionResponse
+// @Failure 400 {object} OAuthErrorResponse
+// @Failure 401 {object} OAuthErrorResponse
+// @Router /oauth2/introspect [post]
+func (h *Handler) introspect(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	token := c.PostForm("token")
+	if token == "" {
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	client := c.MustGet(clientCtx).(domain.Client)
+	result := h.service.OAuth.Introspect(ctx, client, token)
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, introspectionResponse(result))
+}
+
+func introspectionResponse(result domain.Introspection) IntrospectionResponse {
+	if !result.Active {
+		return IntrospectionResponse{Revoked: result.Revoked}
+	}
+	return IntrospectionResponse{
+		Active:    true,
+		TokenType: result.TokenType,
+		Scope:     result.Scope,
+		Sub:       result.Subject,
+		ClientID:  result.ClientID,
+		Act:       result.Actor,
+		Aud:       result.Audience,
+		Iss:       result.Issuer,
+		Jti:       result.TokenID,
+		Exp:       result.ExpiresAt.Unix(),
+		Iat:       result.IssuedAt.Unix(),
+	}
+}
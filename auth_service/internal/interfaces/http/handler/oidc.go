@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/usecase/oauth"
+	"auth_service/internal/usecase/oidc"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// loginPage is the form shown by /oauth2/authorize. The authorization request
+// is carried through the form in hidden fields.
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Sign in</title></head>
+<body>
+<h1>Sign in to {{.ClientName}}</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<form method="post" action="/oauth2/authorize">
+  <input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+  <input type="hidden" name="client_id" value="{{.Request.ClientID}}">
+  <input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+  <input type="hidden" name="scope" value="{{.Scope}}">
+  <input type="hidden" name="state" value="{{.Request.State}}">
+  <input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+  <input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+  <input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+  <label>Email or username <input name="username" autocomplete="username" required></label>
+  <label>Password <input name="password" type="password" autocomplete="current-password" required></label>
+  <button type="submit">Sign in</button>
+</form>
+</body>
+</html>`))
+
+type loginPageData struct {
+	ClientName string
+	Request    domain.AuthorizationRequest
+	Scope      string
+	Error      string
+}
+
+// @Summary OpenID Provider configuration
+// @Description OIDC discovery document
+// @Tags well-known
+// @Produce json
+// @Success 200 {object} domain.ProviderMetadata
+// @Router /.well-known/openid-configuration [get]
+func (h *Handler) openidConfiguration(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.service.OIDC.Metadata())
+}
+
+// @Summary Authorization endpoint
+// @Description OIDC authorization code flow with mandatory PKCE (S256). Shows the login form.
+// @Tags oauth2
+// @Produce html
+// @Param response_type query string true "code"
+// @Param client_id query string true "Client id"
+// @Param redirect_uri query string true "Registered redirect URI"
+// @Param scope query string false "e.g. openid profile email"
+// @Param state query string false "Opaque value returned to the client"
+// @Param nonce query string false "Copied into the id_token"
+// @Param code_challenge query string true "PKCE code challenge"
+// @Param code_challenge_method query string true "S256"
+// @Success 200 {string} string "login form"
+// @Failure 302 {string} string "redirect to redirect_uri with an error"
+// @Failure 400 {object} OAuthErrorResponse
+// @Router /oauth2/authorize [get]
+func (h *Handler) authorize(c *gin.Context) {
+	req := authorizationRequest(c.Query)
+
+	client, err := h.service.OIDC.ValidateAuthorizationRequest(c.Request.Context(), req)
+	if err != nil {
+		h.authorizeError(c, req, err)
+		return
+	}
+
+	renderLoginPage(c, http.StatusOK, client.Name, req, "")
+}
+
+// @Summary Authorization endpoint login
+// @Description Submits the login form. On success redirects to redirect_uri with code and state.
+// @Tags oauth2
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param username formData string true "Email or username"
+// @Param password formData string true "Password"
+// @Success 302 {string} string "redirect to redirect_uri with code and state"
+// @Failure 401 {string} string "login form with an error"
+// @Failure 400 {object} OAuthErrorResponse
+// @Router /oauth2/authorize [post]
+func (h *Handler) authorizeLogin(c *gin.Context) {
+	req := authorizationRequest(c.PostForm)
+
+	code, err := h.service.OIDC.Authorize(c.Request.Context(), req, c.PostForm("username"), c.PostForm("password"))
+	if errors.Is(err, oidc.ErrLoginFailed) {
+		client, verr := h.service.OIDC.ValidateAuthorizationRequest(c.Request.Context(), req)
+		if verr != nil {
+			h.authorizeError(c, req, verr)
+			return
+		}
+		renderLoginPage(c, http.StatusUnauthorized, client.Name, req, err.Error())
+		return
+	}
+	if err != nil {
+		h.authorizeError(c, req, err)
+		return
+	}
+
+	redirectWithParams(c, req.RedirectURI, url.Values{"code": {code}}, req.State)
+}
+
+// authorizeError reports an /authorize error. Errors about the client or its
+// redirect_uri are shown to the user, since the redirect target cannot be
+// trusted; the rest are sent back to the client (RFC 6749 section 4.1.2.1).
+func (h *Handler) authorizeError(c *gin.Context, req domain.AuthorizationRequest, err error) {
+	var code string
+	switch {
+	case errors.Is(err, oauth.ErrInvalidClient), errors.Is(err, oidc.ErrInvalidRedirectURI):
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	case errors.Is(err, oidc.ErrUnsupportedResponseType):
+		code = "unsupported_response_type"
+	case errors.Is(err, oidc.ErrPKCERequired):
+		code = "invalid_request"
+	case errors.Is(err, oidc.ErrInvalidScope):
+		code = "invalid_scope"
+	default:
+		h.log.Error(c.Request.Context(), "oidc: authorize error", err.Error())
+		code = "server_error"
+	}
+
+	redirectWithParams(c, req.RedirectURI, url.Values{
+		"error":             {code},
+		"error_description": {err.Error()},
+	}, req.State)
+}
+
+// userInfoScope admits tokens issued to OIDC clients with the openid scope,
+// and personal access tokens with profile:read.
+func (h *Handler) userInfoScope(c *gin.Context) {
+	identity, err := getIdentity(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	scope := domain.ScopeProfileRead
+	if identity.IsClientToken() {
+		scope = oidc.ScopeOpenID
+	}
+	h.requireScope(scope)(c)
+}
+
+// @Summary UserInfo endpoint
+// @Description OIDC UserInfo: claims about the user the access token was issued for. Tokens issued to an OIDC client only get the claims of the granted scopes: profile for the names, email for the email.
+// @Tags oauth2
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} UserInfoResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /oauth2/userinfo [get]
+func (h *Handler) userinfo(c *gin.Context) {
+	identity, err := getIdentity(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	info, err := h.service.OIDC.UserInfo(c.Request.Context(), identity)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, UserInfoResponse{
+		Sub:               identity.UserID.String(),
+		PreferredUsername: info.PreferredUsername,
+		Name:              info.Name,
+		GivenName:         info.GivenName,
+		FamilyName:        info.FamilyName,
+		Email:             info.Email,
+	})
+}
+
+func authorizationRequest(get func(string) string) domain.AuthorizationRequest {
+	return domain.AuthorizationRequest{
+		ResponseType:        get("response_type"),
+		ClientID:            get("client_id"),
+		RedirectURI:         get("redirect_uri"),
+		Scopes:              strings.Fields(get("scope")),
+		State:               get("state"),
+		Nonce:               get("nonce"),
+		CodeChallenge:       get("code_challenge"),
+		CodeChallengeMethod: get("code_challenge_method"),
+	}
+}
+
+func renderLoginPage(c *gin.Context, status int, clientName string, req domain.AuthorizationRequest, message string) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Frame-Options", "DENY")
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	_ = loginPage.Execute(c.Writer, loginPageData{
+		ClientName: clientName,
+		Request:    req,
+		Scope:      strings.Join(req.Scopes, " "),
+		Error:      message,
+	})
+}
+
+// redirectWithParams redirects to a validated redirect_uri, adding params and
+// the client's state to its query.
+func redirectWithParams(c *gin.Context, redirectURI string, params url.Values, state string) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		NewOAuthErrorResponse(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	target.RawQuery = query.Encode()
+
+	c.Redirect(http.StatusFound, target.String())
+}
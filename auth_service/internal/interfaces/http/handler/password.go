@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/usecase/auth"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ForgotPasswordInput names the account a reset link is requested for
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required,email" example:"john@example.com"`
+}
+
+// ResetPasswordInput sets a new password with the token of a reset link
+type ResetPasswordInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6" example:"new-password123"`
+}
+
+// ChangePasswordInput replaces the password of the logged-in user
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required" example:"password123"`
+	NewPassword     string `json:"new_password" binding:"required,min=6" example:"new-password123"`
+	// Audience of the new access token, as on login
+	Audience []string `json:"audience,omitempty" example:"content-service"`
+}
+
+// @Summary Forgot password
+// @Description Email a password reset link, valid for an hour, to the account registered with email. Always answers 202, whether or not the account exists.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body ForgotPasswordInput true "Email"
+// @Success 202 {object} StatusResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /auth/password/forgot [post]
+func (h *Handler) forgotPassword(c *gin.Context) {
+	var input ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.service.Auth.ForgotPassword(c.Request.Context(), input.Email)
+
+	c.JSON(http.StatusAccepted, StatusResponse{Status: "accepted"})
+}
+
+// @Summary Reset password
+// @Description Set a new password with the token of a reset link. The token works once. Every session of the user is logged out.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body ResetPasswordInput true "Reset token and new password"
+// @Success 200 {object} StatusResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /auth/password/reset [post]
+func (h *Handler) resetPassword(c *gin.Context) {
+	var input ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err := h.service.Auth.ResetPassword(c.Request.Context(), input.Token, input.Password)
+	if errors.Is(err, auth.ErrInvalidResetToken) || errors.Is(err, auth.ErrWeakPassword) {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, StatusResponse{Status: "ok"})
+}
+
+// @Summary Change password
+// @Description Replace the password of the current user, who must confirm the current one. Every session is logged out, this one included; the new tokens of this device are returned.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body ChangePasswordInput true "Current and new password"
+// @Success 200 {object} LoginResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /auth/password [post]
+func (h *Handler) changePassword(c *gin.Context) {
+	identity, err := getIdentity(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var input ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	at, rt, err := h.service.Auth.ChangePassword(c.Request.Context(), identity,
+		input.CurrentPassword, input.NewPassword, clientInfo(c), input.Audience)
+	switch {
+	case errors.Is(err, auth.ErrWrongPassword):
+		NewErrorResponse(c, http.StatusForbidden, err.Error())
+		return
+	case errors.Is(err, auth.ErrWeakPassword), errors.Is(err, domain.ErrInvalidAudience):
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, LoginResponse{
+		AccessToken:  at,
+		RefreshToken: rt,
+	})
+}
@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"auth_service/internal/domain"
 	"github.com/gin-gonic/gin"
 	"log/slog"
+	"time"
 )
 
 // ErrorResponse represents an API error response
@@ -23,10 +25,141 @@ type RegisterResponse struct {
 // LoginResponse represents login response
 type LoginResponse struct {
 	AccessToken  string `json:"access_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
-	RefreshToken string `json:"refresh_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	RefreshToken string `json:"refresh_token" example:"q3Xk9v0bM1l2s7yJtP4cR8eWnA6fZhU5dG0iKoLmNjE"`
+}
+
+// SessionResponse represents one logged-in device
+type SessionResponse struct {
+	ID         string    `json:"id" example:"01234567-89ab-cdef-0123-456789abcdef"`
+	UserAgent  string    `json:"user_agent" example:"Mozilla/5.0"`
+	IP         string    `json:"ip" example:"10.0.0.12"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	RememberMe bool      `json:"remember_me" example:"false"`
+	Current    bool      `json:"current" example:"true"`
+}
+
+// TokenResponseItem represents one personal access token
+type TokenResponseItem struct {
+	ID         string     `json:"id" example:"01234567-89ab-cdef-0123-456789abcdef"`
+	Name       string     `json:"name" example:"lecture sync script"`
+	Scopes     []string   `json:"scopes" example:"lectures:read"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CreatedTokenResponse represents a newly created personal access token
+type CreatedTokenResponse struct {
+	TokenResponseItem
+	// Token is only returned once
+	Token string `json:"token" example:"pat_3q2-7wAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"`
+}
+
+// CapabilityTokenResponse represents a newly issued single-use capability token
+type CapabilityTokenResponse struct {
+	Token     string    `json:"token"`
+	FileKey   string    `json:"file_key" example:"lectures/42/slides.pdf"`
+	Action    string    `json:"action" example:"download"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CapabilityResponse represents a redeemed capability token
+type CapabilityResponse struct {
+	UserID  string `json:"user_id" example:"01234567-89ab-cdef-0123-456789abcdef"`
+	FileKey string `json:"file_key" example:"lectures/42/slides.pdf"`
+	Action  string `json:"action" example:"download"`
+}
+
+// TicketResponse represents a newly issued connection ticket
+type TicketResponse struct {
+	Ticket    string `json:"ticket" example:"Xq3Yv9k2LmN8pQ4rS7tU1wZ5aB6cD0eF2gH4iJ6kL8m"`
+	Channel   string `json:"channel" example:"chat:7d9f2c1e-5b1a-4c8e-9f3a-2e6d8b4a1c0f"`
+	ExpiresIn int64  `json:"expires_in" example:"30"`
+}
+
+// RedeemedTicketResponse represents a redeemed connection ticket
+type RedeemedTicketResponse struct {
+	UserID  string `json:"user_id" example:"01234567-89ab-cdef-0123-456789abcdef"`
+	Channel string `json:"channel" example:"chat:7d9f2c1e-5b1a-4c8e-9f3a-2e6d8b4a1c0f"`
+}
+
+// TokenEpochResponse represents a token epoch after an increment
+type TokenEpochResponse struct {
+	// UserID is set when only one user's tokens were revoked
+	UserID string `json:"user_id,omitempty" example:"01234567-89ab-cdef-0123-456789abcdef"`
+	Epoch  int64  `json:"epoch" example:"3"`
+}
+
+// DeviceAuthorizationResponse represents the answer of the device authorization endpoint (RFC 8628 section 3.2)
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code" example:"WDJB-MJHT"`
+	VerificationURI         string `json:"verification_uri" example:"http://localhost:3000/device"`
+	VerificationURIComplete string `json:"verification_uri_complete" example:"http://localhost:3000/device?user_code=WDJB-MJHT"`
+	ExpiresIn               int64  `json:"expires_in" example:"600"`
+	Interval                int64  `json:"interval" example:"5"`
+}
+
+// DeviceResponse represents a pending device authorization
+type DeviceResponse struct {
+	ClientID   string    `json:"client_id" example:"lecture-cli"`
+	ClientName string    `json:"client_name" example:"Lecture upload CLI"`
+	Scopes     []string  `json:"scopes" example:"openid"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// OAuthErrorResponse represents an error of the /oauth2 endpoints (RFC 6749 section 5.2)
+type OAuthErrorResponse struct {
+	Error            string `json:"error" example:"invalid_client"`
+	ErrorDescription string `json:"error_description,omitempty" example:"invalid client credentials"`
+}
+
+// TokenResponse represents a successful /oauth2/token response (RFC 6749 section 5.1)
+type TokenResponse struct {
+	AccessToken     string `json:"access_token" example:"eyJhbGciOiJFZERTQSIsImtpZCI6Ii4uLiJ9..."`
+	IssuedTokenType string `json:"issued_token_type,omitempty" example:"urn:ietf:params:oauth:token-type:access_token"`
+	TokenType       string `json:"token_type" example:"Bearer"`
+	ExpiresIn       int64  `json:"expires_in,omitempty" example:"1800"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	IDToken         string `json:"id_token,omitempty"`
+	Scope           string `json:"scope,omitempty" example:"lectures:read"`
+}
+
+// UserInfoResponse represents the OIDC /userinfo response. Claims of scopes
+// that were not granted are omitted.
+type UserInfoResponse struct {
+	Sub               string `json:"sub" example:"01234567-89ab-cdef-0123-456789abcdef"`
+	PreferredUsername string `json:"preferred_username,omitempty" example:"john_doe"`
+	Name              string `json:"name,omitempty" example:"John Doe"`
+	GivenName         string `json:"given_name,omitempty" example:"John"`
+	FamilyName        string `json:"family_name,omitempty" example:"Doe"`
+	Email             string `json:"email,omitempty" example:"john@example.com"`
+}
+
+// IntrospectionResponse represents a token introspection response (RFC 7662)
+type IntrospectionResponse struct {
+	Active    bool          `json:"active" example:"true"`
+	Revoked   bool          `json:"revoked,omitempty" example:"false"`
+	TokenType string        `json:"token_type,omitempty" example:"Bearer"`
+	Scope     string        `json:"scope,omitempty" example:""`
+	Sub       string        `json:"sub,omitempty" example:"01234567-89ab-cdef-0123-456789abcdef"`
+	ClientID  string        `json:"client_id,omitempty" example:"ai-service"`
+	Act       *domain.Actor `json:"act,omitempty"`
+	Aud       []string      `json:"aud,omitempty"`
+	Iss       string        `json:"iss,omitempty" example:"auth-service"`
+	Jti       string        `json:"jti,omitempty" example:"01234567-89ab-cdef-0123-456789abcdef"`
+	Exp       int64         `json:"exp,omitempty" example:"1767225600"`
+	Iat       int64         `json:"iat,omitempty" example:"1767223800"`
 }
 
 func NewErrorResponse(c *gin.Context, statusCode int, message string) {
 	slog.Error(message)
 	c.AbortWithStatusJSON(statusCode, ErrorResponse{Message: message})
 }
+
+func NewOAuthErrorResponse(c *gin.Context, statusCode int, code, description string) {
+	slog.Error(description, "error", code)
+	c.AbortWithStatusJSON(statusCode, OAuthErrorResponse{Error: code, ErrorDescription: description})
+}
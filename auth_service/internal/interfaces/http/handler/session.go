@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"auth_service/internal/usecase/auth"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// @Summary List sessions
+// @Description List the devices the current user is logged in on
+// @Tags sessions
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} SessionResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/sessions [get]
+func (h *Handler) listSessions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := getUserId(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	sessions, err := h.service.Auth.ListSessions(ctx, userID)
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	current := getSessionId(c)
+	resp := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, SessionResponse{
+			ID:         s.Id.String(),
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			ExpiresAt:  s.ExpiresAt,
+			RememberMe: s.RememberMe,
+			Current:    s.Id == current,
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Revoke session
+// @Description Log out one device of the current user
+// @Tags sessions
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Session ID"
+// @Success 200 {object} StatusResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /auth/sessions/{id} [delete]
+func (h *Handler) revokeSession(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := getUserId(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	err = h.service.Auth.RevokeSession(ctx, userID, sessionID)
+	if errors.Is(err, auth.ErrSessionNotFound) {
+		NewErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, StatusResponse{Status: "ok"})
+}
+
+// @Summary Logout everywhere
+// @Description Revoke every session of the current user, including this one
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} StatusResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/logout/all [post]
+func (h *Handler) logoutAll(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := getUserId(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if err := h.service.Auth.LogoutAll(ctx, userID); err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, StatusResponse{Status: "ok"})
+}
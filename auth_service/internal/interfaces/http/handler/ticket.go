@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/usecase/auth"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// CreateTicketInput names the stream a ticket is requested for
+type CreateTicketInput struct {
+	Channel string `json:"channel" binding:"required,max=255" example:"chat:7d9f2c1e-5b1a-4c8e-9f3a-2e6d8b4a1c0f"`
+}
+
+// RedeemTicketInput is a ticket presented on a WebSocket or SSE connection
+type RedeemTicketInput struct {
+	Ticket  string `json:"ticket" binding:"required"`
+	Channel string `json:"channel" binding:"required" example:"chat:7d9f2c1e-5b1a-4c8e-9f3a-2e6d8b4a1c0f"`
+}
+
+// @Summary Create connection ticket
+// @Description Exchange the access token for a one-time ticket, valid for 30 seconds, to authenticate a WebSocket or SSE connection to channel. Browsers cannot set an Authorization header on those, so the ticket goes in the query string.
+// @Tags tickets
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body CreateTicketInput true "Channel"
+// @Success 201 {object} TicketResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /auth/ticket [post]
+func (h *Handler) createTicket(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := getUserId(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var input CreateTicketInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stored, ticket, err := h.service.Auth.CreateTicket(ctx, userID, input.Channel)
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, TicketResponse{
+		Ticket:    ticket,
+		Channel:   stored.Channel,
+		ExpiresIn: int64(time.Until(stored.ExpiresAt).Seconds()),
+	})
+}
+
+// @Summary Redeem connection ticket
+// @Description Called by the service accepting a WebSocket or SSE connection. Uses up the ticket and returns the user it was issued to; a ticket is accepted once, and only for the channel it was issued for. Authenticate with the client's credentials (HTTP Basic).
+// @Tags tickets
+// @Accept json
+// @Produce json
+// @Param input body RedeemTicketInput true "Ticket and channel"
+// @Success 200 {object} RedeemedTicketResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} OAuthErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /tickets/redeem [post]
+func (h *Handler) redeemTicket(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var input RedeemTicketInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ticket, err := h.service.Auth.RedeemTicket(ctx, input.Ticket, input.Channel)
+	if errors.Is(err, auth.ErrInvalidTicket) {
+		NewErrorResponse(c, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	client := c.MustGet(clientCtx).(domain.Client)
+	h.log.Info(ctx, "ticket redeemed",
+		"client_id", client.ClientID, "user_id", ticket.UserID.String(), "channel", ticket.Channel)
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, RedeemedTicketResponse{
+		UserID:  ticket.UserID.String(),
+		Channel: ticket.Channel,
+	})
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"auth_service/internal/usecase/auth"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+// CreateTokenInput represents a new personal access token
+type CreateTokenInput struct {
+	Name   string   `json:"name" binding:"required,max=100" example:"lecture sync script"`
+	Scopes []string `json:"scopes" binding:"required,min=1" example:"lectures:read"`
+	// ExpiresAt is optional; without it the token lives until it is revoked.
+	ExpiresAt *time.Time `json:"expires_at,omitempty" example:"2027-01-01T00:00:00Z"`
+}
+
+// @Summary Create personal access token
+// @Description Create a token for scripts. The token is only shown in this response. Scopes: profile:read, lectures:read, lectures:write, messages:write
+// @Tags tokens
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body CreateTokenInput true "Token"
+// @Success 201 {object} CreatedTokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /auth/tokens [post]
+func (h *Handler) createToken(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := getUserId(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var input CreateTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	pat, token, err := h.service.Auth.CreatePersonalAccessToken(ctx, userID, input.Name, input.Scopes, input.ExpiresAt)
+	if errors.Is(err, auth.ErrUnknownScope) || errors.Is(err, auth.ErrNoScopes) || errors.Is(err, auth.ErrInvalidExpiry) {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, CreatedTokenResponse{
+		TokenResponseItem: TokenResponseItem{
+			ID:        pat.Id.String(),
+			Name:      pat.Name,
+			Scopes:    pat.Scopes,
+			CreatedAt: pat.CreatedAt,
+			ExpiresAt: pat.ExpiresAt,
+		},
+		Token: token,
+	})
+}
+
+// @Summary List personal access tokens
+// @Description List the current user's personal access tokens that have not been revoked
+// @Tags tokens
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} TokenResponseItem
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /auth/tokens [get]
+func (h *Handler) listTokens(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := getUserId(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	pats, err := h.service.Auth.ListPersonalAccessTokens(ctx, userID)
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := make([]TokenResponseItem, 0, len(pats))
+	for _, pat := range pats {
+		resp = append(resp, TokenResponseItem{
+			ID:         pat.Id.String(),
+			Name:       pat.Name,
+			Scopes:     pat.Scopes,
+			CreatedAt:  pat.CreatedAt,
+			ExpiresAt:  pat.ExpiresAt,
+			LastUsedAt: pat.LastUsedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Revoke personal access token
+// @Description Revoke one of the current user's personal access tokens
+// @Tags tokens
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Token ID"
+// @Success 200 {object} StatusResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /auth/tokens/{id} [delete]
+func (h *Handler) revokeToken(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := getUserId(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, "invalid token id")
+		return
+	}
+
+	err = h.service.Auth.RevokePersonalAccessToken(ctx, userID, id)
+	if errors.Is(err, auth.ErrPersonalAccessTokenNotFound) {
+		NewErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, StatusResponse{Status: "ok"})
+}
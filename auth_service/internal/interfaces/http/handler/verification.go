@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"auth_service/internal/usecase/auth"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// VerifyEmailInput carries the token of an email verification link
+type VerifyEmailInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// @Summary Verify email
+// @Description Confirm the email address with the token of the link sent on registration. Access tokens report the new state from the next refresh.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body VerifyEmailInput true "Verification token"
+// @Success 200 {object} StatusResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /auth/verify-email [post]
+func (h *Handler) verifyEmail(c *gin.Context) {
+	var input VerifyEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, err := h.service.Auth.VerifyEmail(c.Request.Context(), input.Token)
+	if errors.Is(err, auth.ErrInvalidVerificationToken) {
+		NewErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, StatusResponse{Status: "ok"})
+}
+
+// @Summary Resend verification email
+// @Description Send a new email verification link to the current user, at most once a minute.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 202 {object} StatusResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /auth/verify-email/resend [post]
+func (h *Handler) resendVerificationEmail(c *gin.Context) {
+	userID, err := getUserId(c)
+	if err != nil {
+		NewErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	err = h.service.Auth.ResendVerificationEmail(c.Request.Context(), userID)
+	switch {
+	case errors.Is(err, auth.ErrEmailAlreadyVerified):
+		NewErrorResponse(c, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, auth.ErrVerificationThrottled):
+		c.Header("Retry-After", "60")
+		NewErrorResponse(c, http.StatusTooManyRequests, err.Error())
+		return
+	case err != nil:
+		NewErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusAccepted, StatusResponse{Status: "sent"})
+}
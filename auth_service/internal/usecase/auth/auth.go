@@ -5,100 +5,301 @@ import (
 	"auth_service/internal/infrastructure/logger"
 	"auth_service/internal/infrastructure/repository"
 	"context"
+	"database/sql"
 	"errors"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"time"
 )
 
 type TokenManager interface {
-	NewAccessToken(userID string) (string, error)
-	NewRefreshToken(userID string) (string, error)
-	ParseAccessToken(ctx context.Context, token string) (string, error)
-	ParseRefreshToken(ctx context.Context, token string) (string, error)
+	NewAccessToken(userID, sessionID string, audience []string, profile domain.ProfileClaims, grant domain.Grant) (string, error)
+	ResolveAudience(audience []string) ([]string, error)
+	NewRefreshToken() (domain.RefreshToken, error)
+	HashRefreshToken(token string) string
+	IsJWTRefreshToken(token string) bool
+	ParseAccessToken(ctx context.Context, token string, audiences []string) (domain.AccessTokenClaims, error)
+	ParseRefreshToken(ctx context.Context, token string) (domain.RefreshTokenClaims, error)
+	RevokeAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+	RevokeSessionTokens(ctx context.Context, sessionID string) error
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID) (int64, error)
+	RevokeAllTokens(ctx context.Context) (int64, error)
+	JWKS() domain.JWKSet
+
+	NewPersonalAccessToken() (string, string, error)
+	HashPersonalAccessToken(token string) string
+	IsPersonalAccessToken(token string) bool
+
+	NewCapabilityToken(capability domain.Capability) (string, error)
+	ParseCapabilityToken(ctx context.Context, token string) (domain.Capability, error)
+
+	NewTicket() (string, string, error)
+	HashTicket(ticket string) string
+
+	NewPasswordResetToken() (string, string, error)
+	HashPasswordResetToken(token string) string
+
+	NewEmailVerificationToken(userID uuid.UUID, email string) (string, error)
+	ParseEmailVerificationToken(ctx context.Context, token string) (uuid.UUID, string, error)
 }
 
-var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+var (
+	ErrInvalidCredentials  = errors.New("invalid login or password")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrNotUserToken        = errors.New("access token does not belong to a user")
+	ErrDelegatedToken      = errors.New("delegated access tokens cannot be used here")
+	ErrProfileModified     = errors.New("profile was modified by another request")
+)
+
+// dummyPasswordHash is compared against when the user does not exist, so that
+// unknown and known logins take the same time to reject.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
 
 type ServiceAuth struct {
-	repo   repository.Auth
-	log    *logger.SlogLogger
-	tokens TokenManager
+	repo         repository.Auth
+	events       repository.SecurityEvents
+	pats         repository.PersonalAccessTokens
+	capabilities repository.Capabilities
+	tickets      repository.Tickets
+	resets       repository.PasswordResetTokens
+	log          *logger.SlogLogger
+	tokens       TokenManager
+	claims       *ClaimsBuilder
+	mailer       Mailer
+
+	policies domain.SessionPolicies
+	links    Links
 }
 
-func NewServiceAuth(repo repository.Auth, log *logger.SlogLogger, tokens TokenManager) *ServiceAuth {
+func NewServiceAuth(
+	repo repository.Auth,
+	events repository.SecurityEvents,
+	pats repository.PersonalAccessTokens,
+	capabilities repository.Capabilities,
+	tickets repository.Tickets,
+	resets repository.PasswordResetTokens,
+	log *logger.SlogLogger,
+	tokens TokenManager,
+	claims *ClaimsBuilder,
+	mailer Mailer,
+	policies domain.SessionPolicies,
+	links Links,
+) *ServiceAuth {
 	return &ServiceAuth{
-		repo:   repo,
-		log:    log,
-		tokens: tokens,
+		repo:         repo,
+		events:       events,
+		pats:         pats,
+		capabilities: capabilities,
+		tickets:      tickets,
+		resets:       resets,
+		log:          log,
+		tokens:       tokens,
+		claims:       claims,
+		mailer:       mailer,
+		policies:     policies,
+		links:        links,
 	}
 }
 
+// Register creates an account with an unverified email and sends the user a
+// verification link.
 func (s *ServiceAuth) Register(ctx context.Context, user domain.User) (uuid.UUID, error) {
+	if err := checkPasswordPolicy(user.Password); err != nil {
+		return uuid.UUID{}, err
+	}
 	hash, err := hashPassword(user.Password)
 	if err != nil {
 		s.log.Error(ctx, "service auth: hash password error", err.Error())
 		return uuid.UUID{}, err
 	}
 	user.Password = hash
-	return s.repo.CreateUser(ctx, user)
+	id, err := s.repo.CreateUser(ctx, user)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	// The account exists either way; a lost email can be resent.
+	user.Id = id
+	go func(ctx context.Context) {
+		if err := s.sendVerificationEmail(ctx, user); err != nil {
+			s.log.Error(ctx, "service auth: send verification email error", err.Error())
+		}
+	}(context.WithoutCancel(ctx))
+
+	return id, nil
 }
 
-func (s *ServiceAuth) Login(ctx context.Context, username, password string) (string, string, error) {
-	user, err := s.repo.GetUserByUsername(ctx, username)
+// Login opens a new session for the device described by client. login is the
+// user's email or username. Sessions on other devices are left untouched. The access token is issued for the
+// requested audience, or the default one when empty. rememberMe picks the long
+// session policy over the browser session one.
+func (s *ServiceAuth) Login(ctx context.Context, login, password string, client domain.ClientInfo, audience []string, rememberMe bool) (string, string, error) {
+	user, err := s.Authenticate(ctx, login, password)
 	if err != nil {
-		s.log.Error(ctx, "repo auth: get user error", err.Error())
 		return "", "", err
 	}
+	return s.StartSession(ctx, user.Id, client, audience, rememberMe, domain.Grant{})
+}
+
+// Authenticate checks a password against the user identified by login, an
+// email or a username. Unknown users take as long to reject as wrong passwords
+// and get the same error, so login does not reveal which accounts exist.
+func (s *ServiceAuth) Authenticate(ctx context.Context, login, password string) (domain.User, error) {
+	user, err := s.findUser(ctx, login)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = checkPassword(password, string(dummyPasswordHash))
+		return domain.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		s.log.Error(ctx, "repo auth: get user error", err.Error())
+		return domain.User{}, err
+	}
 
 	if err := checkPassword(password, user.Password); err != nil {
 		s.log.Error(ctx, "repo auth: check password error", err.Error())
-		return "", "", err
+		return domain.User{}, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
+
+// findUser resolves a login identifier. Anything with an @ is tried as an
+// email first, case-insensitively; usernames registered with an @ in them still
+// work through the fallback.
+func (s *ServiceAuth) findUser(ctx context.Context, login string) (domain.User, error) {
+	if strings.Contains(login, "@") {
+		user, err := s.repo.GetUserByEmail(ctx, login)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return user, err
+		}
 	}
+	return s.repo.GetUserByUsername(ctx, login)
+}
+
+// StartSession opens a session for an already authenticated user and returns
+// its access and refresh tokens.
+func (s *ServiceAuth) StartSession(ctx context.Context, userID uuid.UUID, client domain.ClientInfo, audience []string, rememberMe bool, grant domain.Grant) (string, string, error) {
+	// Every login is a new session, which is also a new refresh rotation family
+	sessionID := uuid.New()
 
 	// Generate Access Token
-	access, err := s.tokens.NewAccessToken(user.Id.String())
+	access, err := s.accessToken(ctx, userID, sessionID, audience, grant)
 	if err != nil {
 		s.log.Error(ctx, "service auth: access token generation error", err.Error())
 		return "", "", err
 	}
 
 	// Generate Refresh Token
-	refresh, err := s.tokens.NewRefreshToken(user.Id.String())
+	refresh, err := s.tokens.NewRefreshToken()
 	if err != nil {
 		s.log.Error(ctx, "service auth: refresh token generation error", err.Error())
 		return "", "", err
 	}
 
+	policy := s.policies.For(rememberMe)
+	now := time.Now()
+	expiresAt := now.Add(policy.MaxLifetime)
+	refreshExpiresAt := policy.RefreshExpiry(now, expiresAt)
+
 	// 🔥 SAVE REFRESH TOKEN TO DB (REQUIRED FOR /refresh)
-	err = s.repo.SaveRefreshToken(ctx, user.Id, refresh)
+	err = s.repo.CreateSession(ctx, domain.Session{
+		Id:               sessionID,
+		UserID:           userID,
+		RefreshTokenHash: &refresh.Hash,
+		RefreshExpiresAt: &refreshExpiresAt,
+		RememberMe:       rememberMe,
+		UserAgent:        client.UserAgent,
+		IP:               client.IP,
+		ExpiresAt:        expiresAt,
+		ClientID:         grant.ClientID,
+		Scopes:           grant.Scopes,
+	})
 	if err != nil {
-		s.log.Error(ctx, "service auth: save refresh token error", err.Error())
+		s.log.Error(ctx, "service auth: create session error", err.Error())
 		return "", "", err
 	}
 
-	return access, refresh, nil
+	return access, refresh.Token, nil
 }
 
-func (s *ServiceAuth) ParseAccessToken(ctx context.Context, token string) (uuid.UUID, error) {
-	userIdStr, err := s.tokens.ParseAccessToken(ctx, token)
+// ParseAccessToken authenticates a request carrying token, which must be
+// addressed to one of audiences. Personal access tokens are accepted as well.
+func (s *ServiceAuth) ParseAccessToken(ctx context.Context, token string, audiences []string) (domain.Identity, error) {
+	if s.tokens.IsPersonalAccessToken(token) {
+		return s.parsePersonalAccessToken(ctx, token)
+	}
+
+	claims, err := s.tokens.ParseAccessToken(ctx, token, audiences)
 	if err != nil {
 		s.log.Error(ctx, "parse token error", err.Error())
-		return uuid.UUID{}, err
+		return domain.Identity{}, err
+	}
+
+	return identityFromClaims(claims)
+}
+
+func identityFromClaims(claims domain.AccessTokenClaims) (domain.Identity, error) {
+	// Client tokens authenticate services, not users.
+	if claims.SubjectType != domain.SubjectTypeUser {
+		return domain.Identity{}, ErrNotUserToken
+	}
+	// Delegated tokens let a service read on the user's behalf; they must not
+	// manage the user's account or sessions.
+	if claims.Actor != nil {
+		return domain.Identity{}, ErrDelegatedToken
 	}
 
-	userID, err := uuid.Parse(userIdStr)
+	userID, err := uuid.Parse(claims.UserID)
 	if err != nil {
-		return uuid.UUID{}, err
+		return domain.Identity{}, err
 	}
 
-	return userID, nil
+	// Access tokens issued before sessions existed have no sid.
+	var sessionID uuid.UUID
+	if claims.SessionID != "" {
+		if sessionID, err = uuid.Parse(claims.SessionID); err != nil {
+			return domain.Identity{}, err
+		}
+	}
+
+	return domain.Identity{
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenID:   claims.TokenID,
+		ExpiresAt: claims.ExpiresAt,
+		ClientID:  claims.ClientID,
+		Scopes:    claims.Scopes,
+	}, nil
 }
 
+// ParseRefreshToken returns the id of the user a live refresh token belongs to.
 func (s *ServiceAuth) ParseRefreshToken(ctx context.Context, token string) (string, error) {
-	return s.tokens.ParseRefreshToken(ctx, token)
+	session, err := s.refreshSession(ctx, token, s.tokens.HashRefreshToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return "", err
+	}
+	if !refreshUsable(session) {
+		return "", ErrInvalidRefreshToken
+	}
+	return session.UserID.String(), nil
+}
+
+// accessToken issues a session's access token with the profile claims its
+// audience gets.
+func (s *ServiceAuth) accessToken(ctx context.Context, userID, sessionID uuid.UUID, audience []string, grant domain.Grant) (string, error) {
+	profile, err := s.claims.Build(ctx, userID, audience)
+	if err != nil {
+		return "", err
+	}
+	return s.tokens.NewAccessToken(userID.String(), sessionID.String(), audience, grant.Filter(profile), grant)
 }
-func (s *ServiceAuth) GenerateAccessToken(userId string) (string, error) {
-	return s.tokens.NewAccessToken(userId)
+
+func (s *ServiceAuth) JWKS() domain.JWKSet {
+	return s.tokens.JWKS()
 }
 func hashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -109,74 +310,178 @@ func checkPassword(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-func (s *ServiceAuth) Logout(ctx context.Context, accessToken string) error {
-	userIdStr, err := s.tokens.ParseAccessToken(ctx, accessToken)
-	if err != nil {
+// Logout ends the session the access token was issued for. The access token
+// itself is denylisted, so it stops working immediately.
+func (s *ServiceAuth) Logout(ctx context.Context, identity domain.Identity) error {
+	if err := s.tokens.RevokeAccessToken(ctx, identity.TokenID, identity.ExpiresAt); err != nil {
+		s.log.Error(ctx, "logout: revoke access token error", err.Error())
 		return err
 	}
 
-	userID, err := uuid.Parse(userIdStr)
-	if err != nil {
-		return err
+	// Without a sid there is no way to tell which device this is.
+	if identity.SessionID == uuid.Nil {
+		return s.LogoutAll(ctx, identity.UserID)
 	}
 
-	return s.repo.DeleteRefreshToken(ctx, userID)
+	err := s.RevokeSession(ctx, identity.UserID, identity.SessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil
+	}
+	return err
 }
 
-func (s *ServiceAuth) Me(ctx context.Context, accessToken string) (*domain.User, error) {
-	userIdStr, err := s.tokens.ParseAccessToken(ctx, accessToken)
+func (s *ServiceAuth) Me(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	userID, err := uuid.Parse(userIdStr)
+	return &user, nil
+}
+
+// UpdateProfile edits the user's profile. versions are the UpdatedAt values
+// the client accepts; if the profile is at none of them, nothing is written
+// and ErrProfileModified is returned, so that concurrent edits do not silently
+// overwrite each other. Nil versions apply the edit to whatever the profile
+// currently is. Access tokens carry the new name and locale from the next
+// refresh.
+func (s *ServiceAuth) UpdateProfile(ctx context.Context, userID uuid.UUID, update domain.ProfileUpdate, versions []time.Time) (domain.User, error) {
+	user, err := s.repo.UpdateProfile(ctx, userID, update, versions)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.User{}, ErrProfileModified
+	}
 	if err != nil {
-		return nil, err
+		return domain.User{}, err
 	}
+	return user, nil
+}
 
-	user, err := s.repo.GetUserByID(ctx, userID)
+// Refresh rotates a refresh token inside its family. Presenting a token that was
+// already rotated means it was copied: the family is revoked, so neither the
+// attacker's nor the victim's newer token keeps working, and a security event
+// is recorded.
+//
+// The new refresh token gets a fresh idle timeout, capped by the absolute end
+// of the session. Sessions opened for an OIDC client are only refreshed by
+// that client; clientID is empty for the service's own login sessions.
+func (s *ServiceAuth) Refresh(ctx context.Context, refreshToken, clientID string, client domain.ClientInfo, audience []string) (string, string, error) {
+	tokenHash := s.tokens.HashRefreshToken(refreshToken)
+
+	session, err := s.refreshSession(ctx, refreshToken, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		if used, err := s.repo.GetUsedRefreshToken(ctx, tokenHash); err == nil {
+			return "", "", s.revokeFamily(ctx, used.UserID, used.FamilyID, tokenHash)
+		}
+		return "", "", ErrInvalidRefreshToken
+	}
 	if err != nil {
-		return nil, err
+		return "", "", err
 	}
 
-	return &user, nil
-}
-func (s *ServiceAuth) Refresh(ctx context.Context, refreshToken string) (string, string, error) {
-	userIdStr, err := s.tokens.ParseRefreshToken(ctx, refreshToken)
+	if !refreshUsable(session) {
+		return "", "", ErrInvalidRefreshToken
+	}
+	if session.ClientID != clientID {
+		s.log.Warn(ctx, "refresh token presented by another client",
+			"session_id", session.Id.String(), "client_id", clientID)
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	newAccess, err := s.accessToken(ctx, session.UserID, session.Id, audience, session.Grant())
 	if err != nil {
-		s.log.Error(ctx, "refresh: parse error", err.Error())
 		return "", "", err
 	}
 
-	userID, err := uuid.Parse(userIdStr)
+	newRefresh, err := s.tokens.NewRefreshToken()
 	if err != nil {
 		return "", "", err
 	}
 
-	dbToken, err := s.repo.GetRefreshToken(ctx, userID)
+	nextExpiresAt := s.policies.For(session.RememberMe).RefreshExpiry(time.Now(), session.ExpiresAt)
+
+	err = s.repo.RotateRefreshToken(ctx, domain.UsedRefreshToken{
+		TokenHash: tokenHash,
+		FamilyID:  session.Id,
+		UserID:    session.UserID,
+		ExpiresAt: *session.RefreshExpiresAt,
+	}, newRefresh.Hash, nextExpiresAt, client)
+	if errors.Is(err, domain.ErrRefreshTokenReused) {
+		return "", "", s.revokeFamily(ctx, session.UserID, session.Id, tokenHash)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrInvalidRefreshToken
+	}
 	if err != nil {
-		s.log.Error(ctx, "refresh: db token error", err.Error())
 		return "", "", err
 	}
 
-	if dbToken != refreshToken {
-		return "", "", ErrInvalidRefreshToken
+	return newAccess, newRefresh.Token, nil
+}
+
+// refreshSession finds the session whose current refresh token is refreshToken,
+// or returns sql.ErrNoRows. Opaque tokens are looked up by hash. JWT refresh
+// tokens issued before the switch are verified first and then matched against
+// the hash of their session, so clients holding one keep working and get an
+// opaque token on their next refresh.
+func (s *ServiceAuth) refreshSession(ctx context.Context, refreshToken, tokenHash string) (domain.Session, error) {
+	if !s.tokens.IsJWTRefreshToken(refreshToken) {
+		return s.repo.GetSessionByRefreshTokenHash(ctx, tokenHash)
 	}
 
-	newAccess, err := s.tokens.NewAccessToken(userID.String())
+	claims, err := s.tokens.ParseRefreshToken(ctx, refreshToken)
 	if err != nil {
-		return "", "", err
+		s.log.Error(ctx, "refresh: parse error", err.Error())
+		return domain.Session{}, ErrInvalidRefreshToken
+	}
+	// Tokens issued before families were introduced carry no fam.
+	familyID, err := uuid.Parse(claims.FamilyID)
+	if err != nil {
+		return domain.Session{}, ErrInvalidRefreshToken
 	}
 
-	newRefresh, err := s.tokens.NewRefreshToken(userID.String())
+	session, err := s.repo.GetSession(ctx, familyID)
 	if err != nil {
-		return "", "", err
+		return domain.Session{}, err
+	}
+	if session.RefreshTokenHash == nil || *session.RefreshTokenHash != tokenHash {
+		return domain.Session{}, sql.ErrNoRows
+	}
+	return session, nil
+}
+
+// refreshUsable reports whether the session's refresh token may still be used:
+// the session is neither revoked, idle for longer than its idle timeout, nor
+// past its absolute end.
+func refreshUsable(session domain.Session) bool {
+	now := time.Now()
+	return session.RevokedAt == nil &&
+		session.RefreshExpiresAt != nil &&
+		now.Before(*session.RefreshExpiresAt) &&
+		now.Before(session.ExpiresAt)
+}
+
+func (s *ServiceAuth) revokeFamily(ctx context.Context, userID, familyID uuid.UUID, tokenHash string) error {
+	s.log.Warn(ctx, "refresh token reuse detected",
+		"user_id", userID.String(),
+		"family_id", familyID.String(),
+	)
+
+	err := s.RevokeSession(ctx, userID, familyID)
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return err
 	}
 
-	err = s.repo.SaveRefreshToken(ctx, userID, newRefresh)
+	err = s.events.CreateSecurityEvent(ctx, domain.SecurityEvent{
+		UserID: userID,
+		Type:   domain.SecurityEventRefreshTokenReuse,
+		Details: map[string]string{
+			"family_id":  familyID.String(),
+			"token_hash": tokenHash,
+		},
+	})
 	if err != nil {
-		return "", "", err
+		s.log.Error(ctx, "refresh: record security event error", err.Error())
 	}
 
-	return newAccess, newRefresh, nil
+	return domain.ErrRefreshTokenReused
 }
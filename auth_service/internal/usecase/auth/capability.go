@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"slices"
+	"time"
+)
+
+// capabilityTTL is how long a download link works if nobody uses it.
+const capabilityTTL = 5 * time.Minute
+
+var (
+	ErrUnknownAction     = errors.New("unknown capability action")
+	ErrInvalidCapability = errors.New("capability token is invalid, expired or already used")
+)
+
+// CreateCapability issues a single-use token allowing the user to perform
+// action on the file stored under fileKey. auth_service does not know who may
+// access which file: the service redeeming the token checks that for the
+// returned user.
+func (s *ServiceAuth) CreateCapability(ctx context.Context, userID uuid.UUID, fileKey, action string) (domain.Capability, string, error) {
+	if !slices.Contains(domain.CapabilityActions, action) {
+		return domain.Capability{}, "", fmt.Errorf("%w: %q", ErrUnknownAction, action)
+	}
+
+	capability := domain.Capability{
+		TokenID:   uuid.New(),
+		UserID:    userID,
+		FileKey:   fileKey,
+		Action:    action,
+		ExpiresAt: time.Now().Add(capabilityTTL),
+	}
+	token, err := s.tokens.NewCapabilityToken(capability)
+	if err != nil {
+		s.log.Error(ctx, "service auth: capability token generation error", err.Error())
+		return domain.Capability{}, "", err
+	}
+
+	return capability, token, nil
+}
+
+// RedeemCapability checks that token grants action on fileKey and uses it up.
+// Only the first of several concurrent calls with the same token succeeds.
+func (s *ServiceAuth) RedeemCapability(ctx context.Context, token, fileKey, action string) (domain.Capability, error) {
+	capability, err := s.tokens.ParseCapabilityToken(ctx, token)
+	if err != nil {
+		s.log.Debug(ctx, "capability token rejected", "error", err.Error())
+		return domain.Capability{}, ErrInvalidCapability
+	}
+	if capability.FileKey != fileKey || capability.Action != action {
+		s.log.Warn(ctx, "capability token used for another file or action",
+			"user_id", capability.UserID.String(), "file_key", fileKey, "action", action)
+		return domain.Capability{}, ErrInvalidCapability
+	}
+
+	err = s.capabilities.RedeemCapability(ctx, capability)
+	if errors.Is(err, domain.ErrCapabilityRedeemed) {
+		s.log.Warn(ctx, "capability token replayed", "user_id", capability.UserID.String(), "token_id", capability.TokenID.String())
+		return domain.Capability{}, ErrInvalidCapability
+	}
+	if err != nil {
+		return domain.Capability{}, err
+	}
+
+	return capability, nil
+}
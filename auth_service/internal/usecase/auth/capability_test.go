@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memCapabilities behaves like the Postgres repository at its most eager:
+// records are dropped the moment their token expires.
+type memCapabilities struct {
+	mu       sync.Mutex
+	redeemed map[uuid.UUID]time.Time
+}
+
+func (r *memCapabilities) RedeemCapability(ctx context.Context, capability domain.Capability) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, expiresAt := range r.redeemed {
+		if !expiresAt.After(time.Now()) {
+			delete(r.redeemed, id)
+		}
+	}
+	if _, ok := r.redeemed[capability.TokenID]; ok {
+		return domain.ErrCapabilityRedeemed
+	}
+	r.redeemed[capability.TokenID] = capability.ExpiresAt
+	return nil
+}
+
+func newCapabilityService(t *testing.T) (*ServiceAuth, *memCapabilities) {
+	tokens := newTestTokens(t)
+	capabilities := &memCapabilities{redeemed: map[uuid.UUID]time.Time{}}
+	return &ServiceAuth{capabilities: capabilities, tokens: tokens, log: testLog}, capabilities
+}
+
+func TestRedeemCapability(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newCapabilityService(t)
+
+	_, token, err := s.CreateCapability(ctx, uuid.New(), "lectures/1.pdf", domain.CapabilityActionDownload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.RedeemCapability(ctx, token, "lectures/2.pdf", domain.CapabilityActionDownload); !errors.Is(err, ErrInvalidCapability) {
+		t.Fatalf("redeem for another file: got %v, want ErrInvalidCapability", err)
+	}
+	if _, err := s.RedeemCapability(ctx, token, "lectures/1.pdf", domain.CapabilityActionDownload); err != nil {
+		t.Fatalf("first redeem: %v", err)
+	}
+	if _, err := s.RedeemCapability(ctx, token, "lectures/1.pdf", domain.CapabilityActionDownload); !errors.Is(err, ErrInvalidCapability) {
+		t.Fatalf("second redeem: got %v, want ErrInvalidCapability", err)
+	}
+}
+
+// A token redeemed before it expired must not be redeemable again in the
+// leeway window after exp, when its record may already be gone.
+func TestRedeemCapabilityJustAfterExpiry(t *testing.T) {
+	ctx := context.Background()
+	s, capabilities := newCapabilityService(t)
+
+	capability := domain.Capability{
+		TokenID:   uuid.New(),
+		UserID:    uuid.New(),
+		FileKey:   "lectures/1.pdf",
+		Action:    domain.CapabilityActionDownload,
+		ExpiresAt: time.Now().Add(-time.Second),
+	}
+	token, err := s.tokens.NewCapabilityToken(capability)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Redeemed before it expired
+	capabilities.redeemed[capability.TokenID] = capability.ExpiresAt
+
+	if _, err := s.RedeemCapability(ctx, token, capability.FileKey, capability.Action); !errors.Is(err, ErrInvalidCapability) {
+		t.Fatalf("replay 1s after exp: got %v, want ErrInvalidCapability", err)
+	}
+	if _, err := s.RedeemCapability(ctx, token, capability.FileKey, capability.Action); !errors.Is(err, ErrInvalidCapability) {
+		t.Fatalf("second replay 1s after exp: got %v, want ErrInvalidCapability", err)
+	}
+}
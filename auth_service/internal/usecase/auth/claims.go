@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/repository"
+	"context"
+	"github.com/google/uuid"
+	"slices"
+)
+
+// AudienceResolver resolves the audience an access token is actually issued
+// for, which is the default one when the request names none.
+type AudienceResolver interface {
+	ResolveAudience(audience []string) ([]string, error)
+}
+
+// ClaimsBuilder picks the profile claims of a user's access token. Each
+// audience only gets the claims the policy lists for it, so tokens stay small
+// and services do not learn more about the user than they need.
+type ClaimsBuilder struct {
+	users     repository.Auth
+	audiences AudienceResolver
+	policy    domain.ClaimsPolicy
+}
+
+func NewClaimsBuilder(users repository.Auth, audiences AudienceResolver, policy domain.ClaimsPolicy) *ClaimsBuilder {
+	return &ClaimsBuilder{
+		users:     users,
+		audiences: audiences,
+		policy:    policy,
+	}
+}
+
+// Build returns the profile claims of an access token issued to the user for
+// audience. The user is only loaded when the audience gets any claims.
+func (b *ClaimsBuilder) Build(ctx context.Context, userID uuid.UUID, audience []string) (domain.ProfileClaims, error) {
+	audience, err := b.audiences.ResolveAudience(audience)
+	if err != nil {
+		return domain.ProfileClaims{}, err
+	}
+	names := b.policy.For(audience)
+	if len(names) == 0 {
+		return domain.ProfileClaims{}, nil
+	}
+
+	user, err := b.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return domain.ProfileClaims{}, err
+	}
+
+	var claims domain.ProfileClaims
+	if slices.Contains(names, domain.ClaimRoles) {
+		claims.Roles = user.Roles
+	}
+	if slices.Contains(names, domain.ClaimEmail) {
+		claims.Email = user.Email
+	}
+	if slices.Contains(names, domain.ClaimEmailVerified) {
+		claims.EmailVerified = &user.EmailVerified
+	}
+	if slices.Contains(names, domain.ClaimLocale) {
+		claims.Locale = user.Locale
+	}
+	if slices.Contains(names, domain.ClaimName) {
+		claims.Name = user.DisplayName()
+	}
+	return claims, nil
+}
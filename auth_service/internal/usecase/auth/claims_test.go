@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+var testClaimsPolicy = domain.ClaimsPolicy{
+	"auth-service":    {domain.ClaimEmail, domain.ClaimEmailVerified},
+	"content-service": {domain.ClaimRoles, domain.ClaimName},
+}
+
+func TestClaimsBuilder(t *testing.T) {
+	verified := true
+
+	tests := []struct {
+		name     string
+		policy   domain.ClaimsPolicy
+		audience []string
+		unknown  bool // the user does not exist
+		want     domain.ProfileClaims
+		wantErr  error
+	}{
+		{
+			name:   "default audience",
+			policy: testClaimsPolicy,
+			want:   domain.ProfileClaims{Email: "john@example.com", EmailVerified: &verified},
+		},
+		{
+			name:     "requested audience",
+			policy:   testClaimsPolicy,
+			audience: []string{"content-service"},
+			want:     domain.ProfileClaims{Roles: []string{"teacher"}, Name: "John Doe"},
+		},
+		{
+			name:     "union of several audiences",
+			policy:   testClaimsPolicy,
+			audience: []string{"auth-service", "content-service"},
+			want:     domain.ProfileClaims{Roles: []string{"teacher"}, Email: "john@example.com", EmailVerified: &verified, Name: "John Doe"},
+		},
+		{
+			name:     "audience without claims does not load the user",
+			policy:   domain.ClaimsPolicy{"auth-service": {domain.ClaimLocale}},
+			audience: []string{"content-service"},
+			unknown:  true,
+		},
+		{
+			name:     "audience not allowed",
+			policy:   testClaimsPolicy,
+			audience: []string{"billing"},
+			wantErr:  domain.ErrInvalidAudience,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := newMemSessions()
+			user := addProfileUser(t, users)
+			if tt.unknown {
+				delete(users.users, user.Username)
+			}
+			b := NewClaimsBuilder(users, newTestTokens(t), tt.policy)
+
+			got, err := b.Build(context.Background(), user.Id, tt.audience)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Build() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Build() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Access tokens issued at login and on refresh carry the profile claims of
+// their audience.
+func TestLoginProfileClaims(t *testing.T) {
+	ctx := context.Background()
+	sessions := newMemSessions()
+	user := addProfileUser(t, sessions)
+	tokens := newTestTokens(t)
+	s := NewServiceAuth(sessions, &recordingEvents{}, newMemPATs(), nil, nil, nil, testLog, tokens, NewClaimsBuilder(sessions, tokens, testClaimsPolicy), &recordingMailer{}, testPolicies, testLinks)
+
+	access, refresh, err := s.Login(ctx, user.Username, "password123", domain.ClientInfo{}, []string{"content-service"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := tokens.ParseAccessToken(ctx, access, []string{"content-service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(claims.Profile.Roles, []string{"teacher"}) || claims.Profile.Name != "John Doe" || claims.Profile.Email != "" {
+		t.Errorf("profile claims = %+v, want roles and name only", claims.Profile)
+	}
+
+	access, _, err = s.Refresh(ctx, refresh, "", domain.ClientInfo{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err = tokens.ParseAccessToken(ctx, access, []string{"auth-service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Profile.Email != "john@example.com" || claims.Profile.Roles != nil {
+		t.Errorf("profile claims after refresh = %+v, want those of the default audience", claims.Profile)
+	}
+}
+
+// Access tokens issued to an OIDC client only carry the profile claims of the
+// scopes the user granted it.
+func TestGrantProfileClaims(t *testing.T) {
+	verified := true
+	policy := domain.ClaimsPolicy{"content-service": domain.ProfileClaimNames}
+
+	tests := []struct {
+		name  string
+		grant domain.Grant
+		want  domain.ProfileClaims
+	}{
+		{
+			name:  "first-party login",
+			grant: domain.Grant{},
+			want:  domain.ProfileClaims{Roles: []string{"teacher"}, Email: "john@example.com", EmailVerified: &verified, Locale: "de", Name: "John Doe"},
+		},
+		{
+			name:  "openid only",
+			grant: domain.Grant{ClientID: "web", Scopes: []string{"openid"}},
+		},
+		{
+			name:  "profile",
+			grant: domain.Grant{ClientID: "web", Scopes: []string{"openid", "profile"}},
+			want:  domain.ProfileClaims{Locale: "de", Name: "John Doe"},
+		},
+		{
+			name:  "email",
+			grant: domain.Grant{ClientID: "web", Scopes: []string{"openid", "email"}},
+			want:  domain.ProfileClaims{Email: "john@example.com", EmailVerified: &verified},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sessions := newMemSessions()
+			user := addProfileUser(t, sessions)
+			tokens := newTestTokens(t)
+			s := NewServiceAuth(sessions, &recordingEvents{}, newMemPATs(), nil, nil, nil, testLog, tokens, NewClaimsBuilder(sessions, tokens, policy), &recordingMailer{}, testPolicies, testLinks)
+
+			access, refresh, err := s.StartSession(ctx, user.Id, domain.ClientInfo{}, []string{"content-service"}, false, tt.grant)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := tokens.ParseAccessToken(ctx, access, []string{"content-service"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(claims.Profile, tt.want) {
+				t.Errorf("profile claims = %+v, want %+v", claims.Profile, tt.want)
+			}
+
+			access, _, err = s.Refresh(ctx, refresh, tt.grant.ClientID, domain.ClientInfo{}, []string{"content-service"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err = tokens.ParseAccessToken(ctx, access, []string{"content-service"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(claims.Profile, tt.want) {
+				t.Errorf("profile claims after refresh = %+v, want %+v", claims.Profile, tt.want)
+			}
+		})
+	}
+}
+
+func addProfileUser(t *testing.T, users *memSessions) domain.User {
+	t.Helper()
+	user := users.addUser(t, "john_doe", "password123")
+	user.FirstName, user.LastName = "John", "Doe"
+	user.Email, user.EmailVerified = "john@example.com", true
+	user.Roles, user.Locale = []string{"teacher"}, "de"
+	users.users[user.Username] = user
+	return user
+}
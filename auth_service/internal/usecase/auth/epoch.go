@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"strconv"
+)
+
+var ErrUserNotFound = errors.New("user not found")
+
+// RevokeUserTokens is the break-glass revocation for a compromised account:
+// every access token of the user stops working at once, along with the
+// sessions, personal access tokens and tickets. Returns the user's new token
+// epoch.
+func (s *ServiceAuth) RevokeUserTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	epoch, err := s.tokens.RevokeUserTokens(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUserNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	s.log.Warn(ctx, "all tokens of user revoked", "user_id", userID.String(), "epoch", epoch)
+	err = s.events.CreateSecurityEvent(ctx, domain.SecurityEvent{
+		UserID:  userID,
+		Type:    domain.SecurityEventTokensRevoked,
+		Details: map[string]string{"epoch": strconv.FormatInt(epoch, 10)},
+	})
+	if err != nil {
+		s.log.Error(ctx, "revoke user tokens: record security event error", err.Error())
+	}
+
+	return epoch, nil
+}
+
+// RevokeAllTokens invalidates every token of every user and service, e.g.
+// after a signing key leaked. Everyone has to log in again. Returns the new
+// global token epoch.
+func (s *ServiceAuth) RevokeAllTokens(ctx context.Context) (int64, error) {
+	epoch, err := s.tokens.RevokeAllTokens(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	s.log.Warn(ctx, "all tokens revoked", "epoch", epoch)
+	return epoch, nil
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	jwtauth "auth_service/internal/infrastructure/auth"
+	"auth_service/internal/infrastructure/logger"
+	"context"
+	"github.com/google/uuid"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testLog = logger.New("test")
+
+func testPolicy() jwtauth.TokenPolicy {
+	return jwtauth.TokenPolicy{
+		Issuer:         "auth-service",
+		AccessTTL:      30 * time.Minute,
+		DelegatedTTL:   5 * time.Minute,
+		RefreshTTL:     168 * time.Hour,
+		Leeway:         30 * time.Second,
+		NotBefore:      true,
+		RequiredClaims: []string{"exp", "iat", "iss", "jti"},
+		Audiences: jwtauth.AudiencePolicy{
+			Allowed:  []string{"auth-service", "content-service"},
+			Default:  []string{"auth-service"},
+			Accepted: []string{"auth-service"},
+		},
+	}
+}
+
+var testPolicies = domain.SessionPolicies{
+	Default:    domain.SessionPolicy{IdleTimeout: time.Hour, MaxLifetime: 24 * time.Hour},
+	RememberMe: domain.SessionPolicy{IdleTimeout: 7 * 24 * time.Hour, MaxLifetime: 30 * 24 * time.Hour},
+}
+
+// newTestTokens returns a token manager signing with HMAC keys, backed by an
+// in-memory denylist.
+func newTestTokens(t *testing.T) *jwtauth.TokenManager {
+	t.Helper()
+	accessKeys, err := jwtauth.NewKeyring(mustHMACKey(t, "test-access-secret-0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshKeys, err := jwtauth.NewKeyring(mustHMACKey(t, "test-refresh-secret-0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := jwtauth.NewTokenManager(accessKeys, refreshKeys,
+		jwtauth.NewDenylist(&memDenylistStore{}, testLog),
+		jwtauth.NewEpochs(&memEpochStore{users: map[uuid.UUID]int64{}}, testLog),
+		testPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+// newTestService returns a service with the test token manager and session
+// policies. Access tokens carry no profile claims, and emails are recorded
+// rather than sent.
+func newTestService(t *testing.T, sessions *memSessions, events *recordingEvents, pats *memPATs) *ServiceAuth {
+	t.Helper()
+	tokens := newTestTokens(t)
+	claims := NewClaimsBuilder(sessions, tokens, domain.ClaimsPolicy{})
+	return NewServiceAuth(sessions, events, pats, nil, nil, nil, testLog, tokens, claims, &recordingMailer{}, testPolicies, testLinks)
+}
+
+var testLinks = Links{
+	VerifyEmail:   "https://app.example.com/verify-email",
+	ResetPassword: "https://app.example.com/reset-password",
+}
+
+// recordingMailer keeps the emails it is asked to send.
+type recordingMailer struct {
+	mu     sync.Mutex
+	emails []domain.Email
+}
+
+func (m *recordingMailer) Send(ctx context.Context, email domain.Email) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.emails = append(m.emails, email)
+	return nil
+}
+
+func mustHMACKey(t *testing.T, secret string) *jwtauth.SigningKey {
+	t.Helper()
+	key, err := jwtauth.NewHMACKey(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// memDenylistStore is the revoked_tokens table.
+type memDenylistStore struct {
+	mu      sync.Mutex
+	revoked map[uuid.UUID]time.Time
+}
+
+func (s *memDenylistStore) RevokeToken(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.revoked == nil {
+		s.revoked = map[uuid.UUID]time.Time{}
+	}
+	if expiresAt.After(s.revoked[id]) {
+		s.revoked[id] = expiresAt
+	}
+	return nil
+}
+
+func (s *memDenylistStore) ListRevokedTokens(ctx context.Context) ([]domain.RevokedToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var revoked []domain.RevokedToken
+	for id, expiresAt := range s.revoked {
+		if expiresAt.After(time.Now()) {
+			revoked = append(revoked, domain.RevokedToken{ID: id, ExpiresAt: expiresAt})
+		}
+	}
+	return revoked, nil
+}
+
+func (s *memDenylistStore) DeleteExpiredRevocations(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for id, expiresAt := range s.revoked {
+		if !expiresAt.After(time.Now()) {
+			delete(s.revoked, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+type memEpochStore struct {
+	mu     sync.Mutex
+	global int64
+	users  map[uuid.UUID]int64
+}
+
+func (s *memEpochStore) GetTokenEpochs(ctx context.Context) (domain.TokenEpochs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := map[uuid.UUID]int64{}
+	for id, epoch := range s.users {
+		users[id] = epoch
+	}
+	return domain.TokenEpochs{Global: s.global, Users: users}, nil
+}
+
+func (s *memEpochStore) IncrementGlobalTokenEpoch(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.global++
+	return s.global, nil
+}
+
+func (s *memEpochStore) IncrementUserTokenEpoch(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[userID]++
+	return s.users[userID], nil
+}
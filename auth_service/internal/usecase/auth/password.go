@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	minPasswordLength = 6
+	// bcrypt ignores everything after the 72nd byte
+	maxPasswordBytes = 72
+
+	passwordResetTTL = time.Hour
+	// passwordResetInterval is how often a reset email may be sent to the same
+	// account; the forgot password endpoint needs no login.
+	passwordResetInterval = time.Minute
+)
+
+var (
+	ErrWeakPassword      = errors.New("password does not meet the password policy")
+	ErrWrongPassword     = errors.New("current password is incorrect")
+	ErrInvalidResetToken = errors.New("password reset link is invalid, expired or already used")
+)
+
+// checkPasswordPolicy tells whether password may be set as a user's password.
+func checkPasswordPolicy(password string) error {
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return fmt.Errorf("%w: use at least %d characters", ErrWeakPassword, minPasswordLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("%w: use at most %d bytes", ErrWeakPassword, maxPasswordBytes)
+	}
+	return nil
+}
+
+// ForgotPassword emails a password reset link to the account registered with
+// email, if there is one. It returns at once and does the work in the
+// background, so that neither the result nor the response time reveals
+// whether the account exists.
+func (s *ServiceAuth) ForgotPassword(ctx context.Context, email string) {
+	go func(ctx context.Context) {
+		if err := s.sendPasswordReset(ctx, email); err != nil {
+			s.log.Error(ctx, "service auth: send password reset error", err.Error())
+		}
+	}(context.WithoutCancel(ctx))
+}
+
+func (s *ServiceAuth) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.log.Info(ctx, "password reset requested for unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// GetUserByEmail only loads the credentials
+	if user, err = s.repo.GetUserByID(ctx, user.Id); err != nil {
+		return err
+	}
+
+	token, hash, err := s.tokens.NewPasswordResetToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = s.resets.CreatePasswordResetToken(ctx, domain.PasswordResetToken{
+		TokenHash: hash,
+		UserID:    user.Id,
+		CreatedAt: now,
+		ExpiresAt: now.Add(passwordResetTTL),
+	}, passwordResetInterval)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.log.Warn(ctx, "password reset throttled", "user_id", user.Id.String())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	link, err := withToken(s.links.ResetPassword, token)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, domain.Email{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nset a new password by opening the link below. It expires in an hour and works once.\n\n%s\n\nIf you did not ask for a new password, ignore this email; your password stays as it is.\n",
+			user.DisplayName(), link),
+	})
+}
+
+// ResetPassword sets a new password with the token of a reset link. Every
+// session and personal access token of the user is revoked with the password,
+// so a thief holding a refresh token or a token they created loses access
+// along with the old password.
+func (s *ServiceAuth) ResetPassword(ctx context.Context, token, password string) error {
+	// The token is single use: only consume it once the new password is known
+	// to be acceptable, so a rejected password can be corrected and retried.
+	if err := checkPasswordPolicy(password); err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		s.log.Error(ctx, "service auth: hash password error", err.Error())
+		return err
+	}
+
+	reset, err := s.resets.ConsumePasswordResetToken(ctx, s.tokens.HashPasswordResetToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if time.Now().After(reset.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	sessions, err := s.repo.UpdatePassword(ctx, reset.UserID, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.revokeSessionTokens(ctx, sessions); err != nil {
+		return err
+	}
+
+	s.log.Info(ctx, "password reset", "user_id", reset.UserID.String())
+	err = s.events.CreateSecurityEvent(ctx, domain.SecurityEvent{
+		UserID: reset.UserID,
+		Type:   domain.SecurityEventPasswordReset,
+	})
+	if err != nil {
+		s.log.Error(ctx, "reset password: record security event error", err.Error())
+	}
+	return nil
+}
+
+// ChangePassword replaces the password of a logged-in user who knows the
+// current one. Every session and personal access token is revoked, the
+// current session included, and the current device gets a new session with
+// the same remember me setting, whose tokens are returned.
+func (s *ServiceAuth) ChangePassword(ctx context.Context, identity domain.Identity, current, password string, client domain.ClientInfo, audience []string) (string, string, error) {
+	user, err := s.repo.GetUserByID(ctx, identity.UserID)
+	if err != nil {
+		return "", "", err
+	}
+	if err := checkPassword(current, user.Password); err != nil {
+		return "", "", ErrWrongPassword
+	}
+	if err := checkPasswordPolicy(password); err != nil {
+		return "", "", err
+	}
+	if _, err := s.tokens.ResolveAudience(audience); err != nil {
+		return "", "", err
+	}
+
+	// Access tokens issued before sessions existed have no sid.
+	rememberMe := false
+	if identity.SessionID != uuid.Nil {
+		session, err := s.repo.GetSession(ctx, identity.SessionID)
+		if err != nil {
+			return "", "", err
+		}
+		rememberMe = session.RememberMe
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		s.log.Error(ctx, "service auth: hash password error", err.Error())
+		return "", "", err
+	}
+	sessions, err := s.repo.UpdatePassword(ctx, user.Id, hash)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.revokeSessionTokens(ctx, sessions); err != nil {
+		return "", "", err
+	}
+	if err := s.tokens.RevokeAccessToken(ctx, identity.TokenID, identity.ExpiresAt); err != nil {
+		s.log.Error(ctx, "change password: revoke access token error", err.Error())
+		return "", "", err
+	}
+
+	s.log.Info(ctx, "password changed", "user_id", user.Id.String())
+	err = s.events.CreateSecurityEvent(ctx, domain.SecurityEvent{
+		UserID:  user.Id,
+		Type:    domain.SecurityEventPasswordChanged,
+		Details: map[string]string{"session_id": identity.SessionID.String()},
+	})
+	if err != nil {
+		s.log.Error(ctx, "change password: record security event error", err.Error())
+	}
+
+	return s.StartSession(ctx, user.Id, client, audience, rememberMe, domain.Grant{})
+}
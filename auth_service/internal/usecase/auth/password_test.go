@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"sync"
+	"testing"
+	"time"
+)
+
+// UpdatePassword revokes the user's sessions in the same step, like the
+// Postgres transaction.
+func (r *memSessions) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for username, user := range r.users {
+		if user.Id != userID {
+			continue
+		}
+		user.Password = passwordHash
+		r.users[username] = user
+
+		var ids []uuid.UUID
+		for _, session := range r.sessions {
+			if session.UserID == userID && session.RevokedAt == nil {
+				r.revoke(session)
+				ids = append(ids, session.Id)
+			}
+		}
+		return ids, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+// memResets stores password reset tokens the way the repository does: one
+// token per interval and user, consumed on first read.
+type memResets struct {
+	mu     sync.Mutex
+	tokens map[string]domain.PasswordResetToken
+}
+
+func (r *memResets) CreatePasswordResetToken(ctx context.Context, token domain.PasswordResetToken, interval time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.tokens {
+		if existing.UserID == token.UserID && existing.CreatedAt.After(token.CreatedAt.Add(-interval)) {
+			return sql.ErrNoRows
+		}
+	}
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (r *memResets) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (domain.PasswordResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return domain.PasswordResetToken{}, sql.ErrNoRows
+	}
+	delete(r.tokens, tokenHash)
+	return token, nil
+}
+
+func newPasswordResetService(t *testing.T) (*ServiceAuth, *memSessions, *memResets, *recordingMailer, *recordingEvents, domain.User) {
+	t.Helper()
+	users := newMemSessions()
+	user := users.addUser(t, "john_doe", "password123")
+	user.Email = "john.doe@example.com"
+	users.users[user.Username] = user
+
+	resets := &memResets{tokens: map[string]domain.PasswordResetToken{}}
+	mailer := &recordingMailer{}
+	events := &recordingEvents{}
+	s := newTestService(t, users, events, newMemPATs())
+	s.resets, s.mailer = resets, mailer
+	return s, users, resets, mailer, events, user
+}
+
+// A reset sets the new password, logs out every session and works once.
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+	s, _, _, mailer, events, user := newPasswordResetService(t)
+
+	access, _, err := s.Login(ctx, user.Username, "password123", domain.ClientInfo{}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.sendPasswordReset(ctx, "John.Doe@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.emails) != 1 || mailer.emails[0].To != user.Email {
+		t.Fatalf("emails = %+v, want one to %s", mailer.emails, user.Email)
+	}
+	token := mailedToken(t, mailer, testLinks.ResetPassword)
+
+	if err := s.ResetPassword(ctx, token, "new-password456"); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+	if _, err := s.Authenticate(ctx, user.Username, "password123"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("old password: error = %v, want %v", err, ErrInvalidCredentials)
+	}
+	if _, err := s.Authenticate(ctx, user.Username, "new-password456"); err != nil {
+		t.Errorf("new password: error = %v", err)
+	}
+	if _, err := s.tokens.ParseAccessToken(ctx, access, []string{"auth-service"}); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("access token issued before the reset: error = %v, want %v", err, domain.ErrTokenRevoked)
+	}
+	if len(events.events) != 1 || events.events[0].Type != domain.SecurityEventPasswordReset || events.events[0].UserID != user.Id {
+		t.Errorf("security events = %+v, want one password reset of the user", events.events)
+	}
+
+	if err := s.ResetPassword(ctx, token, "another-password789"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("second ResetPassword() error = %v, want %v", err, ErrInvalidResetToken)
+	}
+}
+
+func TestResetPasswordRejected(t *testing.T) {
+	ctx := context.Background()
+	s, _, resets, mailer, _, user := newPasswordResetService(t)
+
+	if err := s.ResetPassword(ctx, "unknown", "new-password456"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("unknown token: error = %v, want %v", err, ErrInvalidResetToken)
+	}
+
+	if err := s.sendPasswordReset(ctx, user.Email); err != nil {
+		t.Fatal(err)
+	}
+	token := mailedToken(t, mailer, testLinks.ResetPassword)
+	hash := s.tokens.HashPasswordResetToken(token)
+	expired := resets.tokens[hash]
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	resets.tokens[hash] = expired
+
+	if err := s.ResetPassword(ctx, token, "new-password456"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("expired token: error = %v, want %v", err, ErrInvalidResetToken)
+	}
+	if _, err := s.Authenticate(ctx, user.Username, "password123"); err != nil {
+		t.Errorf("password changed by a rejected reset: %v", err)
+	}
+}
+
+// Unknown addresses and repeated requests send nothing, and neither is an
+// error the caller could tell apart from success.
+func TestSendPasswordResetSilent(t *testing.T) {
+	ctx := context.Background()
+	s, _, _, mailer, _, user := newPasswordResetService(t)
+
+	if err := s.sendPasswordReset(ctx, "nobody@example.com"); err != nil {
+		t.Errorf("unknown email: error = %v", err)
+	}
+	if len(mailer.emails) != 0 {
+		t.Errorf("emails = %+v, want none", mailer.emails)
+	}
+
+	for range 2 {
+		if err := s.sendPasswordReset(ctx, user.Email); err != nil {
+			t.Errorf("sendPasswordReset() error = %v", err)
+		}
+	}
+	if len(mailer.emails) != 1 {
+		t.Errorf("%d emails sent, want 1", len(mailer.emails))
+	}
+}
+
+// A password the policy rejects leaves the link usable for another try.
+func TestResetPasswordWeakPassword(t *testing.T) {
+	ctx := context.Background()
+	s, _, _, mailer, _, user := newPasswordResetService(t)
+
+	if err := s.sendPasswordReset(ctx, user.Email); err != nil {
+		t.Fatal(err)
+	}
+	token := mailedToken(t, mailer, testLinks.ResetPassword)
+
+	if err := s.ResetPassword(ctx, token, "short"); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("weak password: error = %v, want %v", err, ErrWeakPassword)
+	}
+	if err := s.ResetPassword(ctx, token, "new-password456"); err != nil {
+		t.Errorf("retry with a strong password: error = %v", err)
+	}
+}
+
+// Changing the password logs out every device; the current one gets a new
+// session with the same remember me setting.
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	s, sessions, _, _, _, user := newPasswordResetService(t)
+
+	laptop, _, err := s.Login(ctx, user.Username, "password123", domain.ClientInfo{UserAgent: "laptop"}, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phone, _, err := s.Login(ctx, user.Username, "password123", domain.ClientInfo{UserAgent: "phone"}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := s.ParseAccessToken(ctx, laptop, []string{"auth-service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.ChangePassword(ctx, identity, "wrong", "new-password456", domain.ClientInfo{}, nil); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("wrong current password: error = %v, want %v", err, ErrWrongPassword)
+	}
+	access, _, err := s.ChangePassword(ctx, identity, "password123", "new-password456", domain.ClientInfo{UserAgent: "laptop"}, nil)
+	if err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+
+	for _, old := range []string{laptop, phone} {
+		if _, err := s.ParseAccessToken(ctx, old, []string{"auth-service"}); !errors.Is(err, domain.ErrTokenRevoked) {
+			t.Errorf("access token issued before the change: error = %v, want %v", err, domain.ErrTokenRevoked)
+		}
+	}
+	current, err := s.ParseAccessToken(ctx, access, []string{"auth-service"})
+	if err != nil {
+		t.Fatalf("new access token: %v", err)
+	}
+	if session := sessions.sessions[current.SessionID]; session.RevokedAt != nil || !session.RememberMe {
+		t.Errorf("new session = %+v, want an active remember me session", session)
+	}
+	if _, err := s.Authenticate(ctx, user.Username, "new-password456"); err != nil {
+		t.Errorf("new password: error = %v", err)
+	}
+}
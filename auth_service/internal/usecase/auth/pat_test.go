@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memPATs stores personal access tokens the way the repository does: revoked
+// tokens are neither listed nor found by hash.
+type memPATs struct {
+	mu     sync.Mutex
+	tokens map[uuid.UUID]domain.PersonalAccessToken
+}
+
+func newMemPATs() *memPATs {
+	return &memPATs{tokens: map[uuid.UUID]domain.PersonalAccessToken{}}
+}
+
+func (r *memPATs) CreatePersonalAccessToken(ctx context.Context, token domain.PersonalAccessToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[token.Id] = token
+	return nil
+}
+
+func (r *memPATs) ListPersonalAccessTokens(ctx context.Context, userID uuid.UUID) ([]domain.PersonalAccessToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tokens := []domain.PersonalAccessToken{}
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func (r *memPATs) GetPersonalAccessTokenByHash(ctx context.Context, tokenHash string) (domain.PersonalAccessToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash && token.RevokedAt == nil {
+			return token, nil
+		}
+	}
+	return domain.PersonalAccessToken{}, sql.ErrNoRows
+}
+
+func (r *memPATs) TouchPersonalAccessToken(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token := r.tokens[id]
+	now := time.Now()
+	token.LastUsedAt = &now
+	r.tokens[id] = token
+	return nil
+}
+
+func (r *memPATs) RevokePersonalAccessToken(ctx context.Context, userID, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[id]
+	if !ok || token.UserID != userID || token.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	token.RevokedAt = &now
+	r.tokens[id] = token
+	return nil
+}
+
+func TestCreatePersonalAccessToken(t *testing.T) {
+	future, past := time.Now().Add(time.Hour), time.Now().Add(-time.Second)
+
+	tests := []struct {
+		name       string
+		scopes     []string
+		expiresAt  *time.Time
+		wantScopes []string
+		wantErr    error
+	}{
+		{name: "without expiry", scopes: []string{domain.ScopeLecturesRead}, wantScopes: []string{domain.ScopeLecturesRead}},
+		{
+			name:       "scopes sorted without duplicates",
+			scopes:     []string{domain.ScopeProfileRead, domain.ScopeLecturesRead, domain.ScopeProfileRead},
+			expiresAt:  &future,
+			wantScopes: []string{domain.ScopeLecturesRead, domain.ScopeProfileRead},
+		},
+		{name: "no scopes", wantErr: ErrNoScopes},
+		{name: "unknown scope", scopes: []string{"admin"}, wantErr: ErrUnknownScope},
+		{name: "expiry in the past", scopes: []string{domain.ScopeLecturesRead}, expiresAt: &past, wantErr: ErrInvalidExpiry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pats := newMemPATs()
+			s := newTestService(t, newMemSessions(), &recordingEvents{}, pats)
+
+			pat, token, err := s.CreatePersonalAccessToken(context.Background(), uuid.New(), "ci", tt.scopes, tt.expiresAt)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreatePersonalAccessToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(pats.tokens) != 0 {
+					t.Error("rejected token was stored")
+				}
+				return
+			}
+			if !strings.HasPrefix(token, "pat_") {
+				t.Errorf("token %q lacks the pat_ prefix", token)
+			}
+			if pat.TokenHash == token || pats.tokens[pat.Id].TokenHash != s.tokens.HashPersonalAccessToken(token) {
+				t.Error("token not stored as its hash")
+			}
+			if !slices.Equal(pat.Scopes, tt.wantScopes) {
+				t.Errorf("Scopes = %v, want %v", pat.Scopes, tt.wantScopes)
+			}
+		})
+	}
+}
+
+func TestParsePersonalAccessToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(*memPATs, domain.PersonalAccessToken)
+		token   func(string) string
+		wantErr error
+	}{
+		{name: "valid"},
+		{name: "expired", setup: func(r *memPATs, pat domain.PersonalAccessToken) {
+			past := time.Now().Add(-time.Second)
+			pat.ExpiresAt = &past
+			r.tokens[pat.Id] = pat
+		}, wantErr: ErrInvalidPersonalAccessToken},
+		{name: "revoked", setup: func(r *memPATs, pat domain.PersonalAccessToken) {
+			now := time.Now()
+			pat.RevokedAt = &now
+			r.tokens[pat.Id] = pat
+		}, wantErr: ErrInvalidPersonalAccessToken},
+		{name: "unknown", token: func(token string) string { return token + "x" }, wantErr: ErrInvalidPersonalAccessToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			pats := newMemPATs()
+			s := newTestService(t, newMemSessions(), &recordingEvents{}, pats)
+			userID := uuid.New()
+			pat, token, err := s.CreatePersonalAccessToken(ctx, userID, "ci", []string{domain.ScopeLecturesRead}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.setup != nil {
+				tt.setup(pats, pat)
+			}
+			if tt.token != nil {
+				token = tt.token(token)
+			}
+
+			// Personal access tokens are not bound to an audience.
+			identity, err := s.ParseAccessToken(ctx, token, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseAccessToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if identity.UserID != userID || identity.PersonalAccessTokenID != pat.Id || identity.SessionID != uuid.Nil {
+				t.Errorf("identity = %+v, want user %s with token %s", identity, userID, pat.Id)
+			}
+			if !identity.HasScope(domain.ScopeLecturesRead) || identity.HasScope(domain.ScopeLecturesWrite) {
+				t.Errorf("identity scopes = %v, want only %s", identity.Scopes, domain.ScopeLecturesRead)
+			}
+			if pats.tokens[pat.Id].LastUsedAt == nil {
+				t.Error("last use not recorded")
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingEvents struct {
+	mu     sync.Mutex
+	events []domain.SecurityEvent
+}
+
+func (r *recordingEvents) CreateSecurityEvent(ctx context.Context, event domain.SecurityEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func newRefreshService(t *testing.T) (*ServiceAuth, *memSessions, *recordingEvents) {
+	sessions := newMemSessions()
+	events := &recordingEvents{}
+	return newTestService(t, sessions, events, newMemPATs()), sessions, events
+}
+
+// startSession opens a session, and so a new rotation family, for the user
+// and returns its refresh token.
+func startSession(t *testing.T, s *ServiceAuth, userID uuid.UUID) string {
+	t.Helper()
+	_, refresh, err := s.StartSession(context.Background(), userID, domain.ClientInfo{}, nil, false, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return refresh
+}
+
+// Presenting a rotated refresh token again revokes the whole family, so the
+// newer token stops working too, and records a security event.
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	s, _, events := newRefreshService(t)
+	userID := uuid.New()
+
+	first := startSession(t, s, userID)
+	_, second, err := s.Refresh(ctx, first, "", domain.ClientInfo{}, nil)
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+	if second == first {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	if _, _, err := s.Refresh(ctx, first, "", domain.ClientInfo{}, nil); !errors.Is(err, domain.ErrRefreshTokenReused) {
+		t.Fatalf("reused token: error = %v, want %v", err, domain.ErrRefreshTokenReused)
+	}
+	if _, _, err := s.Refresh(ctx, second, "", domain.ClientInfo{}, nil); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("newer token of the revoked family: error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if len(events.events) != 1 || events.events[0].Type != domain.SecurityEventRefreshTokenReuse || events.events[0].UserID != userID {
+		t.Errorf("security events = %+v, want one refresh token reuse of the user", events.events)
+	}
+}
+
+// When two requests race with the same refresh token, one of them gets the
+// new tokens and the other is treated as reuse.
+func TestRefreshConcurrentReuse(t *testing.T) {
+	ctx := context.Background()
+	s, _, events := newRefreshService(t)
+	refresh := startSession(t, s, uuid.New())
+
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, errs[i] = s.Refresh(ctx, refresh, "", domain.ClientInfo{}, nil)
+		}()
+	}
+	wg.Wait()
+
+	var ok, reused int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			ok++
+		case errors.Is(err, domain.ErrRefreshTokenReused):
+			reused++
+		default:
+			t.Errorf("Refresh() error = %v", err)
+		}
+	}
+	if ok != 1 || reused != 1 {
+		t.Errorf("succeeded %d, reuse detected %d; want 1 and 1", ok, reused)
+	}
+	if len(events.events) != 1 {
+		t.Errorf("%d security events, want 1", len(events.events))
+	}
+}
+
+// Every session is its own family; reusing a token of one session must not
+// revoke the others.
+func TestRefreshReuseKeepsOtherSessions(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newRefreshService(t)
+	userID := uuid.New()
+
+	old := startSession(t, s, userID)
+	if _, _, err := s.Refresh(ctx, old, "", domain.ClientInfo{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	current := startSession(t, s, userID)
+
+	if _, _, err := s.Refresh(ctx, old, "", domain.ClientInfo{}, nil); !errors.Is(err, domain.ErrRefreshTokenReused) {
+		t.Fatalf("reused token: error = %v, want %v", err, domain.ErrRefreshTokenReused)
+	}
+	if _, _, err := s.Refresh(ctx, current, "", domain.ClientInfo{}, nil); err != nil {
+		t.Errorf("token of the other session: %v", err)
+	}
+}
+
+// Refresh tokens are opaque and only their hash is stored.
+func TestRefreshTokenStoredHashed(t *testing.T) {
+	ctx := context.Background()
+	s, sessions, _ := newRefreshService(t)
+
+	refresh := startSession(t, s, uuid.New())
+	if strings.Contains(refresh, ".") {
+		t.Errorf("refresh token %q is not opaque", refresh)
+	}
+	for _, session := range sessions.sessions {
+		if session.RefreshTokenHash == nil || *session.RefreshTokenHash == refresh || *session.RefreshTokenHash != s.tokens.HashRefreshToken(refresh) {
+			t.Errorf("stored hash = %v, want the hash of the token", session.RefreshTokenHash)
+		}
+	}
+
+	for id, session := range sessions.sessions {
+		past := time.Now().Add(-time.Second)
+		session.RefreshExpiresAt = &past
+		sessions.sessions[id] = session
+	}
+	if _, _, err := s.Refresh(ctx, refresh, "", domain.ClientInfo{}, nil); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expired token: error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
+
+// JWT refresh tokens issued before the switch keep working once and are
+// replaced by an opaque token.
+func TestRefreshLegacyJWT(t *testing.T) {
+	ctx := context.Background()
+	s, sessions, _ := newRefreshService(t)
+	userID, sessionID := uuid.New(), uuid.New()
+
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":     "auth-service",
+		"sub":     userID.String(),
+		"jti":     uuid.NewString(),
+		"exp":     time.Now().Add(time.Hour).Unix(),
+		"user_id": userID.String(),
+		"type":    "refresh",
+		"fam":     sessionID.String(),
+	}).SignedString([]byte("test-refresh-secret-0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The migration replaced the stored token with its hash.
+	hash, expiresAt := s.tokens.HashRefreshToken(legacy), time.Now().Add(time.Hour)
+	sessions.sessions[sessionID] = domain.Session{
+		Id:               sessionID,
+		UserID:           userID,
+		RefreshTokenHash: &hash,
+		RefreshExpiresAt: &expiresAt,
+		ExpiresAt:        expiresAt,
+	}
+
+	_, next, err := s.Refresh(ctx, legacy, "", domain.ClientInfo{}, nil)
+	if err != nil {
+		t.Fatalf("legacy token: %v", err)
+	}
+	if strings.Contains(next, ".") {
+		t.Errorf("rotated token %q is not opaque", next)
+	}
+	if _, _, err := s.Refresh(ctx, legacy, "", domain.ClientInfo{}, nil); !errors.Is(err, domain.ErrRefreshTokenReused) {
+		t.Errorf("reused legacy token: error = %v, want %v", err, domain.ErrRefreshTokenReused)
+	}
+}
+
+// A session's refresh token expires after the idle timeout of its policy, and
+// the session itself after the maximum lifetime. remember_me picks the longer
+// policy.
+func TestSessionLifetime(t *testing.T) {
+	for _, rememberMe := range []bool{false, true} {
+		policy := testPolicies.For(rememberMe)
+		t.Run(fmt.Sprintf("remember_me=%v", rememberMe), func(t *testing.T) {
+			ctx := context.Background()
+			s, sessions, _ := newRefreshService(t)
+			start := time.Now()
+			_, refresh, err := s.StartSession(ctx, uuid.New(), domain.ClientInfo{}, nil, rememberMe, domain.Grant{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			session := onlySession(t, sessions)
+			if session.RememberMe != rememberMe {
+				t.Errorf("RememberMe = %v, want %v", session.RememberMe, rememberMe)
+			}
+			if !within(session.ExpiresAt, start.Add(policy.MaxLifetime)) {
+				t.Errorf("ExpiresAt = %s, want %s after login", session.ExpiresAt, policy.MaxLifetime)
+			}
+			if !within(*session.RefreshExpiresAt, start.Add(policy.IdleTimeout)) {
+				t.Errorf("RefreshExpiresAt = %s, want %s after login", session.RefreshExpiresAt, policy.IdleTimeout)
+			}
+
+			// Refreshing pushes the idle timeout back, but never past the end
+			// of the session.
+			end := time.Now().Add(policy.IdleTimeout / 2)
+			session.ExpiresAt = end
+			sessions.sessions[session.Id] = session
+			if _, _, err := s.Refresh(ctx, refresh, "", domain.ClientInfo{}, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := *onlySession(t, sessions).RefreshExpiresAt; !got.Equal(end) {
+				t.Errorf("RefreshExpiresAt after refresh = %s, want the end of the session, %s", got, end)
+			}
+		})
+	}
+}
+
+func TestRefreshRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(domain.Session) domain.Session
+	}{
+		{"idle timeout passed", func(s domain.Session) domain.Session {
+			past := time.Now().Add(-time.Second)
+			s.RefreshExpiresAt = &past
+			return s
+		}},
+		{"session ended", func(s domain.Session) domain.Session {
+			s.ExpiresAt = time.Now().Add(-time.Second)
+			return s
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, sessions, events := newRefreshService(t)
+			userID := uuid.New()
+			refresh := startSession(t, s, userID)
+			session := onlySession(t, sessions)
+			sessions.sessions[session.Id] = tt.setup(session)
+
+			if _, _, err := s.Refresh(ctx, refresh, "", domain.ClientInfo{}, nil); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Errorf("Refresh() error = %v, want %v", err, ErrInvalidRefreshToken)
+			}
+			if len(events.events) != 0 {
+				t.Errorf("security events = %+v, want none", events.events)
+			}
+			if list, _ := s.ListSessions(ctx, userID); len(list) != 0 {
+				t.Errorf("sessions = %+v, want none listed", list)
+			}
+		})
+	}
+}
+
+func onlySession(t *testing.T, sessions *memSessions) domain.Session {
+	t.Helper()
+	if len(sessions.sessions) != 1 {
+		t.Fatalf("%d sessions, want 1", len(sessions.sessions))
+	}
+	for _, session := range sessions.sessions {
+		return session
+	}
+	return domain.Session{}
+}
+
+// within reports whether got is at most a second after want.
+func within(got, want time.Time) bool {
+	return !got.Before(want) && got.Sub(want) < time.Second
+}
+
+// A session opened for an OIDC client is refreshed only by that client, not
+// by another client or the first-party refresh endpoint.
+func TestRefreshOtherClient(t *testing.T) {
+	ctx := context.Background()
+	s, _, events := newRefreshService(t)
+	_, refresh, err := s.StartSession(ctx, uuid.New(), domain.ClientInfo{}, nil, false, domain.Grant{ClientID: "web", Scopes: []string{"openid"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, clientID := range []string{"", "cli"} {
+		if _, _, err := s.Refresh(ctx, refresh, clientID, domain.ClientInfo{}, nil); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("Refresh() by %q error = %v, want %v", clientID, err, ErrInvalidRefreshToken)
+		}
+	}
+	// The rejected attempts did not rotate the token.
+	if _, _, err := s.Refresh(ctx, refresh, "web", domain.ClientInfo{}, nil); err != nil {
+		t.Errorf("Refresh() by the session's client: error = %v", err)
+	}
+	if len(events.events) != 0 {
+		t.Errorf("security events = %+v, want none", events.events)
+	}
+}
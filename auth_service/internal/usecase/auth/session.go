@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+func (s *ServiceAuth) ListSessions(ctx context.Context, userID uuid.UUID) ([]domain.Session, error) {
+	return s.repo.ListSessions(ctx, userID)
+}
+
+// RevokeSession logs out a single device. Both its refresh token and the access
+// tokens issued for it stop working immediately.
+func (s *ServiceAuth) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	err := s.repo.RevokeSession(ctx, userID, sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.tokens.RevokeSessionTokens(ctx, sessionID.String())
+}
+
+// LogoutAll revokes every session of the user, including the current one.
+func (s *ServiceAuth) LogoutAll(ctx context.Context, userID uuid.UUID) error {
+	ids, err := s.repo.RevokeAllSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.revokeSessionTokens(ctx, ids)
+}
+
+// revokeSessionTokens denies the access tokens of sessions already revoked in
+// the database. The denylist is written in its own statement, so a failure is
+// retried once before it is reported.
+func (s *ServiceAuth) revokeSessionTokens(ctx context.Context, ids []uuid.UUID) error {
+	for _, id := range ids {
+		err := s.tokens.RevokeSessionTokens(ctx, id.String())
+		if err != nil {
+			err = s.tokens.RevokeSessionTokens(ctx, id.String())
+		}
+		if err != nil {
+			s.log.Error(ctx, "revoke session tokens error", err.Error())
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memSessions stores users, sessions and used refresh tokens the way the
+// Postgres repository does: rotating records the hash of the presented token as
+// used, and fails with domain.ErrRefreshTokenReused if it already was.
+type memSessions struct {
+	repository.Auth
+
+	mu       sync.Mutex
+	users    map[string]domain.User
+	sessions map[uuid.UUID]domain.Session
+	used     map[string]domain.UsedRefreshToken
+}
+
+func newMemSessions() *memSessions {
+	return &memSessions{
+		users:    map[string]domain.User{},
+		sessions: map[uuid.UUID]domain.Session{},
+		used:     map[string]domain.UsedRefreshToken{},
+	}
+}
+
+func (r *memSessions) GetUserByUsername(ctx context.Context, username string) (domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[username]
+	if !ok {
+		return domain.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+// GetUserByEmail ignores case, like the LOWER(email) lookup.
+func (r *memSessions) GetUserByEmail(ctx context.Context, email string) (domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email != "" && strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+	return domain.User{}, sql.ErrNoRows
+}
+
+func (r *memSessions) GetUserByID(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Id == id {
+			return user, nil
+		}
+	}
+	return domain.User{}, sql.ErrNoRows
+}
+
+func (r *memSessions) CreateSession(ctx context.Context, session domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session.CreatedAt, session.LastUsedAt = time.Now(), time.Now()
+	r.sessions[session.Id] = session
+	return nil
+}
+
+func (r *memSessions) GetSession(ctx context.Context, id uuid.UUID) (domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return domain.Session{}, sql.ErrNoRows
+	}
+	return session, nil
+}
+
+func (r *memSessions) GetSessionByRefreshTokenHash(ctx context.Context, tokenHash string) (domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if session.RefreshTokenHash != nil && *session.RefreshTokenHash == tokenHash {
+			return session, nil
+		}
+	}
+	return domain.Session{}, sql.ErrNoRows
+}
+
+func (r *memSessions) ListSessions(ctx context.Context, userID uuid.UUID) ([]domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sessions := []domain.Session{}
+	for _, session := range r.sessions {
+		if session.UserID == userID && refreshUsable(session) {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *memSessions) RevokeSession(ctx context.Context, userID, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+	r.revoke(session)
+	return nil
+}
+
+func (r *memSessions) RevokeAllSessions(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []uuid.UUID
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			r.revoke(session)
+			ids = append(ids, session.Id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *memSessions) revoke(session domain.Session) {
+	now := time.Now()
+	session.RefreshTokenHash, session.RevokedAt = nil, &now
+	r.sessions[session.Id] = session
+}
+
+func (r *memSessions) RotateRefreshToken(ctx context.Context, used domain.UsedRefreshToken, nextHash string, nextExpiresAt time.Time, client domain.ClientInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.used[used.TokenHash]; ok {
+		return domain.ErrRefreshTokenReused
+	}
+	r.used[used.TokenHash] = used
+
+	session, ok := r.sessions[used.FamilyID]
+	if !ok || session.RevokedAt != nil || session.RefreshTokenHash == nil || *session.RefreshTokenHash != used.TokenHash {
+		return sql.ErrNoRows
+	}
+	session.RefreshTokenHash, session.RefreshExpiresAt = &nextHash, &nextExpiresAt
+	session.LastUsedAt = time.Now()
+	session.UserAgent, session.IP = client.UserAgent, client.IP
+	r.sessions[used.FamilyID] = session
+	return nil
+}
+
+func (r *memSessions) GetUsedRefreshToken(ctx context.Context, tokenHash string) (domain.UsedRefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	used, ok := r.used[tokenHash]
+	if !ok {
+		return domain.UsedRefreshToken{}, sql.ErrNoRows
+	}
+	return used, nil
+}
+
+// addUser registers a user who logs in with password.
+func (r *memSessions) addUser(t *testing.T, username, password string) domain.User {
+	t.Helper()
+	hash, err := hashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := domain.User{Id: uuid.New(), Username: username, Password: hash}
+	r.users[username] = user
+	return user
+}
+
+func TestAuthenticate(t *testing.T) {
+	users := newMemSessions()
+	john := users.addUser(t, "john_doe", "password123")
+	john.Email = "John.Doe@example.com"
+	users.users[john.Username] = john
+	// Usernames registered with an @ before emails could log in keep working.
+	legacy := users.addUser(t, "jane@home", "secret456")
+
+	tests := []struct {
+		name     string
+		login    string
+		password string
+		want     uuid.UUID
+		wantErr  error
+	}{
+		{name: "username", login: "john_doe", password: "password123", want: john.Id},
+		{name: "email", login: "John.Doe@example.com", password: "password123", want: john.Id},
+		{name: "email in another case", login: "john.doe@EXAMPLE.com", password: "password123", want: john.Id},
+		{name: "username with an @", login: "jane@home", password: "secret456", want: legacy.Id},
+		{name: "username is case-sensitive", login: "John_Doe", password: "password123", wantErr: ErrInvalidCredentials},
+		{name: "wrong password", login: "john.doe@example.com", password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "unknown email", login: "nobody@example.com", password: "password123", wantErr: ErrInvalidCredentials},
+		{name: "unknown username", login: "nobody", password: "password123", wantErr: ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, users, &recordingEvents{}, newMemPATs())
+			user, err := s.Authenticate(context.Background(), tt.login, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if user.Id != tt.want {
+				t.Errorf("user = %s, want %s", user.Id, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginOpensSessionPerDevice(t *testing.T) {
+	ctx := context.Background()
+	sessions := newMemSessions()
+	s := newTestService(t, sessions, &recordingEvents{}, newMemPATs())
+	user := sessions.addUser(t, "john_doe", "password123")
+
+	laptopAccess, laptopRefresh, err := s.Login(ctx, "john_doe", "password123", domain.ClientInfo{UserAgent: "laptop"}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phoneAccess, phoneRefresh, err := s.Login(ctx, "john_doe", "password123", domain.ClientInfo{UserAgent: "phone"}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Login(ctx, "john_doe", "wrong", domain.ClientInfo{}, nil, false); err == nil {
+		t.Error("login with a wrong password succeeded")
+	}
+
+	list, err := s.ListSessions(ctx, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("%d sessions after logging in on two devices, want 2", len(list))
+	}
+
+	// Logging out on the laptop leaves the phone logged in.
+	laptop, err := s.ParseAccessToken(ctx, laptopAccess, []string{"auth-service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Logout(ctx, laptop); err != nil {
+		t.Fatal(err)
+	}
+	list, _ = s.ListSessions(ctx, user.Id)
+	if len(list) != 1 || list[0].UserAgent != "phone" {
+		t.Errorf("sessions after logging out the laptop = %+v, want the phone's", list)
+	}
+	if _, _, err := s.Refresh(ctx, laptopRefresh, "", domain.ClientInfo{}, nil); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh of the logged out session: error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, err := s.ParseAccessToken(ctx, laptopAccess, []string{"auth-service"}); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("access token of the logged out session: error = %v, want %v", err, domain.ErrTokenRevoked)
+	}
+	if _, err := s.ParseAccessToken(ctx, phoneAccess, []string{"auth-service"}); err != nil {
+		t.Errorf("access token of the other session: %v", err)
+	}
+	if _, _, err := s.Refresh(ctx, phoneRefresh, "", domain.ClientInfo{UserAgent: "phone"}, nil); err != nil {
+		t.Errorf("refresh of the other session: %v", err)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	ctx := context.Background()
+	sessions := newMemSessions()
+	s := newTestService(t, sessions, &recordingEvents{}, newMemPATs())
+	userID, otherID := uuid.New(), uuid.New()
+	refresh := startSession(t, s, userID)
+	startSession(t, s, userID)
+
+	list, _ := s.ListSessions(ctx, userID)
+	sessionID := list[0].Id
+	access, err := s.tokens.NewAccessToken(userID.String(), sessionID.String(), nil, domain.ProfileClaims{}, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RevokeSession(ctx, otherID, sessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("revoking another user's session: error = %v, want %v", err, ErrSessionNotFound)
+	}
+	if err := s.RevokeSession(ctx, userID, sessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ParseAccessToken(ctx, access, []string{"auth-service"}); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("access token of the revoked session: error = %v, want %v", err, domain.ErrTokenRevoked)
+	}
+	if err := s.RevokeSession(ctx, userID, sessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("revoking a revoked session: error = %v, want %v", err, ErrSessionNotFound)
+	}
+	if list, _ := s.ListSessions(ctx, userID); len(list) != 1 {
+		t.Errorf("%d sessions left, want 1", len(list))
+	}
+
+	if err := s.LogoutAll(ctx, userID); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.ListSessions(ctx, userID); len(list) != 0 {
+		t.Errorf("%d sessions left after logging out everywhere, want 0", len(list))
+	}
+	if _, _, err := s.Refresh(ctx, refresh, "", domain.ClientInfo{}, nil); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after logging out everywhere: error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+// ticketTTL only has to cover the time between fetching a ticket and opening
+// the connection.
+const ticketTTL = 30 * time.Second
+
+var ErrInvalidTicket = errors.New("ticket is invalid, expired or already used")
+
+// CreateTicket issues a one-time ticket that opens channel for the user. The
+// ticket is returned once; only its hash is stored.
+func (s *ServiceAuth) CreateTicket(ctx context.Context, userID uuid.UUID, channel string) (domain.Ticket, string, error) {
+	ticket, hash, err := s.tokens.NewTicket()
+	if err != nil {
+		s.log.Error(ctx, "service auth: ticket generation error", err.Error())
+		return domain.Ticket{}, "", err
+	}
+
+	now := time.Now()
+	stored := domain.Ticket{
+		TicketHash: hash,
+		UserID:     userID,
+		Channel:    channel,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(ticketTTL),
+	}
+	if err := s.tickets.CreateTicket(ctx, stored); err != nil {
+		return domain.Ticket{}, "", err
+	}
+
+	return stored, ticket, nil
+}
+
+// RedeemTicket uses up a ticket and returns whom it was issued to. The ticket
+// must have been issued for channel. A ticket presented for the wrong channel
+// is used up all the same.
+func (s *ServiceAuth) RedeemTicket(ctx context.Context, ticket, channel string) (domain.Ticket, error) {
+	stored, err := s.tickets.ConsumeTicket(ctx, s.tokens.HashTicket(ticket))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Ticket{}, ErrInvalidTicket
+	}
+	if err != nil {
+		return domain.Ticket{}, err
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return domain.Ticket{}, ErrInvalidTicket
+	}
+	if stored.Channel != channel {
+		s.log.Warn(ctx, "ticket used for another channel",
+			"user_id", stored.UserID.String(), "channel", channel)
+		return domain.Ticket{}, ErrInvalidTicket
+	}
+
+	return stored, nil
+}
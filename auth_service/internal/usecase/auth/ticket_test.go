@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memTickets consumes tickets the way the repository does: a ticket can be
+// read once, whether it is then accepted or not.
+type memTickets struct {
+	mu      sync.Mutex
+	tickets map[string]domain.Ticket
+}
+
+func (r *memTickets) CreateTicket(ctx context.Context, ticket domain.Ticket) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tickets[ticket.TicketHash] = ticket
+	return nil
+}
+
+func (r *memTickets) ConsumeTicket(ctx context.Context, ticketHash string) (domain.Ticket, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ticket, ok := r.tickets[ticketHash]
+	if !ok {
+		return domain.Ticket{}, sql.ErrNoRows
+	}
+	delete(r.tickets, ticketHash)
+	return ticket, nil
+}
+
+func TestRedeemTicket(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel string
+		expired bool
+		wantErr error
+	}{
+		{name: "valid", channel: "notifications"},
+		{name: "other channel", channel: "chat", wantErr: ErrInvalidTicket},
+		{name: "expired", channel: "notifications", expired: true, wantErr: ErrInvalidTicket},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tokens := newTestTokens(t)
+			tickets := &memTickets{tickets: map[string]domain.Ticket{}}
+			s := &ServiceAuth{tickets: tickets, tokens: tokens, log: testLog}
+			userID := uuid.New()
+
+			stored, ticket, err := s.CreateTicket(ctx, userID, "notifications")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := tickets.tickets[ticket]; ok {
+				t.Error("ticket stored in the clear")
+			}
+			if tt.expired {
+				stored.ExpiresAt = time.Now().Add(-time.Second)
+				tickets.tickets[stored.TicketHash] = stored
+			}
+
+			redeemed, err := s.RedeemTicket(ctx, ticket, tt.channel)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RedeemTicket() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && redeemed.UserID != userID {
+				t.Errorf("UserID = %s, want %s", redeemed.UserID, userID)
+			}
+
+			// Tickets are single use, even when rejected.
+			if _, err := s.RedeemTicket(ctx, ticket, "notifications"); !errors.Is(err, ErrInvalidTicket) {
+				t.Errorf("second RedeemTicket() error = %v, want %v", err, ErrInvalidTicket)
+			}
+		})
+	}
+}
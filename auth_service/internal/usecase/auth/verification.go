@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/url"
+	"time"
+)
+
+// verificationResendInterval is how often a user may ask for another
+// verification email.
+const verificationResendInterval = time.Minute
+
+var (
+	ErrInvalidVerificationToken = errors.New("verification link is invalid or expired")
+	ErrEmailAlreadyVerified     = errors.New("email is already verified")
+	ErrVerificationThrottled    = errors.New("verification email was sent recently")
+)
+
+type Mailer interface {
+	Send(ctx context.Context, email domain.Email) error
+}
+
+// Links are the pages of the frontend that emails link to.
+// The token is appended to them as the token query parameter.
+type Links struct {
+	VerifyEmail   string
+	ResetPassword string
+}
+
+// VerifyEmail marks the address a verification token was issued for as
+// verified. A token for an address the user has since replaced is invalid.
+func (s *ServiceAuth) VerifyEmail(ctx context.Context, token string) (uuid.UUID, error) {
+	userID, email, err := s.tokens.ParseEmailVerificationToken(ctx, token)
+	if err != nil {
+		s.log.Error(ctx, "parse email verification token error", err.Error())
+		return uuid.Nil, ErrInvalidVerificationToken
+	}
+
+	err = s.repo.MarkEmailVerified(ctx, userID, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	s.log.Info(ctx, "email verified", "user_id", userID.String())
+	return userID, nil
+}
+
+// ResendVerificationEmail sends the user a new verification link, at most once
+// per verificationResendInterval.
+func (s *ServiceAuth) ResendVerificationEmail(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+	return s.sendVerificationEmail(ctx, user)
+}
+
+func (s *ServiceAuth) sendVerificationEmail(ctx context.Context, user domain.User) error {
+	err := s.repo.MarkVerificationEmailSent(ctx, user.Id, verificationResendInterval)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrVerificationThrottled
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := s.tokens.NewEmailVerificationToken(user.Id, user.Email)
+	if err != nil {
+		s.log.Error(ctx, "service auth: email verification token generation error", err.Error())
+		return err
+	}
+
+	link, err := withToken(s.links.VerifyEmail, token)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, domain.Email{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nconfirm your email address by opening the link below. It expires in 24 hours.\n\n%s\n\nIf you did not create an account, ignore this email.\n",
+			user.DisplayName(), link),
+	})
+}
+
+// withToken adds token to the query of link.
+func withToken(link, token string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("email link: %w", err)
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memVerifications adds the email verification columns to memSessions.
+type memVerifications struct {
+	*memSessions
+
+	sentAt map[uuid.UUID]time.Time
+}
+
+func (r *memVerifications) MarkEmailVerified(ctx context.Context, userID uuid.UUID, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for username, user := range r.users {
+		if user.Id == userID && strings.EqualFold(user.Email, email) {
+			user.EmailVerified = true
+			r.users[username] = user
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *memVerifications) MarkVerificationEmailSent(ctx context.Context, userID uuid.UUID, interval time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Id != userID {
+			continue
+		}
+		if user.EmailVerified || time.Since(r.sentAt[userID]) < interval {
+			return sql.ErrNoRows
+		}
+		r.sentAt[userID] = time.Now()
+		return nil
+	}
+	return sql.ErrNoRows
+}
+
+func newVerificationService(t *testing.T) (*ServiceAuth, *memVerifications, *recordingMailer, domain.User) {
+	t.Helper()
+	users := &memVerifications{memSessions: newMemSessions(), sentAt: map[uuid.UUID]time.Time{}}
+	user := users.addUser(t, "john_doe", "password123")
+	user.Email = "john.doe@example.com"
+	users.users[user.Username] = user
+
+	mailer := &recordingMailer{}
+	s := newTestService(t, users.memSessions, &recordingEvents{}, newMemPATs())
+	s.repo, s.mailer = users, mailer
+	return s, users, mailer, user
+}
+
+// mailedToken returns the token of the link to page in the last email sent.
+func mailedToken(t *testing.T, mailer *recordingMailer, page string) string {
+	t.Helper()
+	if len(mailer.emails) == 0 {
+		t.Fatal("no email sent")
+	}
+	body := mailer.emails[len(mailer.emails)-1].Body
+	start := strings.Index(body, page)
+	if start < 0 {
+		t.Fatalf("no link to %s in %q", page, body)
+	}
+	link, err := url.Parse(strings.Fields(body[start:])[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return link.Query().Get("token")
+}
+
+func TestVerifyEmail(t *testing.T) {
+	ctx := context.Background()
+	s, users, mailer, user := newVerificationService(t)
+
+	if err := s.ResendVerificationEmail(ctx, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.emails) != 1 || mailer.emails[0].To != user.Email {
+		t.Fatalf("emails = %+v, want one to %s", mailer.emails, user.Email)
+	}
+	token := mailedToken(t, mailer, testLinks.VerifyEmail)
+
+	if _, err := s.VerifyEmail(ctx, "not-a-token"); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("VerifyEmail(garbage) error = %v, want %v", err, ErrInvalidVerificationToken)
+	}
+
+	got, err := s.VerifyEmail(ctx, token)
+	if err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+	if got != user.Id {
+		t.Errorf("VerifyEmail() = %s, want %s", got, user.Id)
+	}
+	if !users.users[user.Username].EmailVerified {
+		t.Error("email not marked verified")
+	}
+	if err := s.ResendVerificationEmail(ctx, user.Id); !errors.Is(err, ErrEmailAlreadyVerified) {
+		t.Errorf("resend after verification: error = %v, want %v", err, ErrEmailAlreadyVerified)
+	}
+}
+
+// A link sent to an address the user has since replaced verifies nothing.
+func TestVerifyEmailChangedAddress(t *testing.T) {
+	ctx := context.Background()
+	s, users, mailer, user := newVerificationService(t)
+
+	if err := s.ResendVerificationEmail(ctx, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	user.Email = "john@example.org"
+	users.users[user.Username] = user
+
+	if _, err := s.VerifyEmail(ctx, mailedToken(t, mailer, testLinks.VerifyEmail)); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("VerifyEmail() error = %v, want %v", err, ErrInvalidVerificationToken)
+	}
+	if users.users[user.Username].EmailVerified {
+		t.Error("new address marked verified")
+	}
+}
+
+func TestResendVerificationEmailThrottled(t *testing.T) {
+	ctx := context.Background()
+	s, users, mailer, user := newVerificationService(t)
+
+	if err := s.ResendVerificationEmail(ctx, user.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResendVerificationEmail(ctx, user.Id); !errors.Is(err, ErrVerificationThrottled) {
+		t.Errorf("second resend: error = %v, want %v", err, ErrVerificationThrottled)
+	}
+	if len(mailer.emails) != 1 {
+		t.Errorf("%d emails sent, want 1", len(mailer.emails))
+	}
+
+	users.sentAt[user.Id] = time.Now().Add(-verificationResendInterval)
+	if err := s.ResendVerificationEmail(ctx, user.Id); err != nil {
+		t.Errorf("resend after the interval: error = %v", err)
+	}
+}
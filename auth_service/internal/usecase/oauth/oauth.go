@@ -0,0 +1,253 @@
+package oauth
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/repository"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Token exchange identifiers (RFC 8693).
+const (
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	TokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+type TokenManager interface {
+	NewClientToken(clientID string, scopes, audience []string) (string, time.Time, error)
+	NewDelegatedToken(subject domain.AccessTokenClaims, actorClientID string, scopes, audience []string, profile domain.ProfileClaims) (string, time.Time, error)
+	ParseAccessToken(ctx context.Context, token string, audiences []string) (domain.AccessTokenClaims, error)
+}
+
+// Claims builds the profile claims of user tokens for an audience.
+type Claims interface {
+	Build(ctx context.Context, userID uuid.UUID, audience []string) (domain.ProfileClaims, error)
+}
+
+var (
+	ErrInvalidClient = errors.New("invalid client credentials")
+	ErrInvalidScope  = errors.New("invalid scope")
+
+	ErrUnauthorizedClient = errors.New("client is not allowed to use this grant")
+
+	ErrUnsupportedTokenType = errors.New("unsupported subject_token_type")
+	ErrInvalidSubjectToken  = errors.New("invalid subject_token")
+)
+
+// dummySecretHash is compared against when the client does not exist, so that
+// unknown and known client ids take the same time to reject.
+var dummySecretHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-client-secret"), bcrypt.DefaultCost)
+
+type ServiceOAuth struct {
+	clients repository.Clients
+	log     *logger.SlogLogger
+	tokens  TokenManager
+	claims  Claims
+}
+
+func NewServiceOAuth(clients repository.Clients, log *logger.SlogLogger, tokens TokenManager, claims Claims) *ServiceOAuth {
+	return &ServiceOAuth{
+		clients: clients,
+		log:     log,
+		tokens:  tokens,
+		claims:  claims,
+	}
+}
+
+// CreateClient registers a client and returns its secret. The secret is only
+// available here; the database keeps a bcrypt hash. Public clients get no
+// secret.
+func (s *ServiceOAuth) CreateClient(ctx context.Context, client domain.Client) (string, error) {
+	if client.Public {
+		return "", s.clients.CreateClient(ctx, client)
+	}
+
+	secret, err := randomSecret()
+	if err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	client.SecretHash = string(hash)
+	if err := s.clients.CreateClient(ctx, client); err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+func (s *ServiceOAuth) AuthenticateClient(ctx context.Context, clientID, secret string) (domain.Client, error) {
+	client, err := s.clients.GetClientByClientID(ctx, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = bcrypt.CompareHashAndPassword(dummySecretHash, []byte(secret))
+		return domain.Client{}, ErrInvalidClient
+	}
+	if err != nil {
+		return domain.Client{}, err
+	}
+
+	if client.Public {
+		return domain.Client{}, ErrInvalidClient
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(client.SecretHash), []byte(secret)); err != nil {
+		s.log.Warn(ctx, "oauth: client authentication failed", "client_id", clientID)
+		return domain.Client{}, ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// AuthenticatePublicClient identifies a client that has no secret. Only
+// clients registered as public are accepted.
+func (s *ServiceOAuth) AuthenticatePublicClient(ctx context.Context, clientID string) (domain.Client, error) {
+	client, err := s.clients.GetClientByClientID(ctx, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Client{}, ErrInvalidClient
+	}
+	if err != nil {
+		return domain.Client{}, err
+	}
+	if !client.Public {
+		return domain.Client{}, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// ClientCredentials issues a client token (RFC 6749 section 4.4). Requesting
+// no scope grants every scope the client is registered with; requesting a
+// scope it is not registered with fails with ErrInvalidScope.
+func (s *ServiceOAuth) ClientCredentials(ctx context.Context, client domain.Client, scopes, audience []string) (domain.IssuedToken, error) {
+	if client.Public {
+		return domain.IssuedToken{}, ErrUnauthorizedClient
+	}
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return domain.IssuedToken{}, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+	}
+
+	token, expiresAt, err := s.tokens.NewClientToken(client.ClientID, scopes, audience)
+	if err != nil {
+		return domain.IssuedToken{}, err
+	}
+
+	s.log.Info(ctx, "oauth: client token issued", "client_id", client.ClientID)
+	return domain.IssuedToken{
+		AccessToken: token,
+		Scopes:      scopes,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// ExchangeToken swaps a user access token for a short-lived token the client
+// uses to call another service on the user's behalf (RFC 8693). The subject
+// token must have been issued to the client, i.e. name its client_id as
+// audience. The new token carries the client as actor, and its scopes are
+// limited to the client's scopes and, if it has any, the subject token's. Its
+// profile claims are the ones the new audience gets, not the subject token's.
+func (s *ServiceOAuth) ExchangeToken(ctx context.Context, client domain.Client, subjectToken, subjectTokenType string, scopes, audience []string) (domain.IssuedToken, error) {
+	if client.Public {
+		return domain.IssuedToken{}, ErrUnauthorizedClient
+	}
+	if subjectTokenType != TokenTypeAccessToken {
+		return domain.IssuedToken{}, ErrUnsupportedTokenType
+	}
+
+	subject, err := s.tokens.ParseAccessToken(ctx, subjectToken, []string{client.ClientID})
+	if err != nil {
+		s.log.Debug(ctx, "oauth: token exchange subject rejected", "error", err.Error())
+		return domain.IssuedToken{}, ErrInvalidSubjectToken
+	}
+	if subject.SubjectType != domain.SubjectTypeUser {
+		return domain.IssuedToken{}, ErrInvalidSubjectToken
+	}
+
+	allowed := client.Scopes
+	if len(subject.Scopes) > 0 {
+		allowed = slices.DeleteFunc(slices.Clone(allowed), func(scope string) bool {
+			return !slices.Contains(subject.Scopes, scope)
+		})
+	}
+	if len(scopes) == 0 {
+		scopes = allowed
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			return domain.IssuedToken{}, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+	}
+
+	userID, err := uuid.Parse(subject.UserID)
+	if err != nil {
+		return domain.IssuedToken{}, ErrInvalidSubjectToken
+	}
+	profile, err := s.claims.Build(ctx, userID, audience)
+	if err != nil {
+		return domain.IssuedToken{}, err
+	}
+
+	token, expiresAt, err := s.tokens.NewDelegatedToken(subject, client.ClientID, scopes, audience, profile)
+	if err != nil {
+		return domain.IssuedToken{}, err
+	}
+
+	s.log.Info(ctx, "oauth: token exchanged", "client_id", client.ClientID, "user_id", subject.UserID)
+	return domain.IssuedToken{
+		AccessToken: token,
+		Scopes:      scopes,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// Introspect reports whether token is a live access token (RFC 7662). Any
+// failure to verify it results in an inactive response; revoked tokens are
+// flagged as such. A client only learns about tokens addressed to it: the
+// token's audience must include the client_id.
+func (s *ServiceOAuth) Introspect(ctx context.Context, client domain.Client, token string) domain.Introspection {
+	claims, err := s.tokens.ParseAccessToken(ctx, token, []string{client.ClientID})
+	if errors.Is(err, domain.ErrTokenRevoked) {
+		return domain.Introspection{Revoked: true}
+	}
+	if err != nil {
+		s.log.Debug(ctx, "oauth: introspected token is not active", "error", err.Error())
+		return domain.Introspection{}
+	}
+
+	return domain.Introspection{
+		Active:    true,
+		TokenType: "Bearer",
+		Subject:   claims.Subject,
+		ClientID:  claims.ClientID,
+		Actor:     claims.Actor,
+		Scope:     strings.Join(claims.Scopes, " "),
+		Issuer:    claims.Issuer,
+		TokenID:   claims.TokenID,
+		Audience:  claims.Audience,
+		IssuedAt:  claims.IssuedAt,
+		ExpiresAt: claims.ExpiresAt,
+	}
+}
+
+func randomSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
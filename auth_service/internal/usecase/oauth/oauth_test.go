@@ -0,0 +1,450 @@
+package oauth
+
+import (
+	"auth_service/internal/domain"
+	jwtauth "auth_service/internal/infrastructure/auth"
+	"auth_service/internal/infrastructure/logger"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testLog = logger.New("test")
+
+func TestAuthenticateClient(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newTestService(t)
+	secret, err := s.CreateClient(ctx, domain.Client{ClientID: "gateway", Name: "API gateway"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateClient(ctx, domain.Client{ClientID: "web", Name: "Frontend", Public: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		clientID string
+		secret   string
+		wantErr  error
+	}{
+		{"valid", "gateway", secret, nil},
+		{"wrong secret", "gateway", secret + "x", ErrInvalidClient},
+		{"unknown client", "other", secret, ErrInvalidClient},
+		{"public client", "web", "", ErrInvalidClient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := s.AuthenticateClient(ctx, tt.clientID, tt.secret)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AuthenticateClient() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && client.ClientID != tt.clientID {
+				t.Errorf("ClientID = %q, want %q", client.ClientID, tt.clientID)
+			}
+		})
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	client := domain.Client{ClientID: "gateway", Scopes: []string{"content:read", "content:write"}}
+
+	tests := []struct {
+		name      string
+		scopes    []string
+		audience  []string
+		wantScope []string
+		wantErr   error
+	}{
+		{name: "all scopes of the client", wantScope: []string{"content:read", "content:write"}},
+		{name: "requested scope", scopes: []string{"content:read"}, wantScope: []string{"content:read"}},
+		{name: "scope the client lacks", scopes: []string{"users:admin"}, wantErr: ErrInvalidScope},
+		{name: "audience not allowed", audience: []string{"billing"}, wantErr: domain.ErrInvalidAudience},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, tokens, _ := newTestService(t)
+			issued, err := s.ClientCredentials(ctx, client, tt.scopes, tt.audience)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ClientCredentials() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if !slices.Equal(issued.Scopes, tt.wantScope) {
+				t.Errorf("Scopes = %v, want %v", issued.Scopes, tt.wantScope)
+			}
+
+			claims, err := tokens.ParseAccessToken(ctx, issued.AccessToken, []string{"auth-service"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.SubjectType != domain.SubjectTypeClient || claims.Subject != "gateway" || claims.ClientID != "gateway" || claims.UserID != "" {
+				t.Errorf("claims = %+v, want a client token of gateway", claims)
+			}
+			if !slices.Equal(claims.Scopes, tt.wantScope) {
+				t.Errorf("scope claim = %v, want %v", claims.Scopes, tt.wantScope)
+			}
+		})
+	}
+}
+
+var (
+	gateway = domain.Client{ClientID: "gateway", Scopes: []string{"content:read", "content:write"}}
+	worker  = domain.Client{ClientID: "worker", Scopes: []string{"content:read"}}
+)
+
+func TestExchangeToken(t *testing.T) {
+	userID, sessionID := uuid.NewString(), uuid.NewString()
+
+	tests := []struct {
+		name      string
+		client    domain.Client
+		tokenType string
+		audience  []string // of the subject token
+		grant     domain.Grant
+		scopes    []string
+		wantScope []string
+		wantErr   error
+	}{
+		{
+			name:      "all scopes of the client",
+			client:    gateway,
+			audience:  []string{"gateway"},
+			wantScope: []string{"content:read", "content:write"},
+		},
+		{
+			name:      "requested scope",
+			client:    gateway,
+			audience:  []string{"gateway"},
+			scopes:    []string{"content:read"},
+			wantScope: []string{"content:read"},
+		},
+		{
+			name:      "limited by the subject token's scopes",
+			client:    gateway,
+			audience:  []string{"gateway"},
+			grant:     domain.Grant{ClientID: "web", Scopes: []string{"content:read"}},
+			wantScope: []string{"content:read"},
+		},
+		{
+			name:     "scope the subject token lacks",
+			client:   gateway,
+			audience: []string{"gateway"},
+			grant:    domain.Grant{ClientID: "web", Scopes: []string{"content:read"}},
+			scopes:   []string{"content:write"},
+			wantErr:  ErrInvalidScope,
+		},
+		{
+			name:     "scope the client lacks",
+			client:   worker,
+			audience: []string{"worker"},
+			scopes:   []string{"content:write"},
+			wantErr:  ErrInvalidScope,
+		},
+		{
+			name:     "subject token issued to another client",
+			client:   gateway,
+			audience: []string{"content-service"},
+			wantErr:  ErrInvalidSubjectToken,
+		},
+		{
+			name:     "public client",
+			client:   domain.Client{ClientID: "gateway", Public: true},
+			audience: []string{"gateway"},
+			wantErr:  ErrUnauthorizedClient,
+		},
+		{
+			name:      "unsupported token type",
+			client:    gateway,
+			tokenType: "urn:ietf:params:oauth:token-type:refresh_token",
+			audience:  []string{"gateway"},
+			wantErr:   ErrUnsupportedTokenType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, tokens, claims := newTestService(t)
+			subject, err := tokens.NewAccessToken(userID, sessionID, tt.audience, domain.ProfileClaims{}, tt.grant)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokenType := tt.tokenType
+			if tokenType == "" {
+				tokenType = TokenTypeAccessToken
+			}
+
+			issued, err := s.ExchangeToken(ctx, tt.client, subject, tokenType, tt.scopes, []string{"content-service"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExchangeToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !slices.Equal(issued.Scopes, tt.wantScope) {
+				t.Errorf("Scopes = %v, want %v", issued.Scopes, tt.wantScope)
+			}
+			if !slices.Equal(claims.audience, []string{"content-service"}) {
+				t.Errorf("profile claims built for %v, want the new audience", claims.audience)
+			}
+
+			delegated, err := tokens.ParseAccessToken(ctx, issued.AccessToken, []string{"content-service"})
+			if err != nil {
+				t.Fatalf("delegated token: %v", err)
+			}
+			if delegated.UserID != userID || delegated.SessionID != sessionID {
+				t.Errorf("delegated token for user %q session %q, want %q %q", delegated.UserID, delegated.SessionID, userID, sessionID)
+			}
+			if delegated.Actor == nil || delegated.Actor.Subject != tt.client.ClientID || delegated.Actor.Actor != nil {
+				t.Errorf("act = %+v, want %q", delegated.Actor, tt.client.ClientID)
+			}
+			if !slices.Equal(delegated.Scopes, tt.wantScope) {
+				t.Errorf("scope claim = %v, want %v", delegated.Scopes, tt.wantScope)
+			}
+		})
+	}
+}
+
+// Client tokens have no user to act on behalf of.
+func TestExchangeClientToken(t *testing.T) {
+	s, tokens, _ := newTestService(t)
+	subject, _, err := tokens.NewClientToken("web", nil, []string{"gateway"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ExchangeToken(context.Background(), gateway, subject, TokenTypeAccessToken, nil, []string{"content-service"})
+	if !errors.Is(err, ErrInvalidSubjectToken) {
+		t.Errorf("ExchangeToken() error = %v, want %v", err, ErrInvalidSubjectToken)
+	}
+}
+
+// A delegated token exchanged again nests the previous actor, keeps to the
+// scopes of its subject token, never outlives it, and is revoked with the
+// user's session.
+func TestExchangeDelegatedToken(t *testing.T) {
+	ctx := context.Background()
+	s, tokens, _ := newTestService(t)
+	sessionID := uuid.NewString()
+
+	subject, err := tokens.NewAccessToken(uuid.NewString(), sessionID, []string{"gateway"}, domain.ProfileClaims{}, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := s.ExchangeToken(ctx, gateway, subject, TokenTypeAccessToken, []string{"content:read"}, []string{"worker"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	twoScopes := domain.Client{ClientID: "worker", Scopes: []string{"content:read", "content:write"}}
+	if _, err := s.ExchangeToken(ctx, twoScopes, first.AccessToken, TokenTypeAccessToken, []string{"content:write"}, nil); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("scope the subject token lacks: error = %v, want %v", err, ErrInvalidScope)
+	}
+	time.Sleep(time.Second)
+	second, err := s.ExchangeToken(ctx, twoScopes, first.AccessToken, TokenTypeAccessToken, nil, []string{"content-service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(second.Scopes, []string{"content:read"}) {
+		t.Errorf("Scopes = %v, want those of the subject token", second.Scopes)
+	}
+	if !second.ExpiresAt.Equal(first.ExpiresAt) {
+		t.Errorf("ExpiresAt = %s, want that of the subject token, %s", second.ExpiresAt, first.ExpiresAt)
+	}
+	claims, err := tokens.ParseAccessToken(ctx, second.AccessToken, []string{"content-service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Actor == nil || claims.Actor.Subject != "worker" || claims.Actor.Actor == nil || claims.Actor.Actor.Subject != "gateway" {
+		t.Errorf("act = %+v, want worker acting for gateway", claims.Actor)
+	}
+
+	if err := tokens.RevokeSessionTokens(ctx, sessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokens.ParseAccessToken(ctx, second.AccessToken, []string{"content-service"}); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("delegated token of a revoked session: error = %v, want %v", err, domain.ErrTokenRevoked)
+	}
+	if _, err := s.ExchangeToken(ctx, gateway, subject, TokenTypeAccessToken, nil, nil); !errors.Is(err, ErrInvalidSubjectToken) {
+		t.Errorf("exchange of a revoked token: error = %v, want %v", err, ErrInvalidSubjectToken)
+	}
+}
+
+func TestIntrospect(t *testing.T) {
+	ctx := context.Background()
+	s, tokens, _ := newTestService(t)
+	userID := uuid.NewString()
+
+	gateway := []string{"gateway"}
+	active, err := tokens.NewAccessToken(userID, uuid.NewString(), gateway, domain.ProfileClaims{}, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherAudience, err := tokens.NewAccessToken(userID, uuid.NewString(), []string{"content-service"}, domain.ProfileClaims{}, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := tokens.NewAccessToken(userID, uuid.NewString(), gateway, domain.ProfileClaims{}, domain.Grant{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := tokens.ParseAccessToken(ctx, revoked, gateway)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tokens.RevokeAccessToken(ctx, claims.TokenID, claims.ExpiresAt); err != nil {
+		t.Fatal(err)
+	}
+	refresh, err := tokens.NewRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		wantActive  bool
+		wantRevoked bool
+	}{
+		{name: "active", token: active, wantActive: true},
+		{name: "addressed to another audience", token: otherAudience},
+		{name: "revoked", token: revoked, wantRevoked: true},
+		{name: "refresh token", token: refresh.Token},
+		{name: "malformed", token: "not-a-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.Introspect(ctx, domain.Client{ClientID: "gateway"}, tt.token)
+			if got.Active != tt.wantActive || got.Revoked != tt.wantRevoked {
+				t.Fatalf("Introspect() = active %v revoked %v, want %v %v", got.Active, got.Revoked, tt.wantActive, tt.wantRevoked)
+			}
+			if !tt.wantActive {
+				if got.Subject != "" || got.TokenID != "" {
+					t.Errorf("inactive token leaks claims: %+v", got)
+				}
+				return
+			}
+			if got.Subject != userID || got.TokenID == "" || got.TokenType != "Bearer" || !slices.Equal(got.Audience, gateway) {
+				t.Errorf("Introspect() = %+v, want the claims of the token", got)
+			}
+		})
+	}
+}
+
+// recordingClaims records the audience profile claims were built for.
+type recordingClaims struct {
+	audience []string
+}
+
+func (c *recordingClaims) Build(ctx context.Context, userID uuid.UUID, audience []string) (domain.ProfileClaims, error) {
+	c.audience = audience
+	return domain.ProfileClaims{}, nil
+}
+
+func newTestService(t *testing.T) (*ServiceOAuth, *jwtauth.TokenManager, *recordingClaims) {
+	t.Helper()
+	accessKeys, err := jwtauth.NewKeyring(mustHMACKey(t, "test-access-secret-0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshKeys, err := jwtauth.NewKeyring(mustHMACKey(t, "test-refresh-secret-0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := jwtauth.NewTokenManager(accessKeys, refreshKeys,
+		jwtauth.NewDenylist(&memDenylistStore{}, testLog),
+		jwtauth.NewEpochs(memEpochStore{}, testLog),
+		jwtauth.TokenPolicy{
+			Issuer:         "auth-service",
+			AccessTTL:      5 * time.Minute,
+			DelegatedTTL:   5 * time.Minute,
+			RefreshTTL:     time.Hour,
+			RequiredClaims: []string{"exp", "iat", "iss", "jti"},
+			Audiences: jwtauth.AudiencePolicy{
+				Allowed:  []string{"auth-service", "content-service", "gateway", "worker"},
+				Default:  []string{"auth-service"},
+				Accepted: []string{"auth-service"},
+			},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := &recordingClaims{}
+	return NewServiceOAuth(&memClients{clients: map[string]domain.Client{}}, testLog, tokens, claims), tokens, claims
+}
+
+type memClients struct {
+	mu      sync.Mutex
+	clients map[string]domain.Client
+}
+
+func (r *memClients) CreateClient(ctx context.Context, client domain.Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients[client.ClientID] = client
+	return nil
+}
+
+func (r *memClients) GetClientByClientID(ctx context.Context, clientID string) (domain.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client, ok := r.clients[clientID]
+	if !ok {
+		return domain.Client{}, sql.ErrNoRows
+	}
+	return client, nil
+}
+
+// memDenylistStore is the revoked_tokens table.
+func mustHMACKey(t *testing.T, secret string) *jwtauth.SigningKey {
+	t.Helper()
+	key, err := jwtauth.NewHMACKey(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+type memDenylistStore struct {
+	mu      sync.Mutex
+	revoked []domain.RevokedToken
+}
+
+func (s *memDenylistStore) RevokeToken(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked = append(s.revoked, domain.RevokedToken{ID: id, ExpiresAt: expiresAt})
+	return nil
+}
+
+func (s *memDenylistStore) ListRevokedTokens(ctx context.Context) ([]domain.RevokedToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]domain.RevokedToken(nil), s.revoked...), nil
+}
+
+func (s *memDenylistStore) DeleteExpiredRevocations(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// memEpochStore never increments an epoch.
+type memEpochStore struct{}
+
+func (memEpochStore) GetTokenEpochs(ctx context.Context) (domain.TokenEpochs, error) {
+	return domain.TokenEpochs{}, nil
+}
+
+func (memEpochStore) IncrementGlobalTokenEpoch(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (memEpochStore) IncrementUserTokenEpoch(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return 0, nil
+}
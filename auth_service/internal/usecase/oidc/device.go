@@ -0,0 +1,228 @@
+package oidc
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"math/big"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	GrantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+	deviceCodeTTL      = 10 * time.Minute
+	devicePollInterval = 5 * time.Second
+	// deviceSlowDownStep is added to a device's poll interval every time it
+	// polls too fast (RFC 8628 section 3.5).
+	deviceSlowDownStep = 5 * time.Second
+)
+
+// userCodeAlphabet has no vowels, so user codes cannot spell words, and no
+// characters that are easily confused (RFC 8628 section 6.1).
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+const userCodeLength = 8
+
+// Errors of the device code grant, reported with the matching RFC 8628 error
+// codes while the device polls.
+var (
+	ErrAuthorizationPending = errors.New("the user has not approved the request yet")
+	ErrSlowDown             = errors.New("polling too fast")
+	ErrAccessDenied         = errors.New("the user denied the request")
+	ErrExpiredToken         = errors.New("the device code has expired")
+	ErrInvalidUserCode      = errors.New("invalid or expired user code")
+)
+
+// DeviceAuthorization starts the device flow for a client without a browser
+// (RFC 8628 section 3.1). The device shows the user code and polls the token
+// endpoint with the device code.
+func (s *ServiceOIDC) DeviceAuthorization(ctx context.Context, client domain.Client, scopes []string) (domain.DeviceCode, error) {
+	for _, scope := range scopes {
+		if !slices.Contains(supportedScopes, scope) {
+			return domain.DeviceCode{}, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+	}
+
+	deviceCode, err := randomCode()
+	if err != nil {
+		return domain.DeviceCode{}, err
+	}
+	userCode, err := randomUserCode()
+	if err != nil {
+		return domain.DeviceCode{}, err
+	}
+
+	expiresAt := time.Now().Add(deviceCodeTTL)
+	err = s.devices.CreateDeviceAuthorization(ctx, domain.DeviceAuthorization{
+		DeviceCodeHash: hashCode(deviceCode),
+		UserCode:       userCode,
+		ClientID:       client.ClientID,
+		Scopes:         scopes,
+		Status:         domain.DeviceAuthorizationPending,
+		PollInterval:   int(devicePollInterval.Seconds()),
+		ExpiresAt:      expiresAt,
+	})
+	if err != nil {
+		return domain.DeviceCode{}, err
+	}
+
+	display := userCode[:4] + "-" + userCode[4:]
+	return domain.DeviceCode{
+		DeviceCode:              deviceCode,
+		UserCode:                display,
+		VerificationURI:         s.deviceVerificationURI,
+		VerificationURIComplete: s.deviceVerificationURI + "?" + url.Values{"user_code": {display}}.Encode(),
+		ExpiresAt:               expiresAt,
+		Interval:                devicePollInterval,
+	}, nil
+}
+
+// LookupDevice returns a pending device authorization and its client, so the
+// user can check what they are approving.
+func (s *ServiceOIDC) LookupDevice(ctx context.Context, userCode string) (domain.DeviceAuthorization, domain.Client, error) {
+	auth, err := s.devices.GetDeviceAuthorizationByUserCode(ctx, normalizeUserCode(userCode))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.DeviceAuthorization{}, domain.Client{}, ErrInvalidUserCode
+	}
+	if err != nil {
+		return domain.DeviceAuthorization{}, domain.Client{}, err
+	}
+	if auth.Status != domain.DeviceAuthorizationPending || !time.Now().Before(auth.ExpiresAt) {
+		return domain.DeviceAuthorization{}, domain.Client{}, ErrInvalidUserCode
+	}
+
+	client, err := s.clients.GetClientByClientID(ctx, auth.ClientID)
+	if err != nil {
+		return domain.DeviceAuthorization{}, domain.Client{}, err
+	}
+	return auth, client, nil
+}
+
+// DecideDevice records the logged-in user's answer to a device authorization.
+func (s *ServiceOIDC) DecideDevice(ctx context.Context, userID uuid.UUID, userCode string, approve bool) error {
+	status := domain.DeviceAuthorizationDenied
+	if approve {
+		status = domain.DeviceAuthorizationApproved
+	}
+
+	err := s.devices.DecideDeviceAuthorization(ctx, normalizeUserCode(userCode), userID, status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidUserCode
+	}
+	if err != nil {
+		return err
+	}
+
+	s.log.Info(ctx, "oidc: device authorization decided", "user_id", userID.String(), "status", status)
+	return nil
+}
+
+// ExchangeDeviceCode answers a poll of the token endpoint (RFC 8628 section
+// 3.4). Once the user has approved, a new session is opened for them and its
+// tokens are returned exactly once.
+func (s *ServiceOIDC) ExchangeDeviceCode(ctx context.Context, client domain.Client, deviceCode string, info domain.ClientInfo) (domain.TokenSet, error) {
+	codeHash := hashCode(deviceCode)
+
+	auth, err := s.devices.PollDeviceAuthorization(ctx, codeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.TokenSet{}, ErrInvalidGrant
+	}
+	if err != nil {
+		return domain.TokenSet{}, err
+	}
+	if auth.ClientID != client.ClientID {
+		s.log.Warn(ctx, "oidc: device code presented by another client", "client_id", client.ClientID)
+		return domain.TokenSet{}, ErrInvalidGrant
+	}
+	if !time.Now().Before(auth.ExpiresAt) {
+		return domain.TokenSet{}, ErrExpiredToken
+	}
+
+	switch auth.Status {
+	case domain.DeviceAuthorizationPending:
+		interval := time.Duration(auth.PollInterval) * time.Second
+		if auth.LastPolledAt != nil && time.Since(*auth.LastPolledAt) < interval {
+			return domain.TokenSet{}, s.slowDown(ctx, codeHash)
+		}
+		return domain.TokenSet{}, ErrAuthorizationPending
+	case domain.DeviceAuthorizationDenied:
+		_ = s.devices.DeleteDeviceAuthorization(ctx, codeHash)
+		return domain.TokenSet{}, ErrAccessDenied
+	}
+
+	// Only the poll that deletes the row gets the tokens.
+	err = s.devices.DeleteDeviceAuthorization(ctx, codeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.TokenSet{}, ErrInvalidGrant
+	}
+	if err != nil {
+		return domain.TokenSet{}, err
+	}
+
+	// Devices without a browser cannot log in again easily, so they get the
+	// long session policy.
+	grant := domain.Grant{ClientID: client.ClientID, Scopes: auth.Scopes}
+	expiresAt := s.accessExpiry()
+	access, refresh, err := s.sessions.StartSession(ctx, *auth.UserID, info, nil, true, grant)
+	if err != nil {
+		return domain.TokenSet{}, err
+	}
+
+	tokens := domain.TokenSet{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		Scopes:       auth.Scopes,
+		ExpiresAt:    expiresAt,
+	}
+	if !slices.Contains(auth.Scopes, ScopeOpenID) {
+		return tokens, nil
+	}
+
+	tokens.IDToken, err = s.idToken(ctx, client, *auth.UserID, auth.Scopes, "", *auth.AuthTime)
+	if err != nil {
+		return domain.TokenSet{}, err
+	}
+	return tokens, nil
+}
+
+// slowDown makes a device that polls too fast wait longer between polls, and
+// returns the ErrSlowDown to answer it with.
+func (s *ServiceOIDC) slowDown(ctx context.Context, codeHash string) error {
+	interval, err := s.devices.SlowDownDeviceAuthorization(ctx, codeHash, deviceSlowDownStep)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: poll at most every %d seconds", ErrSlowDown, interval)
+}
+
+func randomUserCode() (string, error) {
+	size := big.NewInt(int64(len(userCodeAlphabet)))
+	code := make([]byte, userCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// normalizeUserCode accepts user codes typed in lower case or with the dash
+// and spaces, as users tend to.
+func normalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+}
@@ -0,0 +1,237 @@
+package oidc
+
+import (
+	"auth_service/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExchangeDeviceCodeSlowDown(t *testing.T) {
+	ctx := context.Background()
+	s, devices, sessions := newTestDeviceService()
+	client := domain.Client{ClientID: "tv"}
+
+	code, err := s.DeviceAuthorization(ctx, client, []string{ScopeOpenID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code.Interval != devicePollInterval {
+		t.Errorf("Interval = %s, want %s", code.Interval, devicePollInterval)
+	}
+
+	if _, err := s.ExchangeDeviceCode(ctx, client, code.DeviceCode, domain.ClientInfo{}); !errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("first poll: error = %v, want %v", err, ErrAuthorizationPending)
+	}
+
+	// Every poll that comes too early adds 5 seconds to the interval.
+	for _, want := range []int{10, 15} {
+		_, err := s.ExchangeDeviceCode(ctx, client, code.DeviceCode, domain.ClientInfo{})
+		if !errors.Is(err, ErrSlowDown) {
+			t.Fatalf("early poll: error = %v, want %v", err, ErrSlowDown)
+		}
+		if got := devices.get(code.DeviceCode).PollInterval; got != want {
+			t.Errorf("interval after slow_down = %d, want %d", got, want)
+		}
+	}
+
+	// 10 seconds was enough before, but no longer is.
+	devices.rewindLastPoll(code.DeviceCode, 10*time.Second)
+	if _, err := s.ExchangeDeviceCode(ctx, client, code.DeviceCode, domain.ClientInfo{}); !errors.Is(err, ErrSlowDown) {
+		t.Fatalf("poll after 10 seconds: error = %v, want %v", err, ErrSlowDown)
+	}
+
+	devices.rewindLastPoll(code.DeviceCode, 20*time.Second)
+	if _, err := s.ExchangeDeviceCode(ctx, client, code.DeviceCode, domain.ClientInfo{}); !errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("poll after 20 seconds: error = %v, want %v", err, ErrAuthorizationPending)
+	}
+	if len(sessions.grants) != 0 {
+		t.Error("a session was started before the user approved")
+	}
+}
+
+func TestExchangeDeviceCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		client   domain.Client
+		decision string
+		expired  bool
+		wantErr  error
+	}{
+		{name: "approved", client: domain.Client{ClientID: "tv"}, decision: domain.DeviceAuthorizationApproved},
+		{name: "denied", client: domain.Client{ClientID: "tv"}, decision: domain.DeviceAuthorizationDenied, wantErr: ErrAccessDenied},
+		{name: "other client", client: domain.Client{ClientID: "cli"}, decision: domain.DeviceAuthorizationApproved, wantErr: ErrInvalidGrant},
+		{name: "expired", client: domain.Client{ClientID: "tv"}, decision: domain.DeviceAuthorizationApproved, expired: true, wantErr: ErrExpiredToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, devices, sessions := newTestDeviceService()
+
+			code, err := s.DeviceAuthorization(ctx, domain.Client{ClientID: "tv"}, []string{ScopeOpenID, ScopeProfile})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Users type the code as they please.
+			err = s.DecideDevice(ctx, testUser.Id, strings.ToLower(code.UserCode), tt.decision == domain.DeviceAuthorizationApproved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.expired {
+				devices.expire(code.DeviceCode)
+			}
+
+			tokens, err := s.ExchangeDeviceCode(ctx, tt.client, code.DeviceCode, domain.ClientInfo{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExchangeDeviceCode() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(sessions.grants) != 0 {
+					t.Error("a session was started for a rejected poll")
+				}
+				return
+			}
+
+			if tokens.IDToken == "" {
+				t.Error("no id_token for the openid scope")
+			}
+			want := domain.Grant{ClientID: "tv", Scopes: []string{ScopeOpenID, ScopeProfile}}
+			if len(sessions.grants) != 1 || sessions.grants[0].ClientID != want.ClientID ||
+				!slices.Equal(sessions.grants[0].Scopes, want.Scopes) {
+				t.Errorf("session grants = %+v, want [%+v]", sessions.grants, want)
+			}
+
+			// The tokens are handed out once.
+			devices.rewindLastPoll(code.DeviceCode, time.Minute)
+			if _, err := s.ExchangeDeviceCode(ctx, tt.client, code.DeviceCode, domain.ClientInfo{}); !errors.Is(err, ErrInvalidGrant) {
+				t.Errorf("second poll: error = %v, want %v", err, ErrInvalidGrant)
+			}
+		})
+	}
+}
+
+func TestDecideDeviceUnknownCode(t *testing.T) {
+	s, _, _ := newTestDeviceService()
+	if err := s.DecideDevice(context.Background(), testUser.Id, "BCDF-GHJK", true); !errors.Is(err, ErrInvalidUserCode) {
+		t.Errorf("DecideDevice() error = %v, want %v", err, ErrInvalidUserCode)
+	}
+}
+
+func newTestDeviceService() (*ServiceOIDC, *memDevices, *recordingSessions) {
+	sessions := &recordingSessions{}
+	devices := &memDevices{auths: map[string]domain.DeviceAuthorization{}}
+	s := NewServiceOIDC(nil, nil, devices, memUsers{}, sessions, stubTokens{}, testLog, Config{
+		Issuer:                "https://auth.example.com",
+		DeviceVerificationURI: "https://auth.example.com/device",
+	})
+	return s, devices, sessions
+}
+
+// memDevices stores device authorizations the way the repository does. Polls
+// record their time and return the time of the previous one.
+type memDevices struct {
+	mu    sync.Mutex
+	auths map[string]domain.DeviceAuthorization
+}
+
+func (d *memDevices) get(deviceCode string) domain.DeviceAuthorization {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.auths[hashCode(deviceCode)]
+}
+
+// rewindLastPoll moves the last poll of deviceCode by ago into the past.
+func (d *memDevices) rewindLastPoll(deviceCode string, ago time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	auth, ok := d.auths[hashCode(deviceCode)]
+	if !ok {
+		return
+	}
+	polledAt := time.Now().Add(-ago)
+	auth.LastPolledAt = &polledAt
+	d.auths[auth.DeviceCodeHash] = auth
+}
+
+func (d *memDevices) expire(deviceCode string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	auth := d.auths[hashCode(deviceCode)]
+	auth.ExpiresAt = time.Now().Add(-time.Second)
+	d.auths[auth.DeviceCodeHash] = auth
+}
+
+func (d *memDevices) CreateDeviceAuthorization(ctx context.Context, auth domain.DeviceAuthorization) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.auths[auth.DeviceCodeHash] = auth
+	return nil
+}
+
+func (d *memDevices) GetDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (domain.DeviceAuthorization, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, auth := range d.auths {
+		if auth.UserCode == userCode {
+			return auth, nil
+		}
+	}
+	return domain.DeviceAuthorization{}, sql.ErrNoRows
+}
+
+func (d *memDevices) PollDeviceAuthorization(ctx context.Context, deviceCodeHash string) (domain.DeviceAuthorization, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	auth, ok := d.auths[deviceCodeHash]
+	if !ok {
+		return domain.DeviceAuthorization{}, sql.ErrNoRows
+	}
+	prev := auth.LastPolledAt
+	now := time.Now()
+	auth.LastPolledAt = &now
+	d.auths[deviceCodeHash] = auth
+	auth.LastPolledAt = prev
+	return auth, nil
+}
+
+func (d *memDevices) SlowDownDeviceAuthorization(ctx context.Context, deviceCodeHash string, step time.Duration) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	auth, ok := d.auths[deviceCodeHash]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	auth.PollInterval += int(step.Seconds())
+	d.auths[deviceCodeHash] = auth
+	return auth.PollInterval, nil
+}
+
+func (d *memDevices) DecideDeviceAuthorization(ctx context.Context, userCode string, userID uuid.UUID, status string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for hash, auth := range d.auths {
+		if auth.UserCode == userCode && auth.Status == domain.DeviceAuthorizationPending {
+			now := time.Now()
+			auth.Status, auth.UserID, auth.AuthTime = status, &userID, &now
+			d.auths[hash] = auth
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (d *memDevices) DeleteDeviceAuthorization(ctx context.Context, deviceCodeHash string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.auths[deviceCodeHash]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(d.auths, deviceCodeHash)
+	return nil
+}
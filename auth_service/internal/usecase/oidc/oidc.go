@@ -0,0 +1,325 @@
+package oidc
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/repository"
+	"auth_service/internal/usecase/oauth"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"time"
+)
+
+const authorizationCodeTTL = 5 * time.Minute
+
+const (
+	ScopeOpenID  = domain.ScopeOpenID
+	ScopeProfile = domain.ScopeProfile
+	ScopeEmail   = domain.ScopeEmail
+)
+
+var supportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// Sessions is the part of the auth service used to log users in.
+type Sessions interface {
+	Authenticate(ctx context.Context, login, password string) (domain.User, error)
+	StartSession(ctx context.Context, userID uuid.UUID, client domain.ClientInfo, audience []string, rememberMe bool, grant domain.Grant) (string, string, error)
+	Refresh(ctx context.Context, refreshToken, clientID string, client domain.ClientInfo, audience []string) (string, string, error)
+}
+
+type TokenManager interface {
+	NewIDToken(claims domain.IDTokenClaims) (string, error)
+	IDTokenAlgorithm() string
+	AccessTokenTTL() time.Duration
+}
+
+// ErrInvalidRedirectURI and oauth.ErrInvalidClient are reported to the user
+// agent; every other /authorize error is sent back to the client's redirect_uri.
+var (
+	ErrInvalidRedirectURI      = errors.New("redirect_uri is not registered for the client")
+	ErrUnsupportedResponseType = errors.New("only response_type=code is supported")
+	ErrPKCERequired            = errors.New("code_challenge with code_challenge_method=S256 is required")
+	ErrInvalidScope            = errors.New("invalid scope")
+	ErrLoginFailed             = errors.New("invalid login or password")
+	ErrInvalidGrant            = errors.New("invalid authorization code")
+)
+
+// Config holds the public URLs of the provider.
+type Config struct {
+	// Issuer is the public base URL of auth_service, e.g. https://auth.example.com.
+	Issuer string
+	// DeviceVerificationURI is the page where users enter device flow user
+	// codes, usually served by the frontend.
+	DeviceVerificationURI string
+}
+
+type ServiceOIDC struct {
+	clients  repository.Clients
+	codes    repository.AuthorizationCodes
+	devices  repository.DeviceAuthorizations
+	users    repository.Auth
+	sessions Sessions
+	tokens   TokenManager
+	log      *logger.SlogLogger
+
+	issuer                string
+	deviceVerificationURI string
+}
+
+func NewServiceOIDC(
+	clients repository.Clients,
+	codes repository.AuthorizationCodes,
+	devices repository.DeviceAuthorizations,
+	users repository.Auth,
+	sessions Sessions,
+	tokens TokenManager,
+	log *logger.SlogLogger,
+	cfg Config,
+) *ServiceOIDC {
+	return &ServiceOIDC{
+		clients:               clients,
+		codes:                 codes,
+		devices:               devices,
+		users:                 users,
+		sessions:              sessions,
+		tokens:                tokens,
+		log:                   log,
+		issuer:                strings.TrimSuffix(cfg.Issuer, "/"),
+		deviceVerificationURI: cfg.DeviceVerificationURI,
+	}
+}
+
+// Metadata returns the OpenID Provider discovery document.
+func (s *ServiceOIDC) Metadata() domain.ProviderMetadata {
+	return domain.ProviderMetadata{
+		Issuer:                      s.issuer,
+		AuthorizationEndpoint:       s.issuer + "/oauth2/authorize",
+		TokenEndpoint:               s.issuer + "/oauth2/token",
+		UserinfoEndpoint:            s.issuer + "/oauth2/userinfo",
+		JWKSURI:                     s.issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:       s.issuer + "/oauth2/introspect",
+		DeviceAuthorizationEndpoint: s.issuer + "/oauth2/device_authorization",
+		ScopesSupported:             supportedScopes,
+		ResponseTypesSupported:      []string{"code"},
+		GrantTypesSupported: []string{
+			"authorization_code", "refresh_token", "client_credentials",
+			oauth.GrantTypeTokenExchange, GrantTypeDeviceCode,
+		},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{s.tokens.IDTokenAlgorithm()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce",
+			"preferred_username", "name", "given_name", "family_name", "email",
+		},
+	}
+}
+
+// ValidateAuthorizationRequest checks an /authorize request before the login
+// form is shown. PKCE is mandatory for every client.
+func (s *ServiceOIDC) ValidateAuthorizationRequest(ctx context.Context, req domain.AuthorizationRequest) (domain.Client, error) {
+	client, err := s.clients.GetClientByClientID(ctx, req.ClientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Client{}, oauth.ErrInvalidClient
+	}
+	if err != nil {
+		return domain.Client{}, err
+	}
+
+	// Redirect URIs are compared exactly, as required for public clients.
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return domain.Client{}, ErrInvalidRedirectURI
+	}
+	if req.ResponseType != "code" {
+		return domain.Client{}, ErrUnsupportedResponseType
+	}
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) < 43 || len(req.CodeChallenge) > 128 {
+		return domain.Client{}, ErrPKCERequired
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(supportedScopes, scope) {
+			return domain.Client{}, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+	}
+
+	return client, nil
+}
+
+// Authorize logs the user in and returns a single-use authorization code bound
+// to the client, redirect_uri and PKCE challenge of the request.
+func (s *ServiceOIDC) Authorize(ctx context.Context, req domain.AuthorizationRequest, login, password string) (string, error) {
+	if _, err := s.ValidateAuthorizationRequest(ctx, req); err != nil {
+		return "", err
+	}
+
+	user, err := s.sessions.Authenticate(ctx, login, password)
+	if err != nil {
+		return "", ErrLoginFailed
+	}
+
+	code, err := randomCode()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = s.codes.CreateAuthorizationCode(ctx, domain.AuthorizationCode{
+		CodeHash:      hashCode(code),
+		ClientID:      req.ClientID,
+		UserID:        user.Id,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        req.Scopes,
+		CodeChallenge: req.CodeChallenge,
+		Nonce:         req.Nonce,
+		AuthTime:      now,
+		ExpiresAt:     now.Add(authorizationCodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// ExchangeCode redeems an authorization code (RFC 6749 section 4.1.3 with the
+// RFC 7636 PKCE check). A new session is opened for the user, and an id_token
+// is added when the openid scope was granted.
+func (s *ServiceOIDC) ExchangeCode(ctx context.Context, client domain.Client, code, redirectURI, verifier string, info domain.ClientInfo) (domain.TokenSet, error) {
+	stored, err := s.codes.ConsumeAuthorizationCode(ctx, hashCode(code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.TokenSet{}, ErrInvalidGrant
+	}
+	if err != nil {
+		return domain.TokenSet{}, err
+	}
+
+	if time.Now().After(stored.ExpiresAt) ||
+		stored.ClientID != client.ClientID ||
+		stored.RedirectURI != redirectURI ||
+		!verifyPKCE(verifier, stored.CodeChallenge) {
+		s.log.Warn(ctx, "oidc: authorization code rejected", "client_id", client.ClientID)
+		return domain.TokenSet{}, ErrInvalidGrant
+	}
+
+	grant := domain.Grant{ClientID: client.ClientID, Scopes: stored.Scopes}
+	expiresAt := s.accessExpiry()
+	access, refresh, err := s.sessions.StartSession(ctx, stored.UserID, info, nil, false, grant)
+	if err != nil {
+		return domain.TokenSet{}, err
+	}
+
+	tokens := domain.TokenSet{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		Scopes:       stored.Scopes,
+		ExpiresAt:    expiresAt,
+	}
+	if !slices.Contains(stored.Scopes, ScopeOpenID) {
+		return tokens, nil
+	}
+
+	tokens.IDToken, err = s.idToken(ctx, client, stored.UserID, stored.Scopes, stored.Nonce, stored.AuthTime)
+	if err != nil {
+		return domain.TokenSet{}, err
+	}
+	return tokens, nil
+}
+
+// RefreshTokens rotates the refresh token of a session the client opened.
+// Refresh tokens of other clients' sessions, and of the user's own logins,
+// are rejected.
+func (s *ServiceOIDC) RefreshTokens(ctx context.Context, client domain.Client, refreshToken string, info domain.ClientInfo) (domain.TokenSet, error) {
+	expiresAt := s.accessExpiry()
+	access, refresh, err := s.sessions.Refresh(ctx, refreshToken, client.ClientID, info, nil)
+	if err != nil {
+		return domain.TokenSet{}, err
+	}
+	return domain.TokenSet{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// accessExpiry is when an access token issued now expires at the latest; take
+// it before issuing, so that expires_in never overstates the lifetime.
+func (s *ServiceOIDC) accessExpiry() time.Time {
+	return time.Now().Add(s.tokens.AccessTokenTTL())
+}
+
+func (s *ServiceOIDC) idToken(ctx context.Context, client domain.Client, userID uuid.UUID, scopes []string, nonce string, authTime time.Time) (string, error) {
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	return s.tokens.NewIDToken(domain.IDTokenClaims{
+		Issuer:   s.issuer,
+		Subject:  user.Id.String(),
+		Audience: client.ClientID,
+		Nonce:    nonce,
+		AuthTime: authTime,
+		UserInfo: userInfo(user, scopes),
+	})
+}
+
+// UserInfo returns the claims about the caller that the token reveals: those
+// of the scopes granted to the OIDC client it was issued to, or all of them
+// for first-party and personal access tokens.
+func (s *ServiceOIDC) UserInfo(ctx context.Context, identity domain.Identity) (domain.UserInfo, error) {
+	user, err := s.users.GetUserByID(ctx, identity.UserID)
+	if err != nil {
+		return domain.UserInfo{}, err
+	}
+	scopes := supportedScopes
+	if identity.ClientID != "" {
+		scopes = identity.Scopes
+	}
+	return userInfo(user, scopes), nil
+}
+
+// userInfo picks the claims about user that scopes grant, for id_tokens and
+// the UserInfo endpoint alike.
+func userInfo(user domain.User, scopes []string) domain.UserInfo {
+	var info domain.UserInfo
+	if slices.Contains(scopes, ScopeProfile) {
+		info.PreferredUsername = user.Username
+		info.GivenName = user.FirstName
+		info.FamilyName = user.LastName
+		info.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	}
+	if slices.Contains(scopes, ScopeEmail) {
+		info.Email = user.Email
+	}
+	return info
+}
+
+func verifyPKCE(verifier, challenge string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomCode() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
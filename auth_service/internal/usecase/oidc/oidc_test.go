@@ -0,0 +1,251 @@
+package oidc
+
+import (
+	"auth_service/internal/domain"
+	"auth_service/internal/infrastructure/logger"
+	"auth_service/internal/infrastructure/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testLog = logger.New("test")
+
+const (
+	testVerifier  = "dBjftJeZ4CVP-mJ92IyM0gCZx0UjLYfxU6-F0pOXNq4"
+	testChallenge = "DlBiZO6b4wlejQz_4PvTEtHhqwGetjqyYmmAJEE8Cfc"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{"matching verifier", testVerifier, testChallenge, true},
+		{"wrong verifier", testVerifier + "x", testChallenge, false},
+		{"plain method", testVerifier, testVerifier, false},
+		{"missing verifier", "", testChallenge, false},
+		{"missing challenge", testVerifier, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyPKCE(tt.verifier, tt.challenge); got != tt.want {
+				t.Errorf("verifyPKCE(%q, %q) = %v, want %v", tt.verifier, tt.challenge, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeCode(t *testing.T) {
+	client := domain.Client{ClientID: "web"}
+	tests := []struct {
+		name        string
+		client      domain.Client
+		redirectURI string
+		verifier    string
+		expired     bool
+		wantErr     error
+	}{
+		{name: "valid", client: client, redirectURI: "https://app.example.com/cb", verifier: testVerifier},
+		{name: "wrong verifier", client: client, redirectURI: "https://app.example.com/cb", verifier: "wrong", wantErr: ErrInvalidGrant},
+		{name: "missing verifier", client: client, redirectURI: "https://app.example.com/cb", wantErr: ErrInvalidGrant},
+		{name: "other client", client: domain.Client{ClientID: "cli"}, redirectURI: "https://app.example.com/cb", verifier: testVerifier, wantErr: ErrInvalidGrant},
+		{name: "other redirect_uri", client: client, redirectURI: "https://evil.example.com/cb", verifier: testVerifier, wantErr: ErrInvalidGrant},
+		{name: "expired code", client: client, redirectURI: "https://app.example.com/cb", verifier: testVerifier, expired: true, wantErr: ErrInvalidGrant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, sessions, codes := newTestService()
+			expiresAt := time.Now().Add(authorizationCodeTTL)
+			if tt.expired {
+				expiresAt = time.Now().Add(-time.Second)
+			}
+			codes.add("code", domain.AuthorizationCode{
+				ClientID:      "web",
+				UserID:        testUser.Id,
+				RedirectURI:   "https://app.example.com/cb",
+				Scopes:        []string{ScopeOpenID, ScopeEmail},
+				CodeChallenge: testChallenge,
+				ExpiresAt:     expiresAt,
+			})
+
+			tokens, err := s.ExchangeCode(context.Background(), tt.client, "code", tt.redirectURI, tt.verifier, domain.ClientInfo{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExchangeCode() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(sessions.grants) != 0 {
+					t.Error("a session was started for a rejected code")
+				}
+				return
+			}
+
+			if tokens.IDToken == "" {
+				t.Error("no id_token for the openid scope")
+			}
+			if until := time.Until(tokens.ExpiresAt); until <= 4*time.Minute || until > 5*time.Minute {
+				t.Errorf("access token expires in %s, want the access token lifetime", until)
+			}
+			want := domain.Grant{ClientID: "web", Scopes: []string{ScopeOpenID, ScopeEmail}}
+			if len(sessions.grants) != 1 || sessions.grants[0].ClientID != want.ClientID ||
+				!slices.Equal(sessions.grants[0].Scopes, want.Scopes) {
+				t.Errorf("session grants = %+v, want [%+v]", sessions.grants, want)
+			}
+
+			// Codes are single use, even with the right verifier.
+			_, err = s.ExchangeCode(context.Background(), tt.client, "code", tt.redirectURI, tt.verifier, domain.ClientInfo{})
+			if !errors.Is(err, ErrInvalidGrant) {
+				t.Errorf("second ExchangeCode() error = %v, want %v", err, ErrInvalidGrant)
+			}
+		})
+	}
+}
+
+// Refresh tokens are rotated for the client that presents them, which the
+// auth service matches against the client of the session.
+func TestRefreshTokens(t *testing.T) {
+	s, sessions, _ := newTestService()
+
+	tokens, err := s.RefreshTokens(context.Background(), domain.Client{ClientID: "web"}, "refresh", domain.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(sessions.refreshes, []string{"web"}) {
+		t.Errorf("refreshed for clients %v, want [web]", sessions.refreshes)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Errorf("tokens = %+v, want an access and a refresh token", tokens)
+	}
+	if until := time.Until(tokens.ExpiresAt); until <= 4*time.Minute || until > 5*time.Minute {
+		t.Errorf("access token expires in %s, want the access token lifetime", until)
+	}
+}
+
+func TestUserInfo(t *testing.T) {
+	tests := []struct {
+		name      string
+		identity  domain.Identity
+		wantName  bool
+		wantEmail bool
+	}{
+		{"first-party token", domain.Identity{}, true, true},
+		{"personal access token", domain.Identity{PersonalAccessTokenID: uuid.New(), Scopes: []string{domain.ScopeProfileRead}}, true, true},
+		{"openid only", domain.Identity{ClientID: "web", Scopes: []string{ScopeOpenID}}, false, false},
+		{"profile scope", domain.Identity{ClientID: "web", Scopes: []string{ScopeOpenID, ScopeProfile}}, true, false},
+		{"email scope", domain.Identity{ClientID: "web", Scopes: []string{ScopeOpenID, ScopeEmail}}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestService()
+			tt.identity.UserID = testUser.Id
+
+			info, err := s.UserInfo(context.Background(), tt.identity)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.PreferredUsername != "" && info.Name != ""; got != tt.wantName {
+				t.Errorf("profile claims returned = %v, want %v (%+v)", got, tt.wantName, info)
+			}
+			if got := info.Email != ""; got != tt.wantEmail {
+				t.Errorf("email returned = %v, want %v (%+v)", got, tt.wantEmail, info)
+			}
+		})
+	}
+}
+
+var testUser = domain.User{
+	Id:        uuid.MustParse("6f1c0a7e-3b5d-4c2a-9e8f-0a1b2c3d4e5f"),
+	Username:  "john_doe",
+	Email:     "john@example.com",
+	FirstName: "John",
+	LastName:  "Doe",
+}
+
+func newTestService() (*ServiceOIDC, *recordingSessions, *memCodes) {
+	sessions := &recordingSessions{}
+	codes := &memCodes{codes: map[string]domain.AuthorizationCode{}}
+	s := NewServiceOIDC(nil, codes, nil, memUsers{}, sessions, stubTokens{}, testLog, Config{Issuer: "https://auth.example.com"})
+	return s, sessions, codes
+}
+
+// memUsers only implements the lookup of testUser.
+type memUsers struct {
+	repository.Auth
+}
+
+func (memUsers) GetUserByID(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	if id != testUser.Id {
+		return domain.User{}, sql.ErrNoRows
+	}
+	return testUser, nil
+}
+
+type recordingSessions struct {
+	grants    []domain.Grant
+	refreshes []string // client_id of every refresh
+}
+
+func (s *recordingSessions) Authenticate(ctx context.Context, login, password string) (domain.User, error) {
+	return testUser, nil
+}
+
+func (s *recordingSessions) StartSession(ctx context.Context, userID uuid.UUID, client domain.ClientInfo, audience []string, rememberMe bool, grant domain.Grant) (string, string, error) {
+	s.grants = append(s.grants, grant)
+	return "access", "refresh", nil
+}
+
+func (s *recordingSessions) Refresh(ctx context.Context, refreshToken, clientID string, client domain.ClientInfo, audience []string) (string, string, error) {
+	s.refreshes = append(s.refreshes, clientID)
+	return "access", "refresh", nil
+}
+
+type stubTokens struct{}
+
+func (stubTokens) NewIDToken(claims domain.IDTokenClaims) (string, error) {
+	return "id-token", nil
+}
+
+func (stubTokens) IDTokenAlgorithm() string {
+	return "RS256"
+}
+
+func (stubTokens) AccessTokenTTL() time.Duration {
+	return 5 * time.Minute
+}
+
+// memCodes consumes codes the way the repository does: a code can be read
+// once, whether it is then accepted or not.
+type memCodes struct {
+	mu    sync.Mutex
+	codes map[string]domain.AuthorizationCode
+}
+
+func (c *memCodes) add(code string, stored domain.AuthorizationCode) {
+	stored.CodeHash = hashCode(code)
+	c.codes[stored.CodeHash] = stored
+}
+
+func (c *memCodes) CreateAuthorizationCode(ctx context.Context, code domain.AuthorizationCode) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.codes[code.CodeHash] = code
+	return nil
+}
+
+func (c *memCodes) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (domain.AuthorizationCode, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	code, ok := c.codes[codeHash]
+	if !ok {
+		return domain.AuthorizationCode{}, sql.ErrNoRows
+	}
+	delete(c.codes, codeHash)
+	return code, nil
+}
@@ -5,27 +5,98 @@ import (
 	"auth_service/internal/infrastructure/logger"
 	"auth_service/internal/infrastructure/repository"
 	"auth_service/internal/usecase/auth"
+	"auth_service/internal/usecase/oauth"
+	"auth_service/internal/usecase/oidc"
 	"context"
 	"github.com/google/uuid"
+	"time"
 )
 
 type Auth interface {
 	Register(ctx context.Context, user domain.User) (uuid.UUID, error)
-	Login(ctx context.Context, username, password string) (string, string, error)
+	Login(ctx context.Context, login, password string, client domain.ClientInfo, audience []string, rememberMe bool) (string, string, error)
 	ParseRefreshToken(ctx context.Context, tokenR string) (string, error)
-	ParseAccessToken(ctx context.Context, token string) (uuid.UUID, error)
-	GenerateAccessToken(userId string) (string, error)
-	Refresh(ctx context.Context, refreshToken string) (string, string, error)
-	Logout(ctx context.Context, accessToken string) error
-	Me(ctx context.Context, accessToken string) (*domain.User, error)
+	ParseAccessToken(ctx context.Context, token string, audiences []string) (domain.Identity, error)
+	Refresh(ctx context.Context, refreshToken, clientID string, client domain.ClientInfo, audience []string) (string, string, error)
+	Logout(ctx context.Context, identity domain.Identity) error
+	Me(ctx context.Context, userID uuid.UUID) (*domain.User, error)
+	UpdateProfile(ctx context.Context, userID uuid.UUID, update domain.ProfileUpdate, versions []time.Time) (domain.User, error)
+	VerifyEmail(ctx context.Context, token string) (uuid.UUID, error)
+	ResendVerificationEmail(ctx context.Context, userID uuid.UUID) error
+	ForgotPassword(ctx context.Context, email string)
+	ResetPassword(ctx context.Context, token, password string) error
+	ChangePassword(ctx context.Context, identity domain.Identity, current, password string, client domain.ClientInfo, audience []string) (string, string, error)
+	JWKS() domain.JWKSet
+
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]domain.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error
+	LogoutAll(ctx context.Context, userID uuid.UUID) error
+
+	CreatePersonalAccessToken(ctx context.Context, userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (domain.PersonalAccessToken, string, error)
+	ListPersonalAccessTokens(ctx context.Context, userID uuid.UUID) ([]domain.PersonalAccessToken, error)
+	RevokePersonalAccessToken(ctx context.Context, userID, id uuid.UUID) error
+
+	CreateCapability(ctx context.Context, userID uuid.UUID, fileKey, action string) (domain.Capability, string, error)
+	RedeemCapability(ctx context.Context, token, fileKey, action string) (domain.Capability, error)
+
+	CreateTicket(ctx context.Context, userID uuid.UUID, channel string) (domain.Ticket, string, error)
+	RedeemTicket(ctx context.Context, ticket, channel string) (domain.Ticket, error)
+
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID) (int64, error)
+	RevokeAllTokens(ctx context.Context) (int64, error)
+}
+
+type OAuth interface {
+	CreateClient(ctx context.Context, client domain.Client) (string, error)
+	AuthenticateClient(ctx context.Context, clientID, secret string) (domain.Client, error)
+	AuthenticatePublicClient(ctx context.Context, clientID string) (domain.Client, error)
+	ClientCredentials(ctx context.Context, client domain.Client, scopes, audience []string) (domain.IssuedToken, error)
+	ExchangeToken(ctx context.Context, client domain.Client, subjectToken, subjectTokenType string, scopes, audience []string) (domain.IssuedToken, error)
+	Introspect(ctx context.Context, client domain.Client, token string) domain.Introspection
+}
+
+// TokenManager is what the auth and oauth services need from the token manager.
+type TokenManager interface {
+	auth.TokenManager
+	oauth.TokenManager
+	oidc.TokenManager
+}
+
+type OIDC interface {
+	Metadata() domain.ProviderMetadata
+	ValidateAuthorizationRequest(ctx context.Context, req domain.AuthorizationRequest) (domain.Client, error)
+	Authorize(ctx context.Context, req domain.AuthorizationRequest, login, password string) (string, error)
+	ExchangeCode(ctx context.Context, client domain.Client, code, redirectURI, verifier string, info domain.ClientInfo) (domain.TokenSet, error)
+	RefreshTokens(ctx context.Context, client domain.Client, refreshToken string, info domain.ClientInfo) (domain.TokenSet, error)
+	UserInfo(ctx context.Context, identity domain.Identity) (domain.UserInfo, error)
+
+	DeviceAuthorization(ctx context.Context, client domain.Client, scopes []string) (domain.DeviceCode, error)
+	LookupDevice(ctx context.Context, userCode string) (domain.DeviceAuthorization, domain.Client, error)
+	DecideDevice(ctx context.Context, userID uuid.UUID, userCode string, approve bool) error
+	ExchangeDeviceCode(ctx context.Context, client domain.Client, deviceCode string, info domain.ClientInfo) (domain.TokenSet, error)
 }
 
 type Service struct {
 	Auth
+	OAuth
+	OIDC
+}
+
+// Config holds the settings of the services.
+type Config struct {
+	OIDC     oidc.Config
+	Sessions domain.SessionPolicies
+	Claims   domain.ClaimsPolicy
+	Links    auth.Links
 }
 
-func NewService(rep *repository.Repository, log *logger.SlogLogger, tokens auth.TokenManager) *Service {
+// NewService wires the services.
+func NewService(rep *repository.Repository, log *logger.SlogLogger, tokens TokenManager, mailer auth.Mailer, cfg Config) *Service {
+	claims := auth.NewClaimsBuilder(rep.Auth, tokens, cfg.Claims)
+	authService := auth.NewServiceAuth(rep.Auth, rep.SecurityEvents, rep.PersonalAccessTokens, rep.Capabilities, rep.Tickets, rep.PasswordResetTokens, log, tokens, claims, mailer, cfg.Sessions, cfg.Links)
 	return &Service{
-		Auth: auth.NewServiceAuth(rep, log, tokens),
+		Auth:  authService,
+		OAuth: oauth.NewServiceOAuth(rep.Clients, log, tokens, claims),
+		OIDC:  oidc.NewServiceOIDC(rep.Clients, rep.AuthorizationCodes, rep.DeviceAuthorizations, rep.Auth, authService, tokens, log, cfg.OIDC),
 	}
 }
@@ -0,0 +1,6 @@
+-- 20261017090000_create_refresh_token_families.down.sql
+
+DROP TABLE IF EXISTS security_events;
+DROP TABLE IF EXISTS used_refresh_tokens;
+
+ALTER TABLE users DROP COLUMN IF EXISTS refresh_family_id;
@@ -0,0 +1,23 @@
+-- 20261017090000_create_refresh_token_families.up.sql
+
+ALTER TABLE users ADD COLUMN refresh_family_id UUID;
+
+CREATE TABLE used_refresh_tokens (
+                       jti UUID PRIMARY KEY,
+                       family_id UUID NOT NULL,
+                       user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                       used_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_used_refresh_tokens_user_expires ON used_refresh_tokens (user_id, expires_at);
+
+CREATE TABLE security_events (
+                       id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                       user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+                       event_type VARCHAR(64) NOT NULL,
+                       details JSONB NOT NULL DEFAULT '{}',
+                       created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_security_events_user ON security_events (user_id, created_at);
@@ -0,0 +1,17 @@
+-- 20261017100000_create_sessions_table.down.sql
+
+ALTER TABLE users ADD COLUMN refresh_token TEXT;
+ALTER TABLE users ADD COLUMN refresh_family_id UUID;
+
+-- Only one refresh token per user fits back: keep the most recently used session.
+UPDATE users u
+SET refresh_token = s.refresh_token, refresh_family_id = s.id
+FROM (
+    SELECT DISTINCT ON (user_id) id, user_id, refresh_token
+    FROM sessions
+    WHERE revoked_at IS NULL AND refresh_token IS NOT NULL
+    ORDER BY user_id, last_used_at DESC
+) s
+WHERE u.id = s.user_id;
+
+DROP TABLE IF EXISTS sessions;
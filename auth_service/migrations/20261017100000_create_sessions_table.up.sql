@@ -0,0 +1,24 @@
+-- 20261017100000_create_sessions_table.up.sql
+
+CREATE TABLE sessions (
+                       id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                       user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                       refresh_token TEXT,
+                       user_agent TEXT NOT NULL DEFAULT '',
+                       ip VARCHAR(64) NOT NULL DEFAULT '',
+                       created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       last_used_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_sessions_user ON sessions (user_id) WHERE revoked_at IS NULL;
+
+-- The refresh family id becomes the session id, so tokens issued before this
+-- migration keep working.
+INSERT INTO sessions (id, user_id, refresh_token)
+SELECT COALESCE(refresh_family_id, uuid_generate_v4()), id, refresh_token
+FROM users
+WHERE refresh_token IS NOT NULL;
+
+ALTER TABLE users DROP COLUMN refresh_token;
+ALTER TABLE users DROP COLUMN refresh_family_id;
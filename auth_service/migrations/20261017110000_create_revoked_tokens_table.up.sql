@@ -0,0 +1,12 @@
+-- 20261017110000_create_revoked_tokens_table.up.sql
+
+-- Denylist of access tokens. id is either a token's jti or a session id (sid),
+-- the latter revoking every access token issued for that session. Rows are
+-- deleted once expires_at has passed, since the tokens are dead by then anyway.
+CREATE TABLE revoked_tokens (
+                       id UUID PRIMARY KEY,
+                       expires_at TIMESTAMP NOT NULL,
+                       revoked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_revoked_tokens_expires ON revoked_tokens (expires_at);
@@ -0,0 +1,3 @@
+-- 20261017120000_create_clients_table.down.sql
+
+DROP TABLE IF EXISTS clients;
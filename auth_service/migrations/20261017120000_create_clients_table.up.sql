@@ -0,0 +1,11 @@
+-- 20261017120000_create_clients_table.up.sql
+
+-- OAuth2 clients: internal services that authenticate to auth_service with
+-- client_id + client_secret. Only a bcrypt hash of the secret is stored.
+CREATE TABLE clients (
+                       id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                       client_id VARCHAR(255) UNIQUE NOT NULL,
+                       client_secret_hash VARCHAR(255) NOT NULL,
+                       name VARCHAR(255) NOT NULL,
+                       created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
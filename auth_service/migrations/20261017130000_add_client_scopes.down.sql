@@ -0,0 +1,3 @@
+-- 20261017130000_add_client_scopes.down.sql
+
+ALTER TABLE clients DROP COLUMN IF EXISTS scopes;
@@ -0,0 +1,4 @@
+-- 20261017130000_add_client_scopes.up.sql
+
+-- Scopes a client may request with the client_credentials grant.
+ALTER TABLE clients ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{}';
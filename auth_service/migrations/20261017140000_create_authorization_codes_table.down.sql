@@ -0,0 +1,6 @@
+-- 20261017140000_create_authorization_codes_table.down.sql
+
+DROP TABLE IF EXISTS authorization_codes;
+
+ALTER TABLE clients DROP COLUMN IF EXISTS public;
+ALTER TABLE clients DROP COLUMN IF EXISTS redirect_uris;
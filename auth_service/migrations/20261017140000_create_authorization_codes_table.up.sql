@@ -0,0 +1,21 @@
+-- 20261017140000_create_authorization_codes_table.up.sql
+
+ALTER TABLE clients ADD COLUMN redirect_uris TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE clients ADD COLUMN public BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- OIDC authorization codes. Only a SHA-256 hash of the code is stored; a row is
+-- deleted when the code is redeemed.
+CREATE TABLE authorization_codes (
+                       code_hash VARCHAR(64) PRIMARY KEY,
+                       client_id VARCHAR(255) NOT NULL REFERENCES clients(client_id) ON DELETE CASCADE,
+                       user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                       redirect_uri TEXT NOT NULL,
+                       scopes TEXT[] NOT NULL DEFAULT '{}',
+                       code_challenge VARCHAR(128) NOT NULL,
+                       nonce TEXT NOT NULL DEFAULT '',
+                       auth_time TIMESTAMP NOT NULL,
+                       created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_authorization_codes_expires ON authorization_codes (expires_at);
@@ -0,0 +1,20 @@
+-- 20261017150000_hash_refresh_tokens.down.sql
+
+-- Hashes cannot be turned back into tokens: every session has to log in again.
+DROP TABLE used_refresh_tokens;
+
+CREATE TABLE used_refresh_tokens (
+                       jti UUID PRIMARY KEY,
+                       family_id UUID NOT NULL,
+                       user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                       used_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_used_refresh_tokens_user_expires ON used_refresh_tokens (user_id, expires_at);
+
+DROP INDEX IF EXISTS idx_sessions_refresh_token_hash;
+
+ALTER TABLE sessions ADD COLUMN refresh_token TEXT;
+ALTER TABLE sessions DROP COLUMN refresh_expires_at;
+ALTER TABLE sessions DROP COLUMN refresh_token_hash;
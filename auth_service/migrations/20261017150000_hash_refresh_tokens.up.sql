@@ -0,0 +1,31 @@
+-- 20261017150000_hash_refresh_tokens.up.sql
+
+-- Refresh tokens become opaque and only their SHA-256 is stored. The JWT refresh
+-- tokens already handed out are hashed in place, so they keep working until
+-- their next rotation, which replaces them with opaque ones.
+ALTER TABLE sessions ADD COLUMN refresh_token_hash VARCHAR(64);
+ALTER TABLE sessions ADD COLUMN refresh_expires_at TIMESTAMP;
+
+UPDATE sessions
+SET refresh_token_hash = encode(sha256(convert_to(refresh_token, 'UTF8')), 'hex'),
+    refresh_expires_at = last_used_at + INTERVAL '7 days'
+WHERE refresh_token IS NOT NULL;
+
+ALTER TABLE sessions DROP COLUMN refresh_token;
+
+CREATE UNIQUE INDEX idx_sessions_refresh_token_hash ON sessions (refresh_token_hash);
+
+-- Used tokens are identified by hash as well. Rows recorded by jti cannot be
+-- converted: replaying such a token is still rejected, it just no longer
+-- revokes the family.
+DROP TABLE used_refresh_tokens;
+
+CREATE TABLE used_refresh_tokens (
+                       token_hash VARCHAR(64) PRIMARY KEY,
+                       family_id UUID NOT NULL,
+                       user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                       used_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_used_refresh_tokens_user_expires ON used_refresh_tokens (user_id, expires_at);
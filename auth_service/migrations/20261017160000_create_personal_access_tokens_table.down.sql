@@ -0,0 +1,3 @@
+-- 20261017160000_create_personal_access_tokens_table.down.sql
+
+DROP TABLE IF EXISTS personal_access_tokens;
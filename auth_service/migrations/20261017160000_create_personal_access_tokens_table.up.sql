@@ -0,0 +1,16 @@
+-- 20261017160000_create_personal_access_tokens_table.up.sql
+
+-- Personal access tokens for scripts. Only a SHA-256 hash of the token is stored.
+CREATE TABLE personal_access_tokens (
+                       id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                       user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                       name VARCHAR(100) NOT NULL,
+                       token_hash VARCHAR(64) NOT NULL UNIQUE,
+                       scopes TEXT[] NOT NULL DEFAULT '{}',
+                       created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       expires_at TIMESTAMP,
+                       last_used_at TIMESTAMP,
+                       revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_personal_access_tokens_user ON personal_access_tokens (user_id) WHERE revoked_at IS NULL;
@@ -0,0 +1,3 @@
+-- 20261017170000_create_device_authorizations_table.down.sql
+
+DROP TABLE IF EXISTS device_authorizations;
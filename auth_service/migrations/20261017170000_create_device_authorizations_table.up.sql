@@ -0,0 +1,18 @@
+-- 20261017170000_create_device_authorizations_table.up.sql
+
+-- RFC 8628 device authorizations. Only a SHA-256 hash of the device code is
+-- stored; a row is deleted when its tokens are issued or it is denied.
+CREATE TABLE device_authorizations (
+                       device_code_hash VARCHAR(64) PRIMARY KEY,
+                       user_code VARCHAR(8) NOT NULL UNIQUE,
+                       client_id VARCHAR(255) NOT NULL REFERENCES clients(client_id) ON DELETE CASCADE,
+                       scopes TEXT[] NOT NULL DEFAULT '{}',
+                       status VARCHAR(16) NOT NULL DEFAULT 'pending',
+                       user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+                       auth_time TIMESTAMP,
+                       last_polled_at TIMESTAMP,
+                       created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_device_authorizations_expires ON device_authorizations (expires_at);
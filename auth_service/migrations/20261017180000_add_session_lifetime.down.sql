@@ -0,0 +1,4 @@
+-- 20261017180000_add_session_lifetime.down.sql
+
+ALTER TABLE sessions DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE sessions DROP COLUMN IF EXISTS remember_me;
@@ -0,0 +1,13 @@
+-- 20261017180000_add_session_lifetime.up.sql
+
+-- Sessions get an absolute end and remember whether the user asked for a long
+-- session. Existing sessions were issued 7-day refresh tokens, so they count as
+-- "remember me" sessions and end 30 days after they were opened.
+ALTER TABLE sessions ADD COLUMN remember_me BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE sessions ADD COLUMN expires_at TIMESTAMP;
+
+UPDATE sessions
+SET remember_me = TRUE,
+    expires_at = created_at + INTERVAL '30 days';
+
+ALTER TABLE sessions ALTER COLUMN expires_at SET NOT NULL;
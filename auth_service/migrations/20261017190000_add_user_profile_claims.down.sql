@@ -0,0 +1,5 @@
+-- 20261017190000_add_user_profile_claims.down.sql
+
+ALTER TABLE users DROP COLUMN IF EXISTS locale;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified;
+ALTER TABLE users DROP COLUMN IF EXISTS roles;
@@ -0,0 +1,7 @@
+-- 20261017190000_add_user_profile_claims.up.sql
+
+-- Profile data that access tokens can carry, so that other services do not have
+-- to call /auth/me for it.
+ALTER TABLE users ADD COLUMN roles TEXT[] NOT NULL DEFAULT '{user}';
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- 20261017200000_create_redeemed_capabilities_table.down.sql
+
+DROP TABLE IF EXISTS redeemed_capabilities;
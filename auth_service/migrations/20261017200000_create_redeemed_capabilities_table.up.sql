@@ -0,0 +1,14 @@
+-- 20261017200000_create_redeemed_capabilities_table.up.sql
+
+-- jti of every capability token that has been redeemed, so that each works only
+-- once. Rows are useless once the token has expired and are deleted then.
+CREATE TABLE redeemed_capabilities (
+                       id UUID PRIMARY KEY,
+                       user_id UUID NOT NULL,
+                       file_key TEXT NOT NULL,
+                       action VARCHAR(32) NOT NULL,
+                       expires_at TIMESTAMP NOT NULL,
+                       redeemed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_redeemed_capabilities_expires ON redeemed_capabilities (expires_at);
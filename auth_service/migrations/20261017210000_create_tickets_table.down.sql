@@ -0,0 +1,3 @@
+-- 20261017210000_create_tickets_table.down.sql
+
+DROP TABLE IF EXISTS tickets;
@@ -0,0 +1,13 @@
+-- 20261017210000_create_tickets_table.up.sql
+
+-- One-time tickets for WebSocket and SSE connections. A ticket is deleted when
+-- it is redeemed; expired ones are cleared whenever a new ticket is created.
+CREATE TABLE tickets (
+                       ticket_hash VARCHAR(64) PRIMARY KEY,
+                       user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                       channel VARCHAR(255) NOT NULL,
+                       created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_tickets_expires ON tickets (expires_at);
@@ -0,0 +1,4 @@
+-- 20261017220000_add_token_epochs.down.sql
+
+DROP TABLE IF EXISTS token_epoch;
+ALTER TABLE users DROP COLUMN IF EXISTS token_epoch;
@@ -0,0 +1,14 @@
+-- 20261017220000_add_token_epochs.up.sql
+
+-- Token epochs for break-glass revocation. Every JWT carries the global and
+-- per-user epochs it was issued under in its "ver" claim; incrementing a
+-- counter invalidates every token issued before.
+ALTER TABLE users ADD COLUMN token_epoch BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE token_epoch (
+                       id SMALLINT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+                       epoch BIGINT NOT NULL DEFAULT 0,
+                       updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO token_epoch (id, epoch) VALUES (1, 0);
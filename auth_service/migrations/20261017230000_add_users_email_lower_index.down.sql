@@ -0,0 +1,3 @@
+-- 20261017230000_add_users_email_lower_index.down.sql
+
+DROP INDEX IF EXISTS idx_users_email_lower;
@@ -0,0 +1,6 @@
+-- 20261017230000_add_users_email_lower_index.up.sql
+
+-- Login looks users up by LOWER(email). The index is unique so that an email
+-- differing only in case cannot be registered twice and make login ambiguous;
+-- resolve such duplicates before running this migration.
+CREATE UNIQUE INDEX idx_users_email_lower ON users (LOWER(email));
@@ -0,0 +1,3 @@
+-- 20261017233000_add_email_verification.down.sql
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_verification_sent_at;
@@ -0,0 +1,7 @@
+-- 20261017233000_add_email_verification.up.sql
+
+-- New accounts start unverified. Accounts created before verification existed
+-- never got a link, so they are treated as verified.
+UPDATE users SET email_verified = TRUE;
+
+ALTER TABLE users ADD COLUMN email_verification_sent_at TIMESTAMP;
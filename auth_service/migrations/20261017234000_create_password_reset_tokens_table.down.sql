@@ -0,0 +1,3 @@
+-- 20261017234000_create_password_reset_tokens_table.down.sql
+
+DROP TABLE IF EXISTS password_reset_tokens;
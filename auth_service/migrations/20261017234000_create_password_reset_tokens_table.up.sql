@@ -0,0 +1,14 @@
+-- 20261017234000_create_password_reset_tokens_table.up.sql
+
+-- Tokens of password reset links. A token is deleted when it is used, and all
+-- of a user's tokens when their password changes; expired ones are cleared
+-- whenever a new token is created.
+CREATE TABLE password_reset_tokens (
+                       token_hash VARCHAR(64) PRIMARY KEY,
+                       user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                       created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                       expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_password_reset_tokens_user ON password_reset_tokens (user_id, created_at);
+CREATE INDEX idx_password_reset_tokens_expires ON password_reset_tokens (expires_at);
@@ -0,0 +1,3 @@
+-- 20261017235000_add_users_updated_at.down.sql
+
+ALTER TABLE users DROP COLUMN IF EXISTS updated_at;
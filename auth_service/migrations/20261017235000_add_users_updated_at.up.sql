@@ -0,0 +1,6 @@
+-- 20261017235000_add_users_updated_at.up.sql
+
+-- Version of the profile, sent as the ETag of /api/v1/auth/me so that
+-- concurrent edits are detected. With a time zone, the instant read back and
+-- compared does not depend on the TimeZone of the server or the session.
+ALTER TABLE users ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
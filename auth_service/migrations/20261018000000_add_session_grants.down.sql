@@ -0,0 +1,5 @@
+-- 20261018000000_add_session_grants.down.sql
+
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS scopes,
+    DROP COLUMN IF EXISTS client_id;
@@ -0,0 +1,8 @@
+-- 20261018000000_add_session_grants.up.sql
+
+-- Sessions opened for an OIDC client (authorization code or device flow)
+-- remember the client and the scopes the user granted it, so that refreshed
+-- access tokens keep the same limits. First-party logins have neither.
+ALTER TABLE sessions
+    ADD COLUMN client_id VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,3 @@
+-- 20261018010000_add_device_poll_interval.down.sql
+
+ALTER TABLE device_authorizations DROP COLUMN IF EXISTS poll_interval;
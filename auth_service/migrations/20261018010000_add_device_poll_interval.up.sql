@@ -0,0 +1,5 @@
+-- 20261018010000_add_device_poll_interval.up.sql
+
+-- Seconds a device must wait between polls. Every slow_down answer adds 5
+-- seconds (RFC 8628 section 3.5).
+ALTER TABLE device_authorizations ADD COLUMN poll_interval INTEGER NOT NULL DEFAULT 5;
@@ -0,0 +1,217 @@
+package authclient
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minRSAKeyBits rejects RSA keys too weak to trust, as auth_service does
+// for the keys it signs with.
+const minRSAKeyBits = 2048
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+// jwksSource caches the keys of a JWKS endpoint by kid.
+type jwksSource struct {
+	url        string
+	client     *http.Client
+	minRefresh time.Duration
+
+	fetchMu   sync.Mutex // serialises fetches
+	mu        sync.RWMutex
+	keys      map[string]verifyKey
+	fetchedAt time.Time
+	lastErr   error
+}
+
+func newJWKSSource(url string, client *http.Client, minRefresh time.Duration) *jwksSource {
+	return &jwksSource{
+		url:        url,
+		client:     client,
+		minRefresh: minRefresh,
+		keys:       map[string]verifyKey{},
+	}
+}
+
+// lookup returns the key for kid. An unknown kid may belong to a key that was
+// rotated in after the last fetch, so the JWKS is fetched again unless that
+// happened very recently.
+func (s *jwksSource) lookup(ctx context.Context, kid string) (verifyKey, error) {
+	if key, ok := s.get(kid); ok {
+		return key, nil
+	}
+
+	if err := s.refresh(ctx, false); err != nil {
+		return verifyKey{}, err
+	}
+	if key, ok := s.get(kid); ok {
+		return key, nil
+	}
+
+	s.mu.RLock()
+	lastErr := s.lastErr
+	s.mu.RUnlock()
+	if lastErr != nil {
+		return verifyKey{}, fmt.Errorf("%w: %v", ErrUnknownKey, lastErr)
+	}
+	return verifyKey{}, ErrUnknownKey
+}
+
+func (s *jwksSource) get(kid string) (verifyKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// run fetches the JWKS right away and then every interval until ctx is done.
+func (s *jwksSource) run(ctx context.Context, interval time.Duration) {
+	_ = s.refresh(ctx, true)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = s.refresh(ctx, true)
+		}
+	}
+}
+
+// refresh re-fetches the JWKS. Unless forced, it is skipped when the last
+// attempt is more recent than minRefresh. On failure the cached keys are kept.
+func (s *jwksSource) refresh(ctx context.Context, force bool) error {
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+
+	s.mu.RLock()
+	recent := time.Since(s.fetchedAt) < s.minRefresh
+	s.mu.RUnlock()
+	if !force && recent {
+		return nil
+	}
+
+	keys, err := s.fetch(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetchedAt = time.Now()
+	s.lastErr = err
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	return nil
+}
+
+func (s *jwksSource) fetch(ctx context.Context) (map[string]verifyKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]verifyKey, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := k.verifyKey()
+		if err != nil {
+			// Skip keys this client does not understand rather than failing
+			// the whole set.
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jwk) verifyKey() (verifyKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return verifyKey{}, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return verifyKey{}, err
+		}
+		key := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if err := checkRSAKey(key); err != nil {
+			return verifyKey{}, err
+		}
+		return verifyKey{alg: "RS256", key: key}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return verifyKey{}, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return verifyKey{}, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return verifyKey{}, errors.New("invalid Ed25519 key size")
+		}
+		return verifyKey{alg: "EdDSA", key: ed25519.PublicKey(x)}, nil
+	default:
+		return verifyKey{}, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func checkRSAKey(key *rsa.PublicKey) error {
+	if key.N.BitLen() < minRSAKeyBits {
+		return fmt.Errorf("rsa key must be at least %d bits", minRSAKeyBits)
+	}
+	if key.E < 3 || key.E%2 == 0 {
+		return errors.New("invalid rsa public exponent")
+	}
+	return nil
+}
+
+// ParsePublicKeyPEM reads a PKIX ("PUBLIC KEY") block for use as Config.StaticKey.
+func ParsePublicKeyPEM(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("authclient: no PUBLIC KEY block found")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
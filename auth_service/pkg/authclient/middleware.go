@@ -0,0 +1,69 @@
+package authclient
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// GinPrincipalKey is the gin context key the principal is also stored under.
+const GinPrincipalKey = "authclient.principal"
+
+// Middleware is net/http middleware that rejects requests without a valid
+// bearer token and stores the Principal in the request context.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := v.verifyRequest(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", challenge(err))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(errorResponse{Message: err.Error()})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
+
+// GinMiddleware is the gin counterpart of Middleware. The principal is
+// available through PrincipalFrom(c.Request.Context()) or GinPrincipal(c).
+func (v *Verifier) GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := v.verifyRequest(c.Request)
+		if err != nil {
+			c.Header("WWW-Authenticate", challenge(err))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse{Message: err.Error()})
+			return
+		}
+		c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), p))
+		c.Set(GinPrincipalKey, p)
+		c.Next()
+	}
+}
+
+// GinPrincipal returns the principal stored by GinMiddleware.
+func GinPrincipal(c *gin.Context) (*Principal, bool) {
+	return PrincipalFrom(c.Request.Context())
+}
+
+func (v *Verifier) verifyRequest(r *http.Request) (*Principal, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, ErrMissingToken
+	}
+	return v.Verify(r.Context(), token)
+}
+
+type errorResponse struct {
+	Message string `json:"message"`
+}
+
+// challenge builds the WWW-Authenticate header of RFC 6750 section 3.
+func challenge(err error) string {
+	if errors.Is(err, ErrMissingToken) {
+		return `Bearer realm="auth-service"`
+	}
+	return `Bearer realm="auth-service", error="invalid_token"`
+}
@@ -0,0 +1,79 @@
+package authclient
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Subject types, matching the "sub_type" claim of auth_service access tokens.
+const (
+	SubjectTypeUser   = "user"
+	SubjectTypeClient = "client"
+)
+
+// Principal is the caller identified by a verified access token.
+type Principal struct {
+	// Subject is the user id for user tokens and the client_id for service tokens.
+	Subject     string
+	SubjectType string
+
+	UserID    string // empty for service tokens
+	ClientID  string // empty for user tokens
+	SessionID string // empty for service tokens
+	TokenID   string // jti
+	Scopes    []string
+	Audience  []string
+	ExpiresAt time.Time
+
+	// Actor is set on delegated tokens obtained through token exchange: the
+	// service currently acting on behalf of the user.
+	Actor *Actor
+
+	// Profile claims of user tokens. auth_service only adds the ones
+	// configured for the token's audience; the others are empty.
+	Roles         []string
+	Email         string
+	EmailVerified *bool
+	Locale        string
+	Name          string
+}
+
+// Actor is the RFC 8693 "act" claim. Actor.Actor names the previous actor
+// when a delegated token was exchanged again.
+type Actor struct {
+	Subject string `json:"sub"`
+	Actor   *Actor `json:"act,omitempty"`
+}
+
+// IsDelegated reports whether a service is acting on behalf of the subject.
+func (p *Principal) IsDelegated() bool {
+	return p.Actor != nil
+}
+
+// IsUser reports whether the token was issued to a user rather than a service.
+func (p *Principal) IsUser() bool {
+	return p.SubjectType == SubjectTypeUser
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// HasRole reports whether the token carries role in its roles claim.
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored by the middleware, if any.
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
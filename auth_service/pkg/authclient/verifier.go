@@ -0,0 +1,235 @@
+// Package authclient verifies auth_service access tokens inside other Go
+// services. Keys come either from the auth service JWKS, cached and refreshed
+// in the background, or from a static key. Middleware for net/http and gin
+// puts the verified Principal into the request context.
+//
+//	verifier, err := authclient.New(ctx, authclient.Config{
+//		JWKSURL:   "http://auth-service:8080/.well-known/jwks.json",
+//		Audiences: []string{"content-service"},
+//	})
+//	router.Use(verifier.GinMiddleware())
+//
+// Tokens are checked offline, so revocations made in auth_service are not
+// seen until the token expires. Use token introspection where that matters.
+package authclient
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultIssuer          = "auth-service"
+	defaultRefreshInterval = 5 * time.Minute
+	defaultLeeway          = 30 * time.Second
+)
+
+var (
+	ErrMissingToken    = errors.New("missing bearer token")
+	ErrInvalidToken    = errors.New("invalid access token")
+	ErrInvalidAudience = errors.New("access token is not addressed to this service")
+	ErrUnknownKey      = errors.New("unknown signing key")
+)
+
+// Config configures a Verifier. Exactly one of JWKSURL and StaticKey is set.
+type Config struct {
+	// JWKSURL is the auth service JWKS endpoint.
+	JWKSURL string
+
+	// StaticKey is an *rsa.PublicKey, an ed25519.PublicKey or, for an auth
+	// service signing access tokens with a shared secret, the secret as []byte.
+	StaticKey any
+
+	// Audiences this service accepts; a token must name at least one of them.
+	Audiences []string
+
+	// Issuer defaults to "auth-service".
+	Issuer string
+
+	// RefreshInterval is how often the JWKS is re-fetched. Defaults to 5 minutes.
+	// A token signed by an unknown kid also triggers a fetch, at most once per
+	// interval / 10, so key rotations are picked up right away.
+	RefreshInterval time.Duration
+
+	// Leeway tolerates clock skew on exp and iat. Defaults to 30 seconds.
+	Leeway time.Duration
+
+	// HTTPClient is used for JWKS requests. Defaults to a client with a 10s timeout.
+	HTTPClient *http.Client
+}
+
+type keySource interface {
+	lookup(ctx context.Context, kid string) (verifyKey, error)
+}
+
+type verifyKey struct {
+	alg string
+	key any
+}
+
+// Verifier checks auth_service access tokens. It is safe for concurrent use.
+type Verifier struct {
+	keys      keySource
+	issuer    string
+	audiences []string
+	leeway    time.Duration
+}
+
+// New builds a Verifier. With a JWKS URL, keys are fetched in the background
+// until ctx is done; a fetch failure is reported by Verify, not by New, so a
+// service can start before the auth service is up.
+func New(ctx context.Context, cfg Config) (*Verifier, error) {
+	if len(cfg.Audiences) == 0 {
+		return nil, errors.New("authclient: at least one audience is required")
+	}
+	if (cfg.JWKSURL == "") == (cfg.StaticKey == nil) {
+		return nil, errors.New("authclient: set exactly one of JWKSURL and StaticKey")
+	}
+
+	v := &Verifier{
+		issuer:    cfg.Issuer,
+		audiences: cfg.Audiences,
+		leeway:    cfg.Leeway,
+	}
+	if v.issuer == "" {
+		v.issuer = defaultIssuer
+	}
+	if v.leeway == 0 {
+		v.leeway = defaultLeeway
+	}
+
+	if cfg.StaticKey != nil {
+		key, err := staticKey(cfg.StaticKey)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = key
+		return v, nil
+	}
+
+	interval := cfg.RefreshInterval
+	if interval == 0 {
+		interval = defaultRefreshInterval
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	jwks := newJWKSSource(cfg.JWKSURL, client, interval/10)
+	go jwks.run(ctx, interval)
+	v.keys = jwks
+
+	return v, nil
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	UserID      string `json:"user_id"`
+	Type        string `json:"type"`
+	SessionID   string `json:"sid,omitempty"`
+	SubjectType string `json:"sub_type,omitempty"`
+	ClientID    string `json:"client_id,omitempty"`
+	Scope       string `json:"scope,omitempty"`
+	Actor       *Actor `json:"act,omitempty"`
+
+	Roles         []string `json:"roles,omitempty"`
+	Email         string   `json:"email,omitempty"`
+	EmailVerified *bool    `json:"email_verified,omitempty"`
+	Locale        string   `json:"locale,omitempty"`
+	Name          string   `json:"name,omitempty"`
+}
+
+// Verify checks the signature, issuer, type, expiry and audience of an access
+// token and returns its principal.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	var keyErr error
+	parsed, err := jwt.ParseWithClaims(token, &claims{}, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := v.keys.lookup(ctx, kid)
+		if err != nil {
+			keyErr = err
+			return nil, err
+		}
+		if t.Method.Alg() != key.alg {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+		return key.key, nil
+	},
+		jwt.WithIssuer(v.issuer),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.leeway),
+	)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	c, ok := parsed.Claims.(*claims)
+	if !ok || !parsed.Valid || c.Type != "access" {
+		return nil, ErrInvalidToken
+	}
+	if !slices.ContainsFunc(c.Audience, func(aud string) bool {
+		return slices.Contains(v.audiences, aud)
+	}) {
+		return nil, ErrInvalidAudience
+	}
+
+	subjectType := c.SubjectType
+	if subjectType == "" {
+		subjectType = SubjectTypeUser
+	}
+	return &Principal{
+		Subject:     c.Subject,
+		SubjectType: subjectType,
+		UserID:      c.UserID,
+		ClientID:    c.ClientID,
+		SessionID:   c.SessionID,
+		TokenID:     c.ID,
+		Scopes:      strings.Fields(c.Scope),
+		Audience:    c.Audience,
+		ExpiresAt:   c.ExpiresAt.Time,
+		Actor:       c.Actor,
+
+		Roles:         c.Roles,
+		Email:         c.Email,
+		EmailVerified: c.EmailVerified,
+		Locale:        c.Locale,
+		Name:          c.Name,
+	}, nil
+}
+
+type staticSource struct {
+	key verifyKey
+}
+
+func staticKey(key any) (*staticSource, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if err := checkRSAKey(k); err != nil {
+			return nil, fmt.Errorf("authclient: %w", err)
+		}
+		return &staticSource{verifyKey{alg: jwt.SigningMethodRS256.Alg(), key: k}}, nil
+	case ed25519.PublicKey:
+		return &staticSource{verifyKey{alg: jwt.SigningMethodEdDSA.Alg(), key: k}}, nil
+	case []byte:
+		return &staticSource{verifyKey{alg: jwt.SigningMethodHS256.Alg(), key: k}}, nil
+	default:
+		return nil, fmt.Errorf("authclient: unsupported static key type %T", key)
+	}
+}
+
+// lookup ignores kid: a static key verifies every token.
+func (s *staticSource) lookup(context.Context, string) (verifyKey, error) {
+	return s.key, nil
+}
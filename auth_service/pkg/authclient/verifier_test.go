@@ -0,0 +1,232 @@
+package authclient
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testJWKS serves the public keys it holds and can rotate them.
+type testJWKS struct {
+	mu   sync.Mutex
+	keys []jwk
+}
+
+func (s *testJWKS) set(keys ...jwk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *testJWKS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = json.NewEncoder(w).Encode(map[string][]jwk{"keys": s.keys})
+}
+
+func ed25519JWK(kid string, pub ed25519.PublicKey) jwk {
+	return jwk{Kty: "OKP", Kid: kid, Alg: "EdDSA", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(pub)}
+}
+
+func rsaJWK(kid string, pub *rsa.PublicKey) jwk {
+	return jwk{
+		Kty: "RSA", Kid: kid, Alg: "RS256",
+		N: base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+// validClaims are the claims of an access token for content-service.
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":     "auth-service",
+		"sub":     "6f1c0a7e-3b5d-4c2a-9e8f-0a1b2c3d4e5f",
+		"user_id": "6f1c0a7e-3b5d-4c2a-9e8f-0a1b2c3d4e5f",
+		"type":    "access",
+		"aud":     []string{"content-service"},
+		"iat":     now.Unix(),
+		"exp":     now.Add(15 * time.Minute).Unix(),
+		"jti":     "0d6c9a52-5a1e-4c5f-9c4a-2f3b1a0e9d8c",
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key crypto.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func newTestVerifier(t *testing.T, jwks *testJWKS, refresh time.Duration) *Verifier {
+	t.Helper()
+	server := httptest.NewServer(jwks)
+	t.Cleanup(server.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	v, err := New(ctx, Config{
+		JWKSURL:         server.URL,
+		Audiences:       []string{"content-service"},
+		RefreshInterval: refresh,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	key := newEd25519Key(t)
+	jwks := &testJWKS{}
+	jwks.set(ed25519JWK("k1", key.Public().(ed25519.PublicKey)))
+	v := newTestVerifier(t, jwks, time.Hour)
+
+	with := func(edit func(jwt.MapClaims)) jwt.MapClaims {
+		claims := validClaims()
+		edit(claims)
+		return claims
+	}
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{
+			name:  "valid",
+			token: sign(t, jwt.SigningMethodEdDSA, key, "k1", validClaims()),
+		},
+		{
+			name:    "refresh token",
+			token:   sign(t, jwt.SigningMethodEdDSA, key, "k1", with(func(c jwt.MapClaims) { c["type"] = "refresh" })),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "capability token",
+			token:   sign(t, jwt.SigningMethodEdDSA, key, "k1", with(func(c jwt.MapClaims) { c["type"] = "capability" })),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "wrong issuer",
+			token:   sign(t, jwt.SigningMethodEdDSA, key, "k1", with(func(c jwt.MapClaims) { c["iss"] = "other-issuer" })),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "missing exp",
+			token:   sign(t, jwt.SigningMethodEdDSA, key, "k1", with(func(c jwt.MapClaims) { delete(c, "exp") })),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "expired past the leeway",
+			token:   sign(t, jwt.SigningMethodEdDSA, key, "k1", with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() })),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "wrong audience",
+			token:   sign(t, jwt.SigningMethodEdDSA, key, "k1", with(func(c jwt.MapClaims) { c["aud"] = []string{"ai-service"} })),
+			wantErr: ErrInvalidAudience,
+		},
+		{
+			name:    "unknown kid",
+			token:   sign(t, jwt.SigningMethodEdDSA, newEd25519Key(t), "k2", validClaims()),
+			wantErr: ErrUnknownKey,
+		},
+		{
+			// The public key, which anyone can fetch, used as an HMAC secret.
+			name:    "alg mismatch",
+			token:   sign(t, jwt.SigningMethodHS256, []byte(key.Public().(ed25519.PublicKey)), "k1", validClaims()),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "alg none",
+			token:   sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "k1", validClaims()),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "signed by another key with the same kid",
+			token:   sign(t, jwt.SigningMethodEdDSA, newEd25519Key(t), "k1", validClaims()),
+			wantErr: ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := v.Verify(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && principal.UserID != validClaims()["user_id"] {
+				t.Errorf("UserID = %q", principal.UserID)
+			}
+		})
+	}
+}
+
+// A token signed by a key rotated in after the last fetch is accepted once
+// the JWKS is fetched again, and tokens of a removed key are rejected.
+func TestVerifyAfterKeyRotation(t *testing.T) {
+	oldKey, newKey := newEd25519Key(t), newEd25519Key(t)
+	jwks := &testJWKS{}
+	jwks.set(ed25519JWK("old", oldKey.Public().(ed25519.PublicKey)))
+	// Unknown kids trigger a fetch at most every RefreshInterval / 10.
+	v := newTestVerifier(t, jwks, time.Second)
+
+	oldToken := sign(t, jwt.SigningMethodEdDSA, oldKey, "old", validClaims())
+	if _, err := v.Verify(context.Background(), oldToken); err != nil {
+		t.Fatalf("token of the initial key: %v", err)
+	}
+
+	jwks.set(ed25519JWK("new", newKey.Public().(ed25519.PublicKey)))
+	time.Sleep(150 * time.Millisecond)
+
+	newToken := sign(t, jwt.SigningMethodEdDSA, newKey, "new", validClaims())
+	if _, err := v.Verify(context.Background(), newToken); err != nil {
+		t.Fatalf("token of the rotated key: %v", err)
+	}
+	if _, err := v.Verify(context.Background(), oldToken); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("token of the removed key: error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestVerifyRejectsWeakRSAKeys(t *testing.T) {
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := &testJWKS{}
+	jwks.set(rsaJWK("weak", &weak.PublicKey))
+	v := newTestVerifier(t, jwks, time.Hour)
+
+	token := sign(t, jwt.SigningMethodRS256, weak, "weak", validClaims())
+	if _, err := v.Verify(context.Background(), token); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("token of a 1024 bit key: error = %v, want %v", err, ErrUnknownKey)
+	}
+
+	if _, err := New(context.Background(), Config{StaticKey: &weak.PublicKey, Audiences: []string{"content-service"}}); err == nil {
+		t.Error("1024 bit static key accepted")
+	}
+}